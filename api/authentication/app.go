@@ -2,16 +2,28 @@ package authentication
 
 import (
 	"context"
+	"errors"
+	"github.com/lt90s/goanalytics/api/middlewares"
 	"github.com/lt90s/goanalytics/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
 )
 
 const (
-	appKeyLength  = 64
-	appCollection = "applicationCollection"
+	appKeyLength   = 64
+	appKeyIdLength = 8
+	appCollection  = "applicationCollection"
+	// retention days are limited to a year
+	maxRetentionDay = 365
+)
+
+var (
+	AppNotExistError        = errors.New("app not exist")
+	SigningKeyNotExistError = errors.New("signing key not exist")
+	NoActiveSigningKeyError = errors.New("app has no active signing key")
 )
 
 func (ms *mongoStore) appCollection() *mongo.Collection {
@@ -122,3 +134,190 @@ func (ms *mongoStore) DeleteApp(appId string) error {
 
 	return err
 }
+
+func (ms *mongoStore) getAppInfo(appId string) (info AppInfo, err error) {
+	ctx := context.Background()
+	id, err := primitive.ObjectIDFromHex(appId)
+	if err != nil {
+		return
+	}
+	result := ms.appCollection().FindOne(ctx, bson.M{"_id": id})
+	if err = result.Err(); err != nil {
+		return
+	}
+	err = result.Decode(&info)
+	info.AppId = appId
+	return
+}
+
+func (ms *mongoStore) GetAppConfig(appId string) (config middlewares.AppConfig, err error) {
+	info, err := ms.getAppInfo(appId)
+	if err != nil {
+		return
+	}
+
+	config = middlewares.AppConfig{
+		AppKey:           info.AppKey,
+		SigningKeys:      make(map[string]string),
+		EnforceSignature: info.EnforceSignature,
+		RateLimit: middlewares.RateLimit{
+			AppRate:     info.RateLimit.AppRate,
+			AppBurst:    info.RateLimit.AppBurst,
+			DeviceRate:  info.RateLimit.DeviceRate,
+			DeviceBurst: info.RateLimit.DeviceBurst,
+		},
+		ClockSkew: middlewares.ClockSkew{
+			Policy:    info.ClockSkew.Policy,
+			Threshold: info.ClockSkew.Threshold,
+		},
+		Platforms:     info.Platforms,
+		RetentionDays: info.RetentionDays,
+	}
+	for _, key := range info.SigningKeys {
+		if key.Active {
+			config.SigningKeys[key.KeyId] = key.Key
+		}
+	}
+	return
+}
+
+func (ms *mongoStore) AddSigningKey(appId string) (key SigningKey, err error) {
+	id, err := primitive.ObjectIDFromHex(appId)
+	if err != nil {
+		return
+	}
+	keyId, err := utils.RandomHexStringKey(appKeyIdLength)
+	if err != nil {
+		return
+	}
+	secret, err := utils.RandomHexStringKey(appKeyLength)
+	if err != nil {
+		return
+	}
+	key = SigningKey{
+		KeyId:     keyId,
+		Key:       secret,
+		Active:    true,
+		CreatedAt: utils.NowTimestamp(),
+	}
+
+	ctx := context.Background()
+	result, err := ms.appCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$push": bson.M{"signingKeys": key},
+	})
+	if err != nil {
+		return
+	}
+	if result.MatchedCount == 0 {
+		err = AppNotExistError
+	}
+	return
+}
+
+func (ms *mongoStore) DeactivateSigningKey(appId, keyId string) error {
+	id, err := primitive.ObjectIDFromHex(appId)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	filter := bson.M{"_id": id, "signingKeys.keyId": keyId}
+	update := bson.M{"$set": bson.M{"signingKeys.$.active": false}}
+	result, err := ms.appCollection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return SigningKeyNotExistError
+	}
+	return nil
+}
+
+// SetEnforceSignature switches version 2 signature enforcement. Enforcement requires at least
+// one active signing key, otherwise the app could not accept any data.
+func (ms *mongoStore) SetEnforceSignature(appId string, enforce bool) error {
+	id, err := primitive.ObjectIDFromHex(appId)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	filter := bson.M{"_id": id}
+	if enforce {
+		filter["signingKeys.active"] = true
+	}
+	update := bson.M{"$set": bson.M{"enforceSignature": enforce}}
+	result, err := ms.appCollection().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if enforce {
+			return NoActiveSigningKeyError
+		}
+		return AppNotExistError
+	}
+	return nil
+}
+
+func (ms *mongoStore) updateApp(appId string, set bson.M) error {
+	id, err := primitive.ObjectIDFromHex(appId)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	result, err := ms.appCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return AppNotExistError
+	}
+	return nil
+}
+
+func (ms *mongoStore) SetRateLimit(appId string, limit RateLimit) error {
+	return ms.updateApp(appId, bson.M{"rateLimit": limit})
+}
+
+func (ms *mongoStore) SetClockSkew(appId string, clockSkew ClockSkew) error {
+	return ms.updateApp(appId, bson.M{"clockSkew": clockSkew})
+}
+
+// SetPlatforms sets the platforms accepted for the app, platforms are normalized and
+// deduplicated. An empty list restores the default platforms.
+func (ms *mongoStore) SetPlatforms(appId string, platforms []string) error {
+	normalized := make([]string, 0, len(platforms))
+	seen := make(map[string]bool)
+	for _, platform := range platforms {
+		platform = middlewares.NormalizePlatform(platform)
+		if platform == "" || seen[platform] {
+			continue
+		}
+		seen[platform] = true
+		normalized = append(normalized, platform)
+	}
+	return ms.updateApp(appId, bson.M{"platforms": normalized})
+}
+
+// SetRetentionDays sets the days counted by retention for the app, days are sorted and
+// deduplicated. An empty list restores the default retention days.
+// normalizeRetentionDays returns the days sorted without duplicates
+func normalizeRetentionDays(days []int) []int {
+	normalized := make([]int, 0, len(days))
+	seen := make(map[int]bool)
+	for _, day := range days {
+		if seen[day] {
+			continue
+		}
+		seen[day] = true
+		normalized = append(normalized, day)
+	}
+	sort.Ints(normalized)
+	return normalized
+}
+
+func (ms *mongoStore) SetRetentionDays(appId string, days []int) error {
+	return ms.updateApp(appId, bson.M{"retentionDays": normalizeRetentionDays(days)})
+}
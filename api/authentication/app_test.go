@@ -2,6 +2,7 @@ package authentication
 
 import (
 	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -45,3 +46,68 @@ func TestMongoStore_GetAppKey(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, info.AppKey, key)
 }
+
+func TestMongoStore_SigningKey(t *testing.T) {
+	store := NewMongoStore(client, database).(*mongoStore)
+	defer client.Database(database).Drop(context.Background())
+
+	info, err := store.CreateApp("test", "testApp")
+	require.NoError(t, err)
+
+	err = store.SetEnforceSignature(info.AppId, true)
+	require.Equal(t, NoActiveSigningKeyError, err)
+
+	key, err := store.AddSigningKey(info.AppId)
+	require.NoError(t, err)
+	require.True(t, key.Active)
+
+	require.NoError(t, store.SetEnforceSignature(info.AppId, true))
+
+	config, err := store.GetAppConfig(info.AppId)
+	require.NoError(t, err)
+	require.True(t, config.EnforceSignature)
+	require.Equal(t, info.AppKey, config.AppKey)
+	require.Equal(t, key.Key, config.SigningKeys[key.KeyId])
+
+	require.NoError(t, store.DeactivateSigningKey(info.AppId, key.KeyId))
+	config, err = store.GetAppConfig(info.AppId)
+	require.NoError(t, err)
+	require.Len(t, config.SigningKeys, 0)
+
+	require.Equal(t, SigningKeyNotExistError, store.DeactivateSigningKey(info.AppId, "foo"))
+}
+
+func TestMongoStore_SetPlatforms(t *testing.T) {
+	store := NewMongoStore(client, database).(*mongoStore)
+	defer client.Database(database).Drop(context.Background())
+
+	info, err := store.CreateApp("test", "testApp")
+	require.NoError(t, err)
+
+	require.NoError(t, store.SetPlatforms(info.AppId, []string{"iOS", "iPadOS", "web", ""}))
+	config, err := store.GetAppConfig(info.AppId)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ios", "web"}, config.Platforms)
+}
+
+func TestMongoStore_SetRetentionDays(t *testing.T) {
+	store := NewMongoStore(client, database).(*mongoStore)
+	defer client.Database(database).Drop(context.Background())
+
+	info, err := store.CreateApp("test", "testApp")
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 15, 30}, middlewares.RetentionDaysOf(store, info.AppId))
+
+	require.NoError(t, store.SetRetentionDays(info.AppId, []int{90, 7, 1, 7}))
+	config, err := store.GetAppConfig(info.AppId)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 7, 90}, config.RetentionDays)
+	require.Equal(t, []int{1, 7, 90}, middlewares.RetentionDaysOf(store, info.AppId))
+}
+
+func TestNormalizeRetentionDays(t *testing.T) {
+	require.Equal(t, []int{60}, normalizeRetentionDays([]int{60, 60}))
+	require.Equal(t, []int{1, 7, 90}, normalizeRetentionDays([]int{90, 7, 1, 7}))
+	require.Len(t, normalizeRetentionDays(nil), 0)
+}
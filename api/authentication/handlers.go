@@ -2,6 +2,7 @@ package authentication
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
 	"github.com/lt90s/goanalytics/common"
 	"github.com/lt90s/goanalytics/event/pubsub"
 	"github.com/lt90s/goanalytics/utils"
@@ -151,3 +152,181 @@ func deleteAppHandler(adminStore store, publisher pubsub.Publisher) gin.HandlerF
 		publisher.Publish(common.GlobalEventDropData, &data)
 	}
 }
+
+func addSigningKeyHandler(adminStore store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var data struct {
+			AppId string `json:"appId"`
+		}
+		err := c.ShouldBindJSON(&data)
+		if err != nil || data.AppId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		key, err := adminStore.AddSigningKey(data.AppId)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", key)
+		}
+	}
+}
+
+func deactivateSigningKeyHandler(adminStore store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var data struct {
+			AppId string `json:"appId"`
+			KeyId string `json:"keyId"`
+		}
+		err := c.ShouldBindJSON(&data)
+		if err != nil || data.AppId == "" || data.KeyId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		err = adminStore.DeactivateSigningKey(data.AppId, data.KeyId)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", gin.H{})
+		}
+	}
+}
+
+func setEnforceSignatureHandler(adminStore store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var data struct {
+			AppId   string `json:"appId"`
+			Enforce bool   `json:"enforce"`
+		}
+		err := c.ShouldBindJSON(&data)
+		if err != nil || data.AppId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		err = adminStore.SetEnforceSignature(data.AppId, data.Enforce)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", gin.H{})
+		}
+	}
+}
+
+func setRateLimitHandler(adminStore store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var data struct {
+			AppId string `json:"appId"`
+			RateLimit
+		}
+		err := c.ShouldBindJSON(&data)
+		if err != nil || data.AppId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if data.AppRate < 0 || data.AppBurst < 0 || data.DeviceRate < 0 || data.DeviceBurst < 0 {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		err = adminStore.SetRateLimit(data.AppId, data.RateLimit)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", gin.H{})
+		}
+	}
+}
+
+func setClockSkewHandler(adminStore store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var data struct {
+			AppId string `json:"appId"`
+			ClockSkew
+		}
+		err := c.ShouldBindJSON(&data)
+		if err != nil || data.AppId == "" || data.Threshold < 0 {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if data.Policy != "" && !middlewares.IsClockSkewPolicyValid(data.Policy) {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		err = adminStore.SetClockSkew(data.AppId, data.ClockSkew)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", gin.H{})
+		}
+	}
+}
+
+func setPlatformsHandler(adminStore store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var data struct {
+			AppId     string   `json:"appId"`
+			Platforms []string `json:"platforms"`
+		}
+		err := c.ShouldBindJSON(&data)
+		if err != nil || data.AppId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		err = adminStore.SetPlatforms(data.AppId, data.Platforms)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", gin.H{})
+		}
+	}
+}
+
+// setRetentionDaysHandler sets the retention days of the app and requests the backfill of the
+// retention of newly added days
+func setRetentionDaysHandler(adminStore store, publisher pubsub.Publisher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var data struct {
+			AppId         string `json:"appId"`
+			RetentionDays []int  `json:"retentionDays"`
+		}
+		err := c.ShouldBindJSON(&data)
+		if err != nil || data.AppId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		for _, day := range data.RetentionDays {
+			if day < 1 || day > maxRetentionDay {
+				c.Set("error", utils.ParamError)
+				return
+			}
+		}
+		// a repeated day would be counted twice by the backfill
+		days := normalizeRetentionDays(data.RetentionDays)
+
+		previous := make(map[int]bool)
+		for _, day := range middlewares.RetentionDaysOf(adminStore, data.AppId) {
+			previous[day] = true
+		}
+		err = adminStore.SetRetentionDays(data.AppId, days)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+
+		request := common.BackfillRetentionRequest{AppId: data.AppId, Days: []int{}}
+		for _, day := range normalizeRetentionDays(middlewares.RetentionDaysOf(adminStore, data.AppId)) {
+			if !previous[day] {
+				request.Days = append(request.Days, day)
+			}
+		}
+		if len(request.Days) > 0 {
+			publisher.Publish(common.GlobalEventBackfillRetention, &request)
+		}
+		c.Set("data", gin.H{"backfillDays": request.Days})
+	}
+}
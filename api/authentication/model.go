@@ -20,10 +20,41 @@ var (
 )
 
 type AppInfo struct {
-	AppId       string             `json:"appId"`
-	AppKey      string             `json:"appKey" bson:"appKey"`
-	MongoId     primitive.ObjectID `json:"-" bson:"_id"`
-	Name        string             `json:"name" bson:"name"`
-	Description string             `json:"description" bson:"description"`
-	CreatedAt   int64              `json:"createdAt" bson:"createdAt"`
+	AppId            string             `json:"appId"`
+	AppKey           string             `json:"appKey" bson:"appKey"`
+	MongoId          primitive.ObjectID `json:"-" bson:"_id"`
+	Name             string             `json:"name" bson:"name"`
+	Description      string             `json:"description" bson:"description"`
+	CreatedAt        int64              `json:"createdAt" bson:"createdAt"`
+	SigningKeys      []SigningKey       `json:"signingKeys" bson:"signingKeys"`
+	EnforceSignature bool               `json:"enforceSignature" bson:"enforceSignature"`
+	RateLimit        RateLimit          `json:"rateLimit" bson:"rateLimit"`
+	ClockSkew        ClockSkew          `json:"clockSkew" bson:"clockSkew"`
+	// accepted platforms, empty means the default
+	Platforms []string `json:"platforms" bson:"platforms"`
+	// days counted by retention, empty means the default
+	RetentionDays []int `json:"retentionDays" bson:"retentionDays"`
+}
+
+// SigningKey is a key for version 2 request signatures. An app may have several active keys
+// so that keys can be rotated without breaking released clients.
+type SigningKey struct {
+	KeyId     string `json:"keyId" bson:"keyId"`
+	Key       string `json:"key" bson:"key"`
+	Active    bool   `json:"active" bson:"active"`
+	CreatedAt int64  `json:"createdAt" bson:"createdAt"`
+}
+
+// RateLimit is the ingestion rate limit of an app in requests per second, zero means the default
+type RateLimit struct {
+	AppRate     float64 `json:"appRate" bson:"appRate"`
+	AppBurst    int     `json:"appBurst" bson:"appBurst"`
+	DeviceRate  float64 `json:"deviceRate" bson:"deviceRate"`
+	DeviceBurst int     `json:"deviceBurst" bson:"deviceBurst"`
+}
+
+// ClockSkew is the client clock skew policy of an app, empty values mean the default
+type ClockSkew struct {
+	Policy    string `json:"policy" bson:"policy"`
+	Threshold int64  `json:"threshold" bson:"threshold"`
 }
@@ -34,6 +34,18 @@ func SetupRoute(router *gin.Engine, jwtMiddleware *jwt.GinJWTMiddleware, adminSt
 	appGroup.GET("", getAppsHandler(adminStore))
 	appGroup.POST("", createAppHandler(adminStore))
 	appGroup.DELETE("", deleteAppHandler(adminStore, publisher))
+	// version 2 request signing
+	appGroup.POST("/signing_key", requireAdminRole, addSigningKeyHandler(adminStore))
+	appGroup.DELETE("/signing_key", requireAdminRole, deactivateSigningKeyHandler(adminStore))
+	appGroup.PUT("/signature", requireAdminRole, setEnforceSignatureHandler(adminStore))
+	// ingestion rate limit
+	appGroup.PUT("/rate_limit", requireAdminRole, setRateLimitHandler(adminStore))
+	// client clock skew policy
+	appGroup.PUT("/clock_skew", requireAdminRole, setClockSkewHandler(adminStore))
+	// accepted platforms
+	appGroup.PUT("/platforms", requireAdminRole, setPlatformsHandler(adminStore))
+	// retention days
+	appGroup.PUT("/retention_days", requireAdminRole, setRetentionDaysHandler(adminStore, publisher))
 }
 
 
@@ -22,6 +22,15 @@ type store interface {
 	CreateApp(name, description string) (info AppInfo, err error)
 	GetAppKey(appId string) (key string, err error)
 	DeleteApp(appId string) error
+
+	GetAppConfig(appId string) (config middlewares.AppConfig, err error)
+	AddSigningKey(appId string) (key SigningKey, err error)
+	DeactivateSigningKey(appId, keyId string) error
+	SetEnforceSignature(appId string, enforce bool) error
+	SetRateLimit(appId string, limit RateLimit) error
+	SetClockSkew(appId string, clockSkew ClockSkew) error
+	SetPlatforms(appId string, platforms []string) error
+	SetRetentionDays(appId string, days []int) error
 }
 
 type mongoStore struct {
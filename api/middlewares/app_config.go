@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"github.com/lt90s/goanalytics/conf"
+	"sync"
+	"time"
+)
+
+// AppConfig holds the per-app settings used by the ingestion middlewares
+type AppConfig struct {
+	AppKey string
+	// active signing keys, keyId -> key
+	SigningKeys      map[string]string
+	EnforceSignature bool
+	RateLimit        RateLimit
+	ClockSkew        ClockSkew
+	// accepted platforms, empty means the default
+	Platforms []string
+	// days counted by retention, empty means the default
+	RetentionDays []int
+}
+
+type AppConfigGetter interface {
+	GetAppConfig(appId string) (AppConfig, error)
+}
+
+type appConfigCacheItem struct {
+	config   AppConfig
+	expireAt time.Time
+}
+
+type cachedAppConfigGetter struct {
+	getter AppConfigGetter
+	ttl    time.Duration
+	mutex  sync.RWMutex
+	items  map[string]appConfigCacheItem
+}
+
+// NewCachedAppConfigGetter caches app configs for ttl so that every ingestion request
+// does not hit the admin database. Changes to an app's settings take effect after ttl.
+func NewCachedAppConfigGetter(getter AppConfigGetter, ttl time.Duration) AppConfigGetter {
+	return &cachedAppConfigGetter{
+		getter: getter,
+		ttl:    ttl,
+		items:  make(map[string]appConfigCacheItem),
+	}
+}
+
+func (cg *cachedAppConfigGetter) GetAppConfig(appId string) (AppConfig, error) {
+	now := time.Now()
+	cg.mutex.RLock()
+	item, ok := cg.items[appId]
+	cg.mutex.RUnlock()
+	if ok && now.Before(item.expireAt) {
+		return item.config, nil
+	}
+
+	config, err := cg.getter.GetAppConfig(appId)
+	if err != nil {
+		return config, err
+	}
+
+	cg.mutex.Lock()
+	cg.items[appId] = appConfigCacheItem{config: config, expireAt: now.Add(cg.ttl)}
+	cg.mutex.Unlock()
+	return config, nil
+}
+
+// RetentionDaysOf returns the retention days of the app, the configured default when the app
+// does not specify any
+func RetentionDaysOf(appConfigGetter AppConfigGetter, appId string) []int {
+	config, err := appConfigGetter.GetAppConfig(appId)
+	if err == nil && len(config.RetentionDays) > 0 {
+		return config.RetentionDays
+	}
+	return conf.GetConfIntSlice(conf.RetentionDaysConfKey)
+}
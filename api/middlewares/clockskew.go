@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+const (
+	// ClockSkewedEventSlotCounter counts the events with a skewed client clock per day, slotted
+	// by skew direction
+	ClockSkewedEventSlotCounter = "ClockSkewedEventSlotCounter"
+
+	ClockSkewSlotFuture = "future"
+	ClockSkewSlotPast   = "past"
+)
+
+const (
+	// keep the client timestamp
+	ClockSkewPolicyTrust = "trust"
+	// correct the client timestamp with the server time
+	ClockSkewPolicyClamp = "clamp"
+	// reject the request
+	ClockSkewPolicyReject = "reject"
+)
+
+// ClockSkew is the clock skew setting of an app, zero values mean the default setting
+type ClockSkew struct {
+	Policy string
+	// seconds
+	Threshold int64
+}
+
+func IsClockSkewPolicyValid(policy string) bool {
+	return policy == ClockSkewPolicyTrust || policy == ClockSkewPolicyClamp || policy == ClockSkewPolicyReject
+}
+
+// ClockSkewMiddleware detects client clocks that differ from the server clock by more than
+// the threshold and applies the app's policy. The client clock is read from the sendTimestamp
+// of signed requests when present, so that cached events sent later are not mistaken for
+// skewed ones, otherwise from the event timestamp.
+//
+// Only the event timestamp is corrected by the clamp policy, ReplayMiddleware still checks its
+// acceptance window against the timestamp sent by the client.
+type ClockSkewMiddleware struct {
+	defaults        ClockSkew
+	appConfigGetter AppConfigGetter
+	counter         slotCounterAdder
+}
+
+func NewClockSkewMiddleware(defaults ClockSkew, appConfigGetter AppConfigGetter, counter slotCounterAdder) ClockSkewMiddleware {
+	return ClockSkewMiddleware{
+		defaults:        defaults,
+		appConfigGetter: appConfigGetter,
+		counter:         counter,
+	}
+}
+
+func (m ClockSkewMiddleware) settingOf(appId string) ClockSkew {
+	setting := m.defaults
+	config, err := m.appConfigGetter.GetAppConfig(appId)
+	if err != nil {
+		return setting
+	}
+	if config.ClockSkew.Policy != "" {
+		setting.Policy = config.ClockSkew.Policy
+	}
+	if config.ClockSkew.Threshold > 0 {
+		setting.Threshold = config.ClockSkew.Threshold
+	}
+	return setting
+}
+
+func (m ClockSkewMiddleware) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, ok := GetMetaData(c)
+		if !ok {
+			log.Error("[ClockSkewMiddleware] MetaData missing")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		clientTimestamp, hasSendTimestamp, err := signedSendTimestamp(c)
+		if err != nil {
+			SetRejectReason(c, RejectReasonBadTimestamp)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		if !hasSendTimestamp {
+			clientTimestamp = data.Timestamp
+		}
+
+		setting := m.settingOf(data.AppId)
+		skew := clientTimestamp - data.ServerTimestamp
+		if setting.Threshold <= 0 || (skew <= setting.Threshold && skew >= -setting.Threshold) {
+			c.Next()
+			return
+		}
+
+		slot := ClockSkewSlotFuture
+		if skew < 0 {
+			slot = ClockSkewSlotPast
+		}
+		entry := log.WithFields(log.Fields{"metadata": data, "skew": skew, "policy": setting.Policy})
+		entry.Debug("[ClockSkewMiddleware] clock skew detected")
+		err = m.counter.AddSlotCounter(data.AppId, ClockSkewedEventSlotCounter, slot, utils.TodayTimestamp(), 1.0)
+		if err != nil {
+			entry.Warn("[ClockSkewMiddleware] add counter error: ", err.Error())
+		}
+
+		switch setting.Policy {
+		case ClockSkewPolicyReject:
+			recordRejection(m.counter, data.AppId, RejectReasonClockSkew)
+			SetRejectReason(c, RejectReasonClockSkew)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		case ClockSkewPolicyClamp:
+			if hasSendTimestamp {
+				// shift the event by the clock error of the device
+				data.Timestamp -= skew
+			} else {
+				data.Timestamp = data.ServerTimestamp
+			}
+			if data.Timestamp > data.ServerTimestamp {
+				data.Timestamp = data.ServerTimestamp
+			}
+			data.DateTimestamp = utils.TimestampToDate(data.Timestamp).Unix()
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestClockSkewMiddleware(t *testing.T) {
+	getter := mockAppConfigGetter{}
+	metadata := NewMetaDataMiddleware(getter)
+
+	run := func(policy string, req *http.Request) (int, *MetaData, *mockSlotCounter) {
+		counter := newMockSlotCounter()
+		clockSkew := NewClockSkewMiddleware(ClockSkew{Policy: policy, Threshold: 600}, getter, counter)
+		var result *MetaData
+		router := gin.Default()
+		router.GET("/hello", metadata.Middleware(), clockSkew.Middleware(), func(c *gin.Context) {
+			result, _ = GetMetaData(c)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, result, counter
+	}
+
+	// not skewed
+	code, data, counter := run(ClockSkewPolicyReject, httptest.NewRequest(http.MethodGet, "/hello?"+queryString(), nil))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, timestamp, data.Timestamp)
+	require.Equal(t, 0.0, counter.get(ClockSkewedEventSlotCounter, ClockSkewSlotFuture))
+
+	// device clock is one hour ahead
+	ahead := url.Values{sendTimestampKey: {fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix())}}
+	request := func() *http.Request {
+		return signedV2Request(http.MethodGet, "", signingKey, ahead)
+	}
+
+	code, data, counter = run(ClockSkewPolicyTrust, request())
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, timestamp, data.Timestamp)
+	require.Equal(t, 1.0, counter.get(ClockSkewedEventSlotCounter, ClockSkewSlotFuture))
+
+	code, data, counter = run(ClockSkewPolicyClamp, request())
+	require.Equal(t, http.StatusOK, code)
+	require.InDelta(t, timestamp-3600, data.Timestamp, 2)
+	require.Equal(t, utils.TimestampToDate(data.Timestamp).Unix(), data.DateTimestamp)
+
+	code, _, counter = run(ClockSkewPolicyReject, request())
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonClockSkew))
+
+	// sendTimestamp of unsigned requests is ignored
+	req := httptest.NewRequest(http.MethodGet, "/hello?"+queryString()+"&"+ahead.Encode(), nil)
+	code, data, counter = run(ClockSkewPolicyReject, req)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, timestamp, data.Timestamp)
+	require.Equal(t, 0.0, counter.get(ClockSkewedEventSlotCounter, ClockSkewSlotFuture))
+}
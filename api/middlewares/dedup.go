@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/storage"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+const eventIdKey = "eventId"
+
+// DedupMiddleware drops the requests whose optional eventId has been seen within the window,
+// so that events retried by the SDK after a timeout are only counted once. Duplicates are
+// answered with success to stop the retries and counted in RejectedRequestSlotCounter. It must
+// be installed after MetaDataMiddleware.
+//
+// An event id is remembered while its request is handled, so that concurrent retries are
+// dropped, and forgotten when the handler fails so that a retry is counted. The SDK must not
+// reuse it for another event.
+type DedupMiddleware struct {
+	window  time.Duration
+	seen    storage.SeenSet
+	counter slotCounterAdder
+}
+
+func NewDedupMiddleware(window time.Duration, seen storage.SeenSet, counter slotCounterAdder) DedupMiddleware {
+	return DedupMiddleware{
+		window:  window,
+		seen:    seen,
+		counter: counter,
+	}
+}
+
+func (m DedupMiddleware) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		eventId := c.Query(eventIdKey)
+		if eventId == "" || m.window <= 0 {
+			c.Next()
+			return
+		}
+
+		data, ok := GetMetaData(c)
+		if !ok {
+			log.Error("[DedupMiddleware] MetaData missing")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		// event ids are only unique per device
+		key := data.DeviceId + "/" + eventId
+		seen, err := m.seen.Seen(data.AppId, key, m.window)
+		if err != nil {
+			// count the event twice rather than losing it when the store is unavailable
+			log.WithFields(log.Fields{"metadata": data, "error": err.Error()}).Warn("[DedupMiddleware] seen check error")
+		} else if seen {
+			log.WithFields(log.Fields{"metadata": data, "eventId": eventId}).Debug("[DedupMiddleware] duplicate event")
+			recordRejection(m.counter, data.AppId, RejectReasonDuplicateEvent)
+			c.AbortWithStatus(http.StatusOK)
+			return
+		}
+		c.Next()
+
+		if err == nil && handlerFailed(c) {
+			if err = m.seen.Forget(data.AppId, key); err != nil {
+				log.WithFields(log.Fields{"metadata": data, "error": err.Error()}).Warn("[DedupMiddleware] forget error")
+			}
+		}
+	}
+}
+
+// handlerFailed reports whether the request failed, the error is written by ResponseMiddleware
+// after the handlers return
+func handlerFailed(c *gin.Context) bool {
+	if _, ok := c.Get("error"); ok {
+		return true
+	}
+	return c.Writer.Status() >= http.StatusBadRequest
+}
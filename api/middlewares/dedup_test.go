@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/storage/memory"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDedupMiddleware(t *testing.T) {
+	counter := newMockSlotCounter()
+	metadata := NewMetaDataMiddleware(mockAppConfigGetter{})
+	dedup := NewDedupMiddleware(time.Hour, memory.NewSeenSet(0), counter)
+
+	handled := 0
+	router := gin.Default()
+	router.GET("/hello", metadata.Middleware(), dedup.Middleware(), func(c *gin.Context) {
+		handled++
+	})
+
+	request := func(query string) int {
+		req := httptest.NewRequest(http.MethodGet, "/hello?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	qs := queryString()
+	require.Equal(t, http.StatusOK, request(qs+"&eventId=e1"))
+	require.Equal(t, http.StatusOK, request(qs+"&eventId=e1"))
+	require.Equal(t, 1, handled)
+	require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonDuplicateEvent))
+
+	// event ids are scoped to the device
+	other := &MetaData{AppId: appId, Channel: channel, DeviceId: "other", Platform: platform, Timestamp: timestamp, Version: version}
+	otherQs := fmt.Sprintf("appId=%s&channel=%s&deviceId=%s&platform=%s&timestamp=%d&version=%s&sign=%s",
+		appId, channel, other.DeviceId, platform, timestamp, version, signV1(other, appKey))
+	require.Equal(t, http.StatusOK, request(otherQs+"&eventId=e1"))
+	require.Equal(t, http.StatusOK, request(qs+"&eventId=e2"))
+	// requests without an event id are never deduplicated
+	require.Equal(t, http.StatusOK, request(qs))
+	require.Equal(t, http.StatusOK, request(qs))
+	require.Equal(t, 5, handled)
+}
+
+func TestDedupMiddleware_HandlerError(t *testing.T) {
+	counter := newMockSlotCounter()
+	metadata := NewMetaDataMiddleware(mockAppConfigGetter{})
+	dedup := NewDedupMiddleware(time.Hour, memory.NewSeenSet(0), counter)
+
+	handled := 0
+	router := gin.Default()
+	router.Use(ResponseMiddleware)
+	router.GET("/hello", metadata.Middleware(), dedup.Middleware(), func(c *gin.Context) {
+		handled++
+		// the first attempt fails
+		if handled == 1 {
+			c.Set("error", utils.ParamError)
+		}
+	})
+
+	request := func(query string) int {
+		req := httptest.NewRequest(http.MethodGet, "/hello?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	qs := queryString() + "&eventId=e1"
+	require.Equal(t, http.StatusBadRequest, request(qs))
+	// the retry is handled, later ones are duplicates
+	require.Equal(t, http.StatusOK, request(qs))
+	require.Equal(t, http.StatusOK, request(qs))
+	require.Equal(t, 2, handled)
+}
@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/geoip"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+)
+
+// GeoIPMiddleware resolves the client ip to a location and attaches it to the MetaData.
+// Requests whose ip cannot be resolved are accepted without a location. The client ip is only
+// reliable behind TrustedProxyMiddleware.
+type GeoIPMiddleware struct {
+	locator geoip.Locator
+}
+
+func NewGeoIPMiddleware(locator geoip.Locator) GeoIPMiddleware {
+	return GeoIPMiddleware{locator}
+}
+
+func (m GeoIPMiddleware) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, ok := GetMetaData(c)
+		if !ok {
+			log.Error("[GeoIPMiddleware] MetaData missing")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		location, err := m.locator.Lookup(net.ParseIP(data.ClientIP))
+		if err != nil {
+			log.WithFields(log.Fields{"ip": data.ClientIP, "error": err.Error()}).Debug("[GeoIPMiddleware] lookup error")
+		} else if location.Country != "" {
+			data.Country = location.Country
+			data.Region = location.Region
+			data.City = location.City
+		}
+		c.Next()
+	}
+}
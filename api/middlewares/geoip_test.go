@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/geoip"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type mockLocator map[string]geoip.Location
+
+func (ml mockLocator) Lookup(ip net.IP) (geoip.Location, error) {
+	location, ok := ml[ip.String()]
+	if !ok {
+		return location, errors.New("not found")
+	}
+	return location, nil
+}
+
+func TestGeoIPMiddleware(t *testing.T) {
+	metadata := NewMetaDataMiddleware(mockAppConfigGetter{})
+	geoIP := NewGeoIPMiddleware(mockLocator{
+		"1.2.3.4": {Country: "US", Region: "US-MO", City: "St. Louis"},
+		"2.3.4.5": {City: "Nowhere"},
+	})
+
+	run := func(ip string) (int, *MetaData) {
+		var result *MetaData
+		router := gin.Default()
+		router.GET("/hello", metadata.Middleware(), geoIP.Middleware(), func(c *gin.Context) {
+			result, _ = GetMetaData(c)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/hello?"+queryString(), nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, result
+	}
+
+	code, data := run("1.2.3.4")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "1.2.3.4", data.ClientIP)
+	require.Equal(t, "US", data.Country)
+	require.Equal(t, "US-MO", data.Region)
+	require.Equal(t, "St. Louis", data.City)
+
+	// unknown location is accepted
+	code, data = run("5.6.7.8")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "5.6.7.8", data.ClientIP)
+	require.Equal(t, "", data.Country)
+
+	// a location without a country is not used
+	code, data = run("2.3.4.5")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "", data.City)
+}
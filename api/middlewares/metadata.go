@@ -1,23 +1,21 @@
 package middlewares
 
 import (
-	"crypto/md5"
-	"encoding/hex"
-	"fmt"
+	"bytes"
 	"github.com/gin-gonic/gin"
 	"github.com/lt90s/goanalytics/conf"
 	"github.com/lt90s/goanalytics/utils"
 	log "github.com/sirupsen/logrus"
+	"io/ioutil"
 	"net/http"
 	"strconv"
-	"strings"
 )
 
-const metaDataKey = "_metadata"
-
-type AppKeyGetter interface {
-	GetAppKey(appId string) (string, error)
-}
+const (
+	metaDataKey = "_metadata"
+	// set when the request carries a verified version 2 signature
+	signedKey = "_signed"
+)
 
 type MetaData struct {
 	AppId         string
@@ -28,14 +26,31 @@ type MetaData struct {
 	UserId        string
 	Timestamp     int64
 	DateTimestamp int64
+	// time the request is received by the server
+	ServerTimestamp int64
+	ClientIP        string
+	// optional device attributes
+	OSVersion    string
+	DeviceModel  string
+	Manufacturer string
+	Locale       string
+	NetworkType  string
+	Carrier      string
+	Resolution   string
+	// click id of the campaign tracking link reported on the first open, see metric/attribution
+	Referrer string
+	// filled by GeoIPMiddleware, empty when the location is unknown
+	Country string
+	Region  string
+	City    string
 }
 
 type MetaDataMiddleware struct {
-	appKeyGetter AppKeyGetter
+	appConfigGetter AppConfigGetter
 }
 
-func NewMetaDataMiddleware(appKeyGetter AppKeyGetter) MetaDataMiddleware {
-	return MetaDataMiddleware{appKeyGetter}
+func NewMetaDataMiddleware(appConfigGetter AppConfigGetter) MetaDataMiddleware {
+	return MetaDataMiddleware{appConfigGetter}
 }
 
 func (m MetaDataMiddleware) Middleware() gin.HandlerFunc {
@@ -43,6 +58,7 @@ func (m MetaDataMiddleware) Middleware() gin.HandlerFunc {
 		timestampS := c.Query("timestamp")
 		timestamp, err := strconv.ParseInt(timestampS, 10, 64)
 		if err != nil {
+			SetRejectReason(c, RejectReasonBadTimestamp)
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
@@ -55,8 +71,26 @@ func (m MetaDataMiddleware) Middleware() gin.HandlerFunc {
 			Version:   c.Query("version"),
 			UserId:    c.Query("userId"),
 			Timestamp: timestamp,
+
+			OSVersion:    c.Query("osVersion"),
+			DeviceModel:  c.Query("deviceModel"),
+			Manufacturer: c.Query("manufacturer"),
+			Locale:       c.Query("locale"),
+			NetworkType:  c.Query("networkType"),
+			Carrier:      c.Query("carrier"),
+			Resolution:   c.Query("resolution"),
+			Referrer:     c.Query("referrer"),
+
+			ServerTimestamp: utils.NowTimestamp(),
+			ClientIP:        c.ClientIP(),
+		}
+		if !m.verifySignature(c, data) {
+			SetRejectReason(c, RejectReasonBadSignature)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
 		}
-		if !m.validateMetaData(data, c.Query("sign")) {
+		if reason := m.validateMetaData(data); reason != "" {
+			SetRejectReason(c, reason)
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
@@ -65,49 +99,98 @@ func (m MetaDataMiddleware) Middleware() gin.HandlerFunc {
 	}
 }
 
-func (m MetaDataMiddleware) validateMetaData(data *MetaData, sign string) bool {
+// verifySignature checks the request signature. Apps that enforce signatures only accept
+// version 2 signatures, regardless of debug mode. Otherwise signatures are not checked in
+// debug mode, and either the legacy md5 or the version 2 signature is accepted.
+func (m MetaDataMiddleware) verifySignature(c *gin.Context, data *MetaData) bool {
 	logEntry := log.WithFields(log.Fields{"metadata": data})
-	// do not check sign when debug
-	if !conf.IsDebug() {
-		key, err := m.appKeyGetter.GetAppKey(data.AppId)
-		if err != nil {
-			logEntry.Debug("GetAppKey failed: ", err.Error())
+	config, err := m.appConfigGetter.GetAppConfig(data.AppId)
+	if err != nil {
+		logEntry.Debug("GetAppConfig failed: ", err.Error())
+		// do not check sign when debug
+		return conf.IsDebug()
+	}
+
+	if !config.EnforceSignature && conf.IsDebug() {
+		return true
+	}
+
+	sign := c.Query(signKey)
+	if c.Query(signVersionKey) != SignVersion2 {
+		if config.EnforceSignature {
+			logEntry.Debug("version 2 sign required")
 			return false
 		}
-
-		s := fmt.Sprintf("appId=%s&channel=%s&deviceId=%s&platform=%s&timestamp=%d&version=%s&key=%s",
-			data.AppId, data.Channel, data.DeviceId, data.Platform, data.Timestamp, data.Version, key)
-		hash := md5.Sum([]byte(s))
-		if sign != hex.EncodeToString(hash[:]) {
+		if sign != signV1(data, config.AppKey) {
 			logEntry.Debug("sign mismatch")
 			return false
 		}
+		return true
 	}
 
-	if data.AppId == "" {
+	key, ok := config.SigningKeys[c.Query(keyIdKey)]
+	if !ok {
+		logEntry.Debug("signing key not found or inactive")
 		return false
 	}
 
-	if data.DeviceId == "" {
+	var body []byte
+	if c.Request.Body != nil {
+		body, err = ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			logEntry.Debug("read body failed: ", err.Error())
+			return false
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if !verifySignV2(key, sign, c.Request.Method, c.Request.URL.Path, c.Request.URL.Query(), body) {
+		logEntry.Debug("sign mismatch")
 		return false
 	}
+	c.Set(signedKey, true)
+	return true
+}
+
+// validateMetaData returns the reason the metadata is rejected, empty when it is valid
+func (m MetaDataMiddleware) validateMetaData(data *MetaData) string {
+	if data.AppId == "" {
+		return RejectReasonMissingAppId
+	}
+
+	if data.DeviceId == "" {
+		return RejectReasonMissingDeviceId
+	}
 
 	if data.Channel == "" {
-		return false
+		return RejectReasonMissingChannel
 	}
 
-	platform := strings.ToLower(data.Platform)
-	if platform != "ios" && platform != "android" {
-		return false
+	platform := NormalizePlatform(data.Platform)
+	if !isPlatformAccepted(platform, platformsOf(m.appConfigGetter, data.AppId)) {
+		return RejectReasonUnknownPlatform
 	}
 	data.Platform = platform
 
 	if data.Version == "" {
-		return false
+		return RejectReasonMissingVersion
 	}
 
 	data.DateTimestamp = utils.TimestampToDate(data.Timestamp).Unix()
-	return true
+	return ""
+}
+
+// deviceAttributes returns the optional device attributes by query parameter name
+func (data *MetaData) deviceAttributes() [][2]string {
+	return [][2]string{
+		{"osVersion", data.OSVersion},
+		{"deviceModel", data.DeviceModel},
+		{"manufacturer", data.Manufacturer},
+		{"locale", data.Locale},
+		{"networkType", data.NetworkType},
+		{"carrier", data.Carrier},
+		{"resolution", data.Resolution},
+	}
 }
 
 func GetMetaData(c *gin.Context) (*MetaData, bool) {
@@ -122,3 +205,9 @@ func GetMetaData(c *gin.Context) (*MetaData, bool) {
 	}
 	return data, true
 }
+
+// IsSigned reports whether the request carries a verified version 2 signature, which also covers
+// the nonce and sendTimestamp parameters
+func IsSigned(c *gin.Context) bool {
+	return c.GetBool(signedKey)
+}
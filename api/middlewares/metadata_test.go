@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/conf"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
@@ -14,7 +16,10 @@ import (
 	"time"
 )
 
-type mockAppkeyGetter struct {}
+type mockAppConfigGetter struct {
+	enforceSignature bool
+	platforms        []string
+}
 
 var (
 	appId = "testAppId"
@@ -24,13 +29,24 @@ var (
 	platform = "android"
 	version = "0.1.0"
 	timestamp = time.Now().Unix()
+	keyId = "k1"
+	signingKey = "testSigningKey"
 )
 
-func (m mockAppkeyGetter) GetAppKey(appId string) (string, error) {
-	if appId == appId {
-		return appKey, nil
+func init() {
+	viper.Set(conf.DebugConfKey, false)
+}
+
+func (m mockAppConfigGetter) GetAppConfig(id string) (AppConfig, error) {
+	if id == appId {
+		return AppConfig{
+			AppKey:           appKey,
+			SigningKeys:      map[string]string{keyId: signingKey},
+			EnforceSignature: m.enforceSignature,
+			Platforms:        m.platforms,
+		}, nil
 	}
-	return "", errors.New("appId not exist")
+	return AppConfig{}, errors.New("appId not exist")
 }
 
 func queryString() string {
@@ -45,7 +61,7 @@ func queryString() string {
 }
 
 func TestMetaDataMiddleware(t *testing.T) {
-	middleware := NewMetaDataMiddleware(mockAppkeyGetter{})
+	middleware := NewMetaDataMiddleware(mockAppConfigGetter{})
 
 	router := gin.Default()
 	router.GET("/hello", middleware.Middleware(), func(c *gin.Context) {
@@ -74,4 +90,35 @@ func TestMetaDataMiddleware(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	require.Equal(t, http.StatusBadRequest, w.Code)
-}
\ No newline at end of file
+}
+func TestMetaDataMiddleware_DeviceAttributes(t *testing.T) {
+	middleware := NewMetaDataMiddleware(mockAppConfigGetter{})
+
+	var result *MetaData
+	router := gin.Default()
+	router.GET("/hello", middleware.Middleware(), func(c *gin.Context) {
+		result, _ = GetMetaData(c)
+	})
+
+	qs := fmt.Sprintf("appId=%s&channel=%s&deviceId=%s&platform=%s&timestamp=%d&version=%s",
+		appId, channel, deviceId, platform, timestamp, version)
+	attributes := "&osVersion=9.0&deviceModel=MI 8&carrier=CMCC"
+	hash := md5.Sum([]byte(qs + attributes + "&key=" + appKey))
+	qs += "&osVersion=9.0&deviceModel=MI+8&carrier=CMCC&sign=" + hex.EncodeToString(hash[:])
+
+	req := httptest.NewRequest(http.MethodGet, "/hello?"+qs, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "9.0", result.OSVersion)
+	require.Equal(t, "MI 8", result.DeviceModel)
+	require.Equal(t, "CMCC", result.Carrier)
+	require.Equal(t, "", result.Locale)
+
+	// attributes are signed
+	fakeQs := strings.Replace(qs, "CMCC", "CUCC", 1)
+	req = httptest.NewRequest(http.MethodGet, "/hello?"+fakeQs, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"github.com/lt90s/goanalytics/conf"
+	"strings"
+)
+
+// platformAliases maps the platform names reported by sdks and browsers to the platform
+// names used in counters, keys are lower case
+var platformAliases = map[string]string{
+	"ipados":    "ios",
+	"iphoneos":  "ios",
+	"iphone os": "ios",
+	"macosx":    "macos",
+	"mac os x":  "macos",
+	"osx":       "macos",
+	"darwin":    "macos",
+	"win":       "windows",
+	"win32":     "windows",
+	"browser":   "web",
+	"h5":        "web",
+	"wechat":    "miniprogram",
+	"weapp":     "miniprogram",
+	"wxapp":     "miniprogram",
+}
+
+// NormalizePlatform lower cases the platform and resolves aliases, e.g. "iPadOS" -> "ios"
+func NormalizePlatform(platform string) string {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	if alias, ok := platformAliases[platform]; ok {
+		return alias
+	}
+	return platform
+}
+
+// platformsOf returns the platforms accepted for the app, the configured default when the app
+// does not specify any
+func platformsOf(appConfigGetter AppConfigGetter, appId string) []string {
+	config, err := appConfigGetter.GetAppConfig(appId)
+	if err == nil && len(config.Platforms) > 0 {
+		return config.Platforms
+	}
+	return conf.GetConfStringSlice(conf.PlatformsConfKey)
+}
+
+func isPlatformAccepted(platform string, platforms []string) bool {
+	for _, p := range platforms {
+		if platform == NormalizePlatform(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizePlatform(t *testing.T) {
+	require.Equal(t, "ios", NormalizePlatform("iPadOS"))
+	require.Equal(t, "android", NormalizePlatform("Android"))
+	require.Equal(t, "web", NormalizePlatform("web"))
+	require.Equal(t, "miniprogram", NormalizePlatform("WeChat"))
+}
+
+func TestMetaDataMiddleware_Platforms(t *testing.T) {
+	run := func(getter mockAppConfigGetter, platform string) (int, *MetaData) {
+		var result *MetaData
+		router := gin.Default()
+		router.GET("/hello", NewMetaDataMiddleware(getter).Middleware(), func(c *gin.Context) {
+			result, _ = GetMetaData(c)
+		})
+		qs := fmt.Sprintf("appId=%s&channel=%s&deviceId=%s&platform=%s&timestamp=%d&version=%s",
+			appId, channel, deviceId, platform, timestamp, version)
+		hash := md5.Sum([]byte(qs + "&key=" + appKey))
+		req := httptest.NewRequest(http.MethodGet, "/hello?"+qs+"&sign="+hex.EncodeToString(hash[:]), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, result
+	}
+
+	// default platforms
+	code, data := run(mockAppConfigGetter{}, "iPadOS")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ios", data.Platform)
+
+	code, _ = run(mockAppConfigGetter{}, "web")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// app platforms
+	getter := mockAppConfigGetter{platforms: []string{"web", "miniprogram"}}
+	code, data = run(getter, "web")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "web", data.Platform)
+
+	code, data = run(getter, "weapp")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "miniprogram", data.Platform)
+
+	code, _ = run(getter, "android")
+	require.Equal(t, http.StatusBadRequest, code)
+}
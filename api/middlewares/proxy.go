@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxyMiddleware resolves the client ip through the trusted proxies only, so that a
+// client cannot spoof its ip, e.g. for geoip and attribution, by setting X-Forwarded-For.
+// gin's ClientIP trusts the first X-Forwarded-For hop, the resolved ip replaces the forwarding
+// headers so that ClientIP returns it. It must be installed on the engine before the routes.
+type TrustedProxyMiddleware struct {
+	proxies []*net.IPNet
+}
+
+// NewTrustedProxyMiddleware creates a TrustedProxyMiddleware from ips and CIDRs, with no proxies
+// the remote address is the client ip
+func NewTrustedProxyMiddleware(proxies []string) (TrustedProxyMiddleware, error) {
+	m := TrustedProxyMiddleware{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return m, err
+		}
+		m.proxies = append(m.proxies, ipNet)
+	}
+	return m, nil
+}
+
+func (m TrustedProxyMiddleware) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range m.proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP walks X-Forwarded-For from the remote address backwards while the hops are trusted
+func (m TrustedProxyMiddleware) clientIP(r *http.Request) string {
+	addr, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		addr = strings.TrimSpace(r.RemoteAddr)
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0 && m.trusted(addr); i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		addr = hop
+	}
+	return addr
+}
+
+func (m TrustedProxyMiddleware) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Header.Set("X-Forwarded-For", m.clientIP(c.Request))
+		c.Request.Header.Del("X-Real-Ip")
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxyMiddleware(t *testing.T) {
+	_, err := NewTrustedProxyMiddleware([]string{"foo"})
+	require.Error(t, err)
+
+	run := func(proxies []string, remoteAddr, forwardedFor string) string {
+		m, err := NewTrustedProxyMiddleware(proxies)
+		require.NoError(t, err)
+		var clientIP string
+		router := gin.New()
+		router.Use(m.Middleware())
+		router.GET("/hello", func(c *gin.Context) {
+			clientIP = c.ClientIP()
+		})
+		req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		req.Header.Set("X-Real-Ip", "9.9.9.9")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return clientIP
+	}
+
+	// headers of untrusted remote addresses are ignored
+	require.Equal(t, "1.2.3.4", run(nil, "1.2.3.4:1234", "5.6.7.8"))
+	require.Equal(t, "1.2.3.4", run([]string{"10.0.0.0/8"}, "1.2.3.4:1234", "5.6.7.8"))
+	require.Equal(t, "10.0.0.1", run([]string{"10.0.0.0/8"}, "10.0.0.1:1234", ""))
+
+	// hops are trusted up to the first untrusted one, a spoofed first hop is skipped
+	require.Equal(t, "5.6.7.8", run([]string{"10.0.0.0/8"}, "10.0.0.1:1234", "6.6.6.6, 5.6.7.8"))
+	require.Equal(t, "5.6.7.8", run([]string{"10.0.0.0/8", "192.168.1.1"}, "10.0.0.1:1234",
+		"6.6.6.6, 5.6.7.8, 192.168.1.1"))
+	require.Equal(t, "10.0.0.2", run([]string{"10.0.0.0/8"}, "10.0.0.1:1234", "foo, 10.0.0.2"))
+}
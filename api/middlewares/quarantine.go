@@ -0,0 +1,141 @@
+package middlewares
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// bodies are truncated to this size in the quarantine
+const maxQuarantineBodySize = 64 * 1024
+
+type quarantineAdder interface {
+	Add(appId string, entry storage.QuarantineEntry) error
+}
+
+// SampledQuarantine adds sampleRate of the entries to the quarantine and at most appRate entries
+// per second per app, so that a flood of rejected requests does not turn into a write flood.
+// Dropped entries are not an error. Entries must be of known apps, their buckets are not swept.
+type SampledQuarantine struct {
+	sampleRate float64
+	appRate    float64
+	appBurst   int
+	quarantine quarantineAdder
+	appBuckets *tokenBuckets
+}
+
+// NewSampledQuarantine creates a SampledQuarantine, a zero sampleRate drops every entry and a
+// zero appRate means no limit
+func NewSampledQuarantine(sampleRate, appRate float64, appBurst int, quarantine quarantineAdder) SampledQuarantine {
+	return SampledQuarantine{
+		sampleRate: sampleRate,
+		appRate:    appRate,
+		appBurst:   appBurst,
+		quarantine: quarantine,
+		appBuckets: newTokenBuckets(),
+	}
+}
+
+func (q SampledQuarantine) Add(appId string, entry storage.QuarantineEntry) error {
+	if rand.Float64() >= q.sampleRate || !q.appBuckets.allow(appId, time.Now(), q.appRate, q.appBurst) {
+		return nil
+	}
+	return q.quarantine.Add(appId, entry)
+}
+
+// QuarantineMiddleware keeps a sampled copy of the ingestion requests rejected with 400, either
+// aborted by a middleware or failed in a handler, so that SDK integrators can see why their
+// requests are rejected. It must be installed before MetaDataMiddleware.
+//
+// Requests of unknown apps are not kept, the reason is the one set by SetRejectReason or
+// RejectReasonBadRequest.
+type QuarantineMiddleware struct {
+	quarantine      SampledQuarantine
+	appConfigGetter AppConfigGetter
+}
+
+// NewQuarantineMiddleware creates a QuarantineMiddleware, it is disabled when the sample rate
+// of the quarantine is 0
+func NewQuarantineMiddleware(quarantine SampledQuarantine, appConfigGetter AppConfigGetter) QuarantineMiddleware {
+	return QuarantineMiddleware{
+		quarantine:      quarantine,
+		appConfigGetter: appConfigGetter,
+	}
+}
+
+func (m QuarantineMiddleware) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m.quarantine.sampleRate <= 0 {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(c.Request.Body)
+			if err != nil {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		c.Next()
+
+		details, rejected := rejectionOf(c)
+		if !rejected {
+			return
+		}
+		appId := c.Query("appId")
+		if appId == "" {
+			return
+		}
+		if _, err := m.appConfigGetter.GetAppConfig(appId); err != nil {
+			return
+		}
+
+		reason := c.GetString(rejectReasonKey)
+		if reason == "" {
+			reason = RejectReasonBadRequest
+		}
+		if len(body) > maxQuarantineBodySize {
+			body = body[:maxQuarantineBodySize]
+		}
+		err := m.quarantine.Add(appId, storage.QuarantineEntry{
+			Reason:    reason,
+			Details:   details,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Query:     c.Request.URL.RawQuery,
+			Body:      string(body),
+			Timestamp: utils.NowTimestamp(),
+		})
+		if err != nil {
+			log.WithFields(log.Fields{"appId": appId, "reason": reason, "error": err.Error()}).Warn("[QuarantineMiddleware] add error")
+		}
+	}
+}
+
+// rejectionOf reports whether the request is rejected with 400, the error set by a handler
+// is only written by ResponseMiddleware after this middleware returns
+func rejectionOf(c *gin.Context) (details []string, rejected bool) {
+	if c.Writer.Status() == http.StatusBadRequest {
+		return nil, true
+	}
+	value, ok := c.Get("error")
+	if !ok {
+		return nil, false
+	}
+	err, ok := value.(utils.HttpError)
+	if !ok || err.HttpCode != http.StatusBadRequest {
+		return nil, false
+	}
+	return []string{err.Msg}, true
+}
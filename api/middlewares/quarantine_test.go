@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type mockQuarantine struct {
+	mutex   sync.Mutex
+	entries []storage.QuarantineEntry
+}
+
+func (m *mockQuarantine) Add(appId string, entry storage.QuarantineEntry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func TestQuarantineMiddleware(t *testing.T) {
+	getter := mockAppConfigGetter{}
+	quarantine := &mockQuarantine{}
+	metadata := NewMetaDataMiddleware(getter)
+
+	router := gin.Default()
+	router.Use(ResponseMiddleware)
+	group := router.Group("/i", NewQuarantineMiddleware(NewSampledQuarantine(1.0, 0.001, 4, quarantine), getter).Middleware(), metadata.Middleware())
+	group.POST("/hello", func(c *gin.Context) {
+		var data struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&data); err != nil {
+			c.Set("error", utils.ParamError)
+		}
+	})
+
+	request := func(query, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/i/hello?"+query, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	qs := queryString()
+	require.Equal(t, http.StatusOK, request(qs, `{"name":"foo"}`))
+	require.Len(t, quarantine.entries, 0)
+
+	unknownPlatform := fmt.Sprintf("appId=%s&channel=%s&deviceId=%s&platform=%s&timestamp=%d&version=%s",
+		appId, channel, deviceId, "web", timestamp, version)
+	hash := md5.Sum([]byte(unknownPlatform + "&key=" + appKey))
+	unknownPlatform += "&sign=" + hex.EncodeToString(hash[:])
+	require.Equal(t, http.StatusBadRequest, request(unknownPlatform, `{}`))
+	require.Equal(t, http.StatusBadRequest, request(qs+"&resolution=1x1", `{}`))
+	require.Equal(t, http.StatusBadRequest, request(qs, `{"foo":1}`))
+	require.Len(t, quarantine.entries, 3)
+	require.Equal(t, RejectReasonUnknownPlatform, quarantine.entries[0].Reason)
+	require.Equal(t, RejectReasonBadSignature, quarantine.entries[1].Reason)
+	require.Equal(t, RejectReasonBadRequest, quarantine.entries[2].Reason)
+	require.Equal(t, []string{utils.ParamError.Msg}, quarantine.entries[2].Details)
+	require.Equal(t, `{"foo":1}`, quarantine.entries[2].Body)
+	require.Equal(t, "/i/hello", quarantine.entries[2].Path)
+
+	// requests of unknown apps are not kept
+	require.Equal(t, http.StatusBadRequest, request(strings.Replace(qs, appId, "foo", 1), `{}`))
+	require.Len(t, quarantine.entries, 3)
+
+	// the writes of the app are limited
+	require.Equal(t, http.StatusBadRequest, request(qs, `{"foo":2}`))
+	require.Equal(t, http.StatusBadRequest, request(qs, `{"foo":3}`))
+	require.Len(t, quarantine.entries, 4)
+	require.Equal(t, `{"foo":2}`, quarantine.entries[3].Body)
+}
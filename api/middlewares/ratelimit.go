@@ -0,0 +1,177 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	RejectReasonAppRateLimited    = "app_rate_limited"
+	RejectReasonDeviceRateLimited = "device_rate_limited"
+
+	// buckets idle longer than this are full again and can be dropped
+	bucketIdleTimeout    = 10 * time.Minute
+	droppedFlushInterval = 10 * time.Second
+)
+
+// RateLimit is the token bucket setting of an app, rates are requests per second.
+// A zero value means the default setting, a zero default means no limit.
+type RateLimit struct {
+	AppRate     float64
+	AppBurst    int
+	DeviceRate  float64
+	DeviceBurst int
+}
+
+type tokenBucket struct {
+	tokens   float64
+	lastTime time.Time
+}
+
+// allow refills the bucket for the time elapsed and takes a token if there is one
+func (b *tokenBucket) allow(now time.Time, rate float64, burst int) bool {
+	b.tokens += now.Sub(b.lastTime).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.lastTime = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type tokenBuckets struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newTokenBuckets() *tokenBuckets {
+	return &tokenBuckets{buckets: make(map[string]*tokenBucket)}
+}
+
+func (tb *tokenBuckets) allow(key string, now time.Time, rate float64, burst int) bool {
+	if rate <= 0 {
+		return true
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	bucket, ok := tb.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), lastTime: now}
+		tb.buckets[key] = bucket
+	}
+	return bucket.allow(now, rate, burst)
+}
+
+func (tb *tokenBuckets) sweep(now time.Time) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	for key, bucket := range tb.buckets {
+		if now.Sub(bucket.lastTime) > bucketIdleTimeout {
+			delete(tb.buckets, key)
+		}
+	}
+}
+
+// RateLimitMiddleware limits ingestion requests per app and per device with token buckets.
+// Limited requests get 429 and are counted in RejectedRequestSlotCounter. The counts are
+// aggregated in memory and flushed periodically so a flood does not turn into counter writes.
+// It must be installed after MetaDataMiddleware.
+type RateLimitMiddleware struct {
+	defaults        RateLimit
+	appConfigGetter AppConfigGetter
+	counter         slotCounterAdder
+	appBuckets      *tokenBuckets
+	deviceBuckets   *tokenBuckets
+	droppedMutex    *sync.Mutex
+	dropped         map[[2]string]float64
+}
+
+func NewRateLimitMiddleware(defaults RateLimit, appConfigGetter AppConfigGetter, counter slotCounterAdder) RateLimitMiddleware {
+	m := RateLimitMiddleware{
+		defaults:        defaults,
+		appConfigGetter: appConfigGetter,
+		counter:         counter,
+		appBuckets:      newTokenBuckets(),
+		deviceBuckets:   newTokenBuckets(),
+		droppedMutex:    &sync.Mutex{},
+		dropped:         make(map[[2]string]float64),
+	}
+	go m.run()
+	return m
+}
+
+func (m RateLimitMiddleware) limitOf(appId string) RateLimit {
+	limit := m.defaults
+	config, err := m.appConfigGetter.GetAppConfig(appId)
+	if err != nil {
+		return limit
+	}
+	if config.RateLimit.AppRate > 0 {
+		limit.AppRate, limit.AppBurst = config.RateLimit.AppRate, config.RateLimit.AppBurst
+	}
+	if config.RateLimit.DeviceRate > 0 {
+		limit.DeviceRate, limit.DeviceBurst = config.RateLimit.DeviceRate, config.RateLimit.DeviceBurst
+	}
+	return limit
+}
+
+func (m RateLimitMiddleware) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, ok := GetMetaData(c)
+		if !ok {
+			log.Error("[RateLimitMiddleware] MetaData missing")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		limit := m.limitOf(data.AppId)
+		if !m.deviceBuckets.allow(data.AppId+"/"+data.DeviceId, now, limit.DeviceRate, limit.DeviceBurst) {
+			m.drop(c, data, RejectReasonDeviceRateLimited)
+			return
+		}
+		if !m.appBuckets.allow(data.AppId, now, limit.AppRate, limit.AppBurst) {
+			m.drop(c, data, RejectReasonAppRateLimited)
+			return
+		}
+		c.Next()
+	}
+}
+
+func (m RateLimitMiddleware) drop(c *gin.Context, data *MetaData, reason string) {
+	m.droppedMutex.Lock()
+	m.dropped[[2]string{data.AppId, reason}]++
+	m.droppedMutex.Unlock()
+	c.AbortWithStatus(http.StatusTooManyRequests)
+}
+
+func (m RateLimitMiddleware) flushDropped() {
+	m.droppedMutex.Lock()
+	dropped := m.dropped
+	m.dropped = make(map[[2]string]float64)
+	m.droppedMutex.Unlock()
+
+	for key, count := range dropped {
+		recordRejections(m.counter, key[0], key[1], count)
+	}
+}
+
+func (m RateLimitMiddleware) run() {
+	for {
+		time.Sleep(droppedFlushInterval)
+		m.flushDropped()
+		now := time.Now()
+		m.appBuckets.sweep(now)
+		m.deviceBuckets.sweep(now)
+	}
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBuckets(t *testing.T) {
+	buckets := newTokenBuckets()
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		require.True(t, buckets.allow("foo", now, 1, 3))
+	}
+	require.False(t, buckets.allow("foo", now, 1, 3))
+	require.True(t, buckets.allow("bar", now, 1, 3))
+
+	now = now.Add(time.Second)
+	require.True(t, buckets.allow("foo", now, 1, 3))
+	require.False(t, buckets.allow("foo", now, 1, 3))
+
+	// no limit
+	for i := 0; i < 10; i++ {
+		require.True(t, buckets.allow("baz", now, 0, 0))
+	}
+
+	buckets.sweep(now.Add(bucketIdleTimeout + time.Second))
+	require.Len(t, buckets.buckets, 0)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	counter := newMockSlotCounter()
+	getter := mockAppConfigGetter{}
+	metadata := NewMetaDataMiddleware(getter)
+	rateLimit := NewRateLimitMiddleware(RateLimit{DeviceRate: 0.001, DeviceBurst: 2}, getter, counter)
+
+	router := gin.Default()
+	router.GET("/hello", metadata.Middleware(), rateLimit.Middleware(), func(c *gin.Context) {
+		c.Writer.WriteString("hello")
+	})
+
+	qs := queryString()
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/hello?"+qs, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	require.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+
+	rateLimit.flushDropped()
+	require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonDeviceRateLimited))
+}
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// RejectedRequestSlotCounter counts the ingestion requests rejected per day, slotted by reason
+const RejectedRequestSlotCounter = "RejectedRequestSlotCounter"
+
+const (
+	RejectReasonStaleTimestamp = "stale_timestamp"
+	RejectReasonMissingNonce   = "missing_nonce"
+	RejectReasonDuplicateNonce = "duplicate_nonce"
+	RejectReasonClockSkew      = "clock_skew"
+	// duplicates are answered with success
+	RejectReasonDuplicateEvent = "duplicate_event"
+
+	RejectReasonBadTimestamp    = "bad_timestamp"
+	RejectReasonBadSignature    = "bad_signature"
+	RejectReasonMissingAppId    = "missing_app_id"
+	RejectReasonMissingDeviceId = "missing_device_id"
+	RejectReasonMissingChannel  = "missing_channel"
+	RejectReasonUnknownPlatform = "unknown_platform"
+	RejectReasonMissingVersion  = "missing_version"
+	// requests rejected without a more specific reason, e.g. a body that fails to decode
+	RejectReasonBadRequest = "bad_request"
+)
+
+const rejectReasonKey = "_rejectReason"
+
+type slotCounterAdder interface {
+	AddSlotCounter(appId string, counterName, slotName string, dateTimestamp int64, amount float64) error
+}
+
+// recordRejection counts a rejected request on the server date, client timestamps are not trusted here
+func recordRejection(counter slotCounterAdder, appId, reason string) {
+	recordRejections(counter, appId, reason, 1.0)
+}
+
+func recordRejections(counter slotCounterAdder, appId, reason string, count float64) {
+	err := counter.AddSlotCounter(appId, RejectedRequestSlotCounter, reason, utils.TodayTimestamp(), count)
+	if err != nil {
+		log.WithFields(log.Fields{"appId": appId, "reason": reason, "error": err.Error()}).Warn("record rejection error")
+	}
+}
+
+// SetRejectReason tells QuarantineMiddleware why the request is rejected
+func SetRejectReason(c *gin.Context, reason string) {
+	c.Set(rejectReasonKey, reason)
+}
@@ -0,0 +1,130 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	nonceKey         = "nonce"
+	sendTimestampKey = "sendTimestamp"
+
+	// nonces are remembered this long when the acceptance window is disabled
+	defaultNonceTTL = 24 * time.Hour
+)
+
+// ReplayMiddleware rejects requests sent outside of the acceptance window and requests whose
+// nonce has been used before. It must be installed after MetaDataMiddleware.
+//
+// The window is checked against the optional sendTimestamp parameter, falling back to the
+// event timestamp, so that SDKs uploading cached events can still report their original time.
+// The clock skew policy does not widen the window, a device whose clock is off by more than the
+// window is rejected.
+// The nonce and sendTimestamp parameters are only honored on requests with a version 2
+// signature, the only one covering them. Apps that enforce signatures must send a nonce with
+// every request.
+type ReplayMiddleware struct {
+	window          time.Duration
+	nonces          storage.SeenSet
+	appConfigGetter AppConfigGetter
+	counter         slotCounterAdder
+}
+
+// NewReplayMiddleware creates a ReplayMiddleware, a zero window disables the timestamp check
+func NewReplayMiddleware(window time.Duration, nonces storage.SeenSet, appConfigGetter AppConfigGetter,
+	counter slotCounterAdder) ReplayMiddleware {
+	return ReplayMiddleware{
+		window:          window,
+		nonces:          nonces,
+		appConfigGetter: appConfigGetter,
+		counter:         counter,
+	}
+}
+
+func (m ReplayMiddleware) nonceTTL() time.Duration {
+	if m.window == 0 {
+		return defaultNonceTTL
+	}
+	// a nonce has to be remembered as long as its timestamp is acceptable
+	return 2 * m.window
+}
+
+func (m ReplayMiddleware) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, ok := GetMetaData(c)
+		if !ok {
+			log.Error("[ReplayMiddleware] MetaData missing")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		sendTimestamp, ok, err := signedSendTimestamp(c)
+		if err != nil {
+			SetRejectReason(c, RejectReasonBadTimestamp)
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			// the event timestamp as sent, data.Timestamp may be corrected by ClockSkewMiddleware
+			sendTimestamp, _ = strconv.ParseInt(c.Query("timestamp"), 10, 64)
+		}
+
+		if m.window > 0 {
+			delta := time.Duration(utils.NowTimestamp()-sendTimestamp) * time.Second
+			if delta > m.window || delta < -m.window {
+				m.reject(c, data, RejectReasonStaleTimestamp)
+				return
+			}
+		}
+
+		nonce := ""
+		if IsSigned(c) {
+			nonce = c.Query(nonceKey)
+		}
+		if nonce == "" {
+			config, err := m.appConfigGetter.GetAppConfig(data.AppId)
+			if err == nil && config.EnforceSignature {
+				m.reject(c, data, RejectReasonMissingNonce)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		seen, err := m.nonces.Seen(data.AppId, nonce, m.nonceTTL())
+		if err != nil {
+			// let the request through rather than losing data when the nonce store is unavailable
+			log.WithFields(log.Fields{"metadata": data, "error": err.Error()}).Warn("[ReplayMiddleware] nonce check error")
+		} else if seen {
+			m.reject(c, data, RejectReasonDuplicateNonce)
+			return
+		}
+		c.Next()
+	}
+}
+
+func (m ReplayMiddleware) reject(c *gin.Context, data *MetaData, reason string) {
+	log.WithFields(log.Fields{"metadata": data, "reason": reason}).Debug("[ReplayMiddleware] request rejected")
+	recordRejection(m.counter, data.AppId, reason)
+	SetRejectReason(c, reason)
+	c.AbortWithStatus(http.StatusBadRequest)
+}
+
+// signedSendTimestamp returns the sendTimestamp parameter of signed requests, it is ignored on
+// unsigned requests since it could be forged to pass the acceptance window
+func signedSendTimestamp(c *gin.Context) (int64, bool, error) {
+	s := c.Query(sendTimestampKey)
+	if s == "" || !IsSigned(c) {
+		return 0, false, nil
+	}
+	sendTimestamp, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return sendTimestamp, true, nil
+}
@@ -0,0 +1,154 @@
+package middlewares
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/storage/memory"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockSlotCounter struct {
+	mutex    sync.Mutex
+	counters map[string]float64
+}
+
+func newMockSlotCounter() *mockSlotCounter {
+	return &mockSlotCounter{counters: make(map[string]float64)}
+}
+
+func (m *mockSlotCounter) AddSlotCounter(appId string, counterName, slotName string, dateTimestamp int64, amount float64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.counters[counterName+"/"+slotName] += amount
+	return nil
+}
+
+func (m *mockSlotCounter) get(counterName, slotName string) float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.counters[counterName+"/"+slotName]
+}
+
+func TestReplayMiddleware(t *testing.T) {
+	counter := newMockSlotCounter()
+	getter := mockAppConfigGetter{}
+	metadata := NewMetaDataMiddleware(getter)
+	replay := NewReplayMiddleware(time.Hour, memory.NewSeenSet(0), getter, counter)
+
+	router := gin.Default()
+	router.GET("/hello", metadata.Middleware(), replay.Middleware(), func(c *gin.Context) {
+		c.Writer.WriteString("hello")
+	})
+
+	request := func(req *http.Request) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	signed := func(params url.Values) *http.Request {
+		return signedV2Request(http.MethodGet, "", signingKey, params)
+	}
+
+	require.Equal(t, http.StatusOK, request(signed(url.Values{nonceKey: {"a"}})))
+	require.Equal(t, http.StatusBadRequest, request(signed(url.Values{nonceKey: {"a"}})))
+	require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonDuplicateNonce))
+	require.Equal(t, http.StatusOK, request(signed(url.Values{nonceKey: {"b"}})))
+	// nonce is optional unless signatures are enforced
+	require.Equal(t, http.StatusOK, request(signed(nil)))
+
+	stale := fmt.Sprintf("%d", time.Now().Add(-2*time.Hour).Unix())
+	require.Equal(t, http.StatusBadRequest, request(signed(url.Values{nonceKey: {"c"}, sendTimestampKey: {stale}})))
+	require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonStaleTimestamp))
+
+	// nonce and sendTimestamp are not covered by the legacy signature and are ignored
+	qs := queryString()
+	require.Equal(t, http.StatusOK, request(httptest.NewRequest(http.MethodGet, "/hello?"+qs+"&nonce=d", nil)))
+	require.Equal(t, http.StatusOK, request(httptest.NewRequest(http.MethodGet, "/hello?"+qs+"&nonce=d", nil)))
+	require.Equal(t, http.StatusOK, request(httptest.NewRequest(http.MethodGet, "/hello?"+qs+"&sendTimestamp="+stale, nil)))
+}
+
+func TestReplayMiddleware_EnforceSignature(t *testing.T) {
+	counter := newMockSlotCounter()
+	getter := mockAppConfigGetter{enforceSignature: true}
+	metadata := NewMetaDataMiddleware(getter)
+	replay := NewReplayMiddleware(time.Hour, memory.NewSeenSet(0), getter, counter)
+
+	router := gin.Default()
+	router.GET("/hello", metadata.Middleware(), replay.Middleware(), func(c *gin.Context) {
+		c.Writer.WriteString("hello")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedV2Request(http.MethodGet, "", signingKey, nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonMissingNonce))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedV2Request(http.MethodGet, "", signingKey, url.Values{nonceKey: {"a"}}))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestReplayMiddleware_ClockSkew(t *testing.T) {
+	getter := mockAppConfigGetter{}
+	metadata := NewMetaDataMiddleware(getter)
+
+	run := func(policy string, req *http.Request) (int, *mockSlotCounter, int64) {
+		counter := newMockSlotCounter()
+		clockSkew := NewClockSkewMiddleware(ClockSkew{Policy: policy, Threshold: 600}, getter, counter)
+		replay := NewReplayMiddleware(time.Hour, memory.NewSeenSet(0), getter, counter)
+		var eventTimestamp int64
+		router := gin.Default()
+		router.GET("/hello", metadata.Middleware(), clockSkew.Middleware(), replay.Middleware(), func(c *gin.Context) {
+			data, _ := GetMetaData(c)
+			eventTimestamp = data.Timestamp
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, counter, eventTimestamp
+	}
+	signed := func(sendTimestamp time.Time) *http.Request {
+		return signedV2Request(http.MethodGet, "", signingKey, url.Values{
+			nonceKey:         {"a"},
+			sendTimestampKey: {fmt.Sprintf("%d", sendTimestamp.Unix())},
+		})
+	}
+
+	// device clock is 20 minutes behind, skewed but inside of the replay window
+	behind := time.Now().Add(-20 * time.Minute)
+	code, _, eventTimestamp := run(ClockSkewPolicyTrust, signed(behind))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, timestamp, eventTimestamp)
+	code, _, eventTimestamp = run(ClockSkewPolicyClamp, signed(behind))
+	require.Equal(t, http.StatusOK, code)
+	require.InDelta(t, timestamp, eventTimestamp, 1)
+	code, counter, _ := run(ClockSkewPolicyReject, signed(behind))
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonClockSkew))
+
+	// device clock is two hours behind, the policy does not let it through the replay window
+	behind = time.Now().Add(-2 * time.Hour)
+	for _, policy := range []string{ClockSkewPolicyTrust, ClockSkewPolicyClamp} {
+		code, counter, _ = run(policy, signed(behind))
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonStaleTimestamp))
+	}
+
+	// unsigned requests are checked against their event timestamp
+	stale := fmt.Sprintf("appId=%s&channel=%s&deviceId=%s&platform=%s&timestamp=%d&version=%s",
+		appId, channel, deviceId, platform, time.Now().Add(-48*time.Hour).Unix(), version)
+	hash := md5.Sum([]byte(stale + "&key=" + appKey))
+	stale += "&sign=" + hex.EncodeToString(hash[:])
+	for _, policy := range []string{ClockSkewPolicyTrust, ClockSkewPolicyClamp} {
+		code, counter, _ = run(policy, httptest.NewRequest(http.MethodGet, "/hello?"+stale, nil))
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, 1.0, counter.get(RejectedRequestSlotCounter, RejectReasonStaleTimestamp))
+	}
+}
@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	signKey        = "sign"
+	signVersionKey = "signVersion"
+	keyIdKey       = "keyId"
+
+	SignVersion2 = "2"
+)
+
+// signV1 is the legacy md5 signature over a fixed set of query parameters and the app key.
+// Device attributes are signed only when present, so that signatures of sdks not reporting
+// them are unchanged.
+func signV1(data *MetaData, key string) string {
+	s := fmt.Sprintf("appId=%s&channel=%s&deviceId=%s&platform=%s&timestamp=%d&version=%s",
+		data.AppId, data.Channel, data.DeviceId, data.Platform, data.Timestamp, data.Version)
+	for _, attribute := range data.deviceAttributes() {
+		if attribute[1] != "" {
+			s += "&" + attribute[0] + "=" + attribute[1]
+		}
+	}
+	s += "&key=" + key
+	hash := md5.Sum([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+// CanonicalQuery encodes all query parameters except sign, sorted by key and value
+func CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if key == signKey {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// CanonicalRequest is the string signed by version 2 signatures:
+//
+//	METHOD \n PATH \n CANONICAL_QUERY \n HEX(SHA256(BODY))
+func CanonicalRequest(method, path string, query url.Values, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		CanonicalQuery(query),
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// SignV2 computes the version 2 signature, HMAC-SHA256 of the canonical request
+func SignV2(key, method, path string, query url.Values, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(CanonicalRequest(method, path, query, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifySignV2(key, sign, method, path string, query url.Values, body []byte) bool {
+	expected := SignV2(key, method, path, query, body)
+	return hmac.Equal([]byte(expected), []byte(sign))
+}
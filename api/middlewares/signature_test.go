@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// signedV2Request builds a version 2 signed request to /hello, params are added to the query
+func signedV2Request(method string, body string, key string, params url.Values) *http.Request {
+	query := url.Values{}
+	for name, values := range params {
+		query[name] = values
+	}
+	query.Set("appId", appId)
+	query.Set("channel", channel)
+	query.Set("deviceId", deviceId)
+	query.Set("platform", platform)
+	query.Set("timestamp", fmt.Sprintf("%d", timestamp))
+	query.Set("version", version)
+	query.Set("userId", "userId")
+	query.Set(signVersionKey, SignVersion2)
+	query.Set(keyIdKey, keyId)
+	query.Set(signKey, SignV2(key, method, "/hello", query, []byte(body)))
+	return httptest.NewRequest(method, "/hello?"+query.Encode(), bytes.NewBufferString(body))
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	query := url.Values{}
+	query.Set("b", "2")
+	query.Add("a", "y")
+	query.Add("a", "x")
+	query.Set("sign", "ignored")
+	require.Equal(t, "a=x&a=y&b=2", CanonicalQuery(query))
+}
+
+func TestMetaDataMiddleware_SignV2(t *testing.T) {
+	middleware := NewMetaDataMiddleware(mockAppConfigGetter{enforceSignature: true})
+
+	router := gin.Default()
+	router.POST("/hello", middleware.Middleware(), func(c *gin.Context) {
+		var data struct {
+			Foo string `json:"foo"`
+		}
+		require.NoError(t, c.ShouldBindJSON(&data))
+		require.Equal(t, "bar", data.Foo)
+		c.Writer.WriteString("hello")
+	})
+
+	body := `{"foo":"bar"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedV2Request(http.MethodPost, body, signingKey, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "hello", w.Body.String())
+
+	// wrong key
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedV2Request(http.MethodPost, body, "wrongKey", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// tampered body
+	req := signedV2Request(http.MethodPost, body, signingKey, nil)
+	req.Body = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"foo":"baz"}`)).Body
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// legacy signature is rejected when version 2 is enforced
+	req = httptest.NewRequest(http.MethodPost, "/hello?"+queryString(), bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -23,7 +23,19 @@ type counterDescriptorData struct {
 	Descriptors []counterDescriptor `json:"descriptors"`
 }
 
-func InstallCounterEndpoint(iRouter, oRouter *gin.RouterGroup, counter storage.Counter) {
+// segmentCounter counts counters again over the members of a segment or the devices attributed
+// to a campaign, see metric/segment
+type segmentCounter interface {
+	SegmentDateSum(appId, segmentId, counterName string, start, end int64) (map[int64]float64, error)
+	SegmentSlotSpan(appId, segmentId, counterName string, start, end int64) (storage.SlotCounters, error)
+	SegmentUniqueActive(appId, segmentId string, start, end int64) (float64, error)
+	SegmentMembers(appId, segmentId string) (map[string]bool, error)
+	CampaignDateSum(appId, campaignId, counterName string, start, end int64) (map[int64]float64, error)
+	CampaignSlotSpan(appId, campaignId, counterName string, start, end int64) (storage.SlotCounters, error)
+}
+
+func InstallCounterEndpoint(iRouter, oRouter *gin.RouterGroup, counter storage.Counter, segments segmentCounter,
+	schemas storage.SchemaRegistry, quarantine middlewares.SampledQuarantine, events storage.EventLog) {
 	oRouter.POST("/counter", func(c *gin.Context) {
 		var data counterDescriptorData
 		err := c.ShouldBindJSON(&data)
@@ -32,10 +44,14 @@ func InstallCounterEndpoint(iRouter, oRouter *gin.RouterGroup, counter storage.C
 			c.Set("error", err)
 			return
 		}
-		getCounters(c, data, counter)
+		getCounters(c, data, counter, segments)
 	})
 
 	oRouter.GET("/counter/trend", func(c *gin.Context) {
+		if segmentId := c.Query("segment"); segmentId != "" {
+			getSegmentTrendData(c, segments, segmentId)
+			return
+		}
 		getTrendData(c, counter)
 	})
 
@@ -43,7 +59,7 @@ func InstallCounterEndpoint(iRouter, oRouter *gin.RouterGroup, counter storage.C
 	oRouter.POST("/counter/customized", addCustomizedCounterHandler(counter))
 	oRouter.DELETE("/counter/customized", deleteCustomizedCounter(counter))
 
-	iRouter.POST("/counter/customized", customizedCounterHandler(counter))
+	iRouter.POST("/counter/customized", customizedCounterHandler(counter, eventValidator{schemas, quarantine, counter}, events))
 }
 
 func addCustomizedCounterHandler(counter storage.Counter) gin.HandlerFunc {
@@ -96,7 +112,8 @@ func deleteCustomizedCounter(counter storage.Counter) gin.HandlerFunc {
 	}
 }
 
-func customizedCounterHandler(counter storage.Counter) gin.HandlerFunc {
+// customizedCounterHandler counts the custom event and keeps it in the event log
+func customizedCounterHandler(counter storage.Counter, validator eventValidator, events storage.EventLog) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		metaData, ok := middlewares.GetMetaData(c)
 		if !ok {
@@ -104,16 +121,31 @@ func customizedCounterHandler(counter storage.Counter) gin.HandlerFunc {
 			return
 		}
 		var data struct {
-			Name   string  `json"name"`
+			Name   string  `json:"name"`
 			Type   string  `json:"type"`
 			Slot   string  `json:"slot"`
 			Amount float64 `json:"amount"`
+			// validated by the event schema only
+			Properties map[string]interface{} `json:"properties"`
 		}
 		err := c.ShouldBindJSON(&data)
 		if err != nil {
 			c.Set("error", utils.ParamError)
 			return
 		}
+
+		properties := map[string]interface{}{"amount": data.Amount}
+		if data.Slot != "" {
+			properties["slot"] = data.Slot
+		}
+		for key, value := range data.Properties {
+			if key != "amount" && key != "slot" {
+				properties[key] = value
+			}
+		}
+		if !validator.validate(c, metaData, data.Name, data, properties) {
+			return
+		}
 		customizedCounter, err := counter.GetCustomizedCounter(metaData.AppId, data.Name, data.Type)
 
 		if err != nil {
@@ -121,6 +153,16 @@ func customizedCounterHandler(counter storage.Counter) gin.HandlerFunc {
 			return
 		}
 
+		event := storage.Event{
+			Name:       data.Name,
+			DeviceId:   metaData.DeviceId,
+			UserId:     metaData.UserId,
+			Channel:    metaData.Channel,
+			Platform:   metaData.Platform,
+			Version:    metaData.Version,
+			Timestamp:  metaData.Timestamp,
+			Properties: properties,
+		}
 		data.Name += storage.CustomizedCounterNameSuffix
 		switch data.Type {
 		case "simple":
@@ -142,11 +184,15 @@ func customizedCounterHandler(counter storage.Counter) gin.HandlerFunc {
 
 		if err != nil {
 			c.Set("error", err)
+			return
+		}
+		if err = events.SaveEvent(metaData.AppId, event); err != nil {
+			logrus.WithFields(logrus.Fields{"event": event, "error": err.Error()}).Warn("SaveEvent error")
 		}
 	}
 }
 
-func getCounters(c *gin.Context, data counterDescriptorData, counter storage.Counter) {
+func getCounters(c *gin.Context, data counterDescriptorData, counter storage.Counter, segments segmentCounter) {
 	appId := c.GetString("appId")
 	results := make(map[string]interface{})
 
@@ -155,11 +201,11 @@ func getCounters(c *gin.Context, data counterDescriptorData, counter storage.Cou
 		var result interface{}
 		switch descriptor.Type {
 		case "simple":
-			result, err = getSimpleCounters(appId, descriptor, counter)
+			result, err = getSimpleCounters(appId, descriptor, counter, segments)
 		case "slot":
-			result, err = getSlotCounters(appId, descriptor, counter)
+			result, err = getSlotCounters(appId, descriptor, counter, segments)
 		case "cpv":
-			result, err = getCpvCounters(appId, descriptor, counter)
+			result, err = getCpvCounters(appId, descriptor, counter, segments)
 		}
 		if err != nil {
 			c.Set("error", err)
@@ -170,8 +216,14 @@ func getCounters(c *gin.Context, data counterDescriptorData, counter storage.Cou
 	c.Set("data", results)
 }
 
-func getSimpleCounters(appId string, descriptor counterDescriptor, counter storage.Counter) (data interface{}, err error) {
-	switch descriptor.Operator {
+func getSimpleCounters(appId string, descriptor counterDescriptor, counter storage.Counter,
+	segments segmentCounter) (data interface{}, err error) {
+	ops := strings.Split(descriptor.Operator, "_")
+	switch ops[0] {
+	case "segmentDateSum":
+		data, err = getSegmentDateSum(appId, descriptor, ops, segments)
+	case "campaignDateSum":
+		data, err = getCampaignDateSum(appId, descriptor, ops, segments)
 	case "sum":
 		data, err = counter.GetSimpleCounterSum(appId, descriptor.Name, descriptor.Start, descriptor.End)
 	case "span":
@@ -183,15 +235,30 @@ func getSimpleCounters(appId string, descriptor counterDescriptor, counter stora
 	return
 }
 
-func getSlotCounters(appId string, descriptor counterDescriptor, counter storage.Counter) (data interface{}, err error) {
-	switch descriptor.Operator {
+func getSlotCounters(appId string, descriptor counterDescriptor, counter storage.Counter,
+	segments segmentCounter) (data interface{}, err error) {
+	ops := strings.Split(descriptor.Operator, "_")
+	switch ops[0] {
 	case "span":
 		data, err = counter.GetSlotCounterSpan(appId, descriptor.Name, descriptor.Start, descriptor.End)
+	case "segmentSpan":
+		if len(ops) != 2 {
+			err = utils.NewHttpError(http.StatusBadRequest, http.StatusBadRequest, "missing segment in op")
+			return
+		}
+		data, err = segments.SegmentSlotSpan(appId, ops[1], descriptor.Name, descriptor.Start, descriptor.End)
+	case "campaignSpan":
+		if len(ops) != 2 {
+			err = utils.NewHttpError(http.StatusBadRequest, http.StatusBadRequest, "missing campaign in op")
+			return
+		}
+		data, err = segments.CampaignSlotSpan(appId, ops[1], descriptor.Name, descriptor.Start, descriptor.End)
 	}
 	return
 }
 
-func getCpvCounters(appId string, descriptor counterDescriptor, counter storage.Counter) (data interface{}, err error) {
+func getCpvCounters(appId string, descriptor counterDescriptor, counter storage.Counter,
+	segments segmentCounter) (data interface{}, err error) {
 	ops := strings.Split(descriptor.Operator, "_")
 	switch ops[0] {
 	case "dateCPV":
@@ -204,18 +271,42 @@ func getCpvCounters(appId string, descriptor counterDescriptor, counter storage.
 			return
 		}
 		data, err = counter.GetSimpleCPVChannelSumDate(appId, descriptor.Name, ops[1], descriptor.Start, descriptor.End)
+	case "platformDateSum":
+		if len(ops) != 2 {
+			err = utils.NewHttpError(http.StatusBadRequest, http.StatusBadRequest, "missing platform in op")
+			return
+		}
+		data, err = counter.GetSimpleCPVPlatformSumDate(appId, descriptor.Name, ops[1], descriptor.Start, descriptor.End)
+	case "segmentDateSum":
+		data, err = getSegmentDateSum(appId, descriptor, ops, segments)
+	case "campaignDateSum":
+		data, err = getCampaignDateSum(appId, descriptor, ops, segments)
 	}
 	return
 }
 
+// getSegmentDateSum sums the counter over the members of the segment in the op by date
+func getSegmentDateSum(appId string, descriptor counterDescriptor, ops []string, segments segmentCounter) (interface{}, error) {
+	if len(ops) != 2 {
+		return nil, utils.NewHttpError(http.StatusBadRequest, http.StatusBadRequest, "missing segment in op")
+	}
+	return segments.SegmentDateSum(appId, ops[1], descriptor.Name, descriptor.Start, descriptor.End)
+}
+
+// getCampaignDateSum sums the counter over the devices attributed to the campaign in the op by date
+func getCampaignDateSum(appId string, descriptor counterDescriptor, ops []string, segments segmentCounter) (interface{}, error) {
+	if len(ops) != 2 {
+		return nil, utils.NewHttpError(http.StatusBadRequest, http.StatusBadRequest, "missing campaign in op")
+	}
+	return segments.CampaignDateSum(appId, ops[1], descriptor.Name, descriptor.Start, descriptor.End)
+}
+
 func getTrendData(c *gin.Context, counter storage.Counter) {
 	appId := c.GetString("appId")
 	delta7 := utils.TodayDiff(7).Unix()
 	delta8 := utils.TodayDiff(8).Unix()
 	delta14 := utils.TodayDiff(14).Unix()
-	delta30 := utils.TodayDiff(30).Unix()
 	delta31 := utils.TodayDiff(31).Unix()
-	delta60 := utils.TodayDiff(60).Unix()
 	yesterday := utils.TodayDiff(1).Unix()
 
 	newUser7, err := counter.GetSimpleCPVSumTotal(appId, user.NewUserCPVCounter, delta7, yesterday)
@@ -229,23 +320,56 @@ func getTrendData(c *gin.Context, counter storage.Counter) {
 		return
 	}
 
-	activeUser7, err := counter.GetSimpleCPVSumTotal(appId, user.DailyActiveCPVCounter, delta7, yesterday)
+	// unique active users of the 7 and 30 days ending yesterday, and of the windows before them
+	activeUser7, err := counter.GetSimpleCPVSumTotal(appId, user.WeeklyActiveCPVCounter, yesterday, yesterday)
 	if err != nil {
 		c.Set("error", err)
 		return
 	}
-	activeUser14, err := counter.GetSimpleCPVSumTotal(appId, user.DailyActiveCPVCounter, delta14, delta8)
+	activeUser14, err := counter.GetSimpleCPVSumTotal(appId, user.WeeklyActiveCPVCounter, delta8, delta8)
 	if err != nil {
 		c.Set("error", err)
 		return
 	}
-	activeUser30, err := counter.GetSimpleCPVSumTotal(appId, user.DailyActiveCPVCounter, delta30, yesterday)
+	activeUser30, err := counter.GetSimpleCPVSumTotal(appId, user.MonthlyActiveCPVCounter, yesterday, yesterday)
 	if err != nil {
 		c.Set("error", err)
 		return
 	}
 
-	activeUser60, err := counter.GetSimpleCPVSumTotal(appId, user.DailyActiveCPVCounter, delta60, delta31)
+	activeUser60, err := counter.GetSimpleCPVSumTotal(appId, user.MonthlyActiveCPVCounter, delta31, delta31)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	stickiness, err := counter.GetSimpleCounterSum(appId, user.DailyActiveStickinessSimpleCounter, yesterday, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+
+	churnedUser7, err := counter.GetSimpleCPVSumTotal(appId, user.ChurnedUserCPVCounter, delta7, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	churnedUser14, err := counter.GetSimpleCPVSumTotal(appId, user.ChurnedUserCPVCounter, delta14, delta8)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	returningUser7, err := counter.GetSimpleCPVSumTotal(appId, user.ReturningUserCPVCounter, delta7, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	returningUser14, err := counter.GetSimpleCPVSumTotal(appId, user.ReturningUserCPVCounter, delta14, delta8)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	// the dormant user pool of yesterday, the latest computed day
+	dormantUser, err := counter.GetSimpleCPVSumTotal(appId, user.DormantUserCPVCounter, yesterday, yesterday)
 	if err != nil {
 		c.Set("error", err)
 		return
@@ -273,26 +397,140 @@ func getTrendData(c *gin.Context, counter storage.Counter) {
 		return
 	}
 
+	newUser7Platforms, err := sumByPlatform(counter, appId, user.NewUserCPVCounter, delta7, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	activeUser7Platforms, err := sumByPlatform(counter, appId, user.WeeklyActiveCPVCounter, yesterday, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+
 	nowTs := utils.NowTimestamp()
 	totalUser, err := counter.GetSimpleCPVSumTotal(appId, user.NewUserCPVCounter, 0, nowTs)
 	totalRegisteredUser, err := counter.GetSimpleCPVSumTotal(appId, user.NewRegisteredUserCPVCounter, 0, nowTs)
 
 	c.Set("data", gin.H{
-		"newUser7":            newUser7,
-		"newUser14":           newUser14,
-		"activeUser7":         activeUser7,
-		"activeUser14":        activeUser14,
-		"activeUser30":        activeUser30,
-		"activeUser60":        activeUser60,
-		"retention7":          retention7,
-		"retention14":         retention14,
-		"activeRetention7":    activeRetention7,
-		"activeRetention14":   activeRetention14,
-		"totalUser":           totalUser,
-		"totalRegisteredUser": totalRegisteredUser,
+		"newUser7":             newUser7,
+		"newUser14":            newUser14,
+		"activeUser7":          activeUser7,
+		"activeUser14":         activeUser14,
+		"activeUser30":         activeUser30,
+		"activeUser60":         activeUser60,
+		"stickiness":           stickiness,
+		"churnedUser7":         churnedUser7,
+		"churnedUser14":        churnedUser14,
+		"returningUser7":       returningUser7,
+		"returningUser14":      returningUser14,
+		"dormantUser":          dormantUser,
+		"retention7":           retention7,
+		"retention14":          retention14,
+		"activeRetention7":     activeRetention7,
+		"activeRetention14":    activeRetention14,
+		"totalUser":            totalUser,
+		"totalRegisteredUser":  totalRegisteredUser,
+		"newUser7Platforms":    newUser7Platforms,
+		"activeUser7Platforms": activeUser7Platforms,
 	})
 }
 
+// getSegmentTrendData returns the user trends of the members of the segment, active users are
+// counted from the activity of the members rather than from the daily computed counters
+func getSegmentTrendData(c *gin.Context, segments segmentCounter, segmentId string) {
+	appId := c.GetString("appId")
+	delta7 := utils.TodayDiff(7).Unix()
+	delta8 := utils.TodayDiff(8).Unix()
+	delta14 := utils.TodayDiff(14).Unix()
+	delta30 := utils.TodayDiff(30).Unix()
+	delta31 := utils.TodayDiff(31).Unix()
+	delta60 := utils.TodayDiff(60).Unix()
+	yesterday := utils.TodayDiff(1).Unix()
+
+	members, err := segments.SegmentMembers(appId, segmentId)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	if members == nil {
+		c.Set("error", utils.NewHttpError(http.StatusNotFound, http.StatusNotFound, "Segment not found"))
+		return
+	}
+
+	newUsers, err := segments.SegmentDateSum(appId, segmentId, user.NewUserCPVCounter, 0, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	var newUser7, newUser14, totalUser float64
+	for date, count := range newUsers {
+		if date >= delta7 {
+			newUser7 += count
+		} else if date >= delta14 {
+			newUser14 += count
+		}
+		totalUser += count
+	}
+
+	activeUser1, err := segments.SegmentUniqueActive(appId, segmentId, yesterday, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	activeUser7, err := segments.SegmentUniqueActive(appId, segmentId, delta7, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	activeUser14, err := segments.SegmentUniqueActive(appId, segmentId, delta14, delta8)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	activeUser30, err := segments.SegmentUniqueActive(appId, segmentId, delta30, yesterday)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	activeUser60, err := segments.SegmentUniqueActive(appId, segmentId, delta60, delta31)
+	if err != nil {
+		c.Set("error", err)
+		return
+	}
+	var stickiness float64
+	if activeUser30 > 0 {
+		stickiness = activeUser1 / activeUser30
+	}
+
+	c.Set("data", gin.H{
+		"segmentSize":  len(members),
+		"newUser7":     newUser7,
+		"newUser14":    newUser14,
+		"activeUser7":  activeUser7,
+		"activeUser14": activeUser14,
+		"activeUser30": activeUser30,
+		"activeUser60": activeUser60,
+		"stickiness":   stickiness,
+		"totalUser":    totalUser,
+	})
+}
+
+// sumByPlatform sums a cpv counter over the dates for each platform that reported data
+func sumByPlatform(counter storage.Counter, appId, counterName string, start, end int64) (map[string]float64, error) {
+	dateCPV, err := counter.GetSimpleCPVDateCPV(appId, counterName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]float64)
+	for _, platforms := range dateCPV["platform"] {
+		for platform, count := range platforms {
+			sums[platform] += count
+		}
+	}
+	return sums, nil
+}
+
 func averageNewUserRetention(counter storage.Counter, appId string, start, end int64) (float64, error) {
 	days := (end - start) / (24 * 3600)
 
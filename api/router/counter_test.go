@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/metric/segment"
 	"github.com/lt90s/goanalytics/storage/mongodb"
 	"github.com/lt90s/goanalytics/utils"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 const (
@@ -30,7 +32,9 @@ func TestCounter_SimpleCounter(t *testing.T) {
 
 	router := gin.Default()
 	router.Use(appIdMiddleware, middlewares.ResponseMiddleware)
-	InstallCounterEndpoint(router.Group("/test"), mongoCounter)
+	InstallCounterEndpoint(router.Group("/i"), router.Group("/test"), mongoCounter, segment.NewMongoStore(client, databasePrefix),
+		mongodb.NewSchemaRegistry(client, databasePrefix), middlewares.NewSampledQuarantine(1, 0, 0, mongodb.NewQuarantine(client, databasePrefix, time.Hour, 100)),
+		mongodb.NewEventLog(client, databasePrefix, time.Hour))
 
 	data := counterDescriptorData{
 		Descriptors: []counterDescriptor{
@@ -88,7 +92,9 @@ func TestCounter_SlotCounter(t *testing.T) {
 
 	router := gin.Default()
 	router.Use(appIdMiddleware, middlewares.ResponseMiddleware)
-	InstallCounterEndpoint(router.Group("/test"), mongoCounter)
+	InstallCounterEndpoint(router.Group("/i"), router.Group("/test"), mongoCounter, segment.NewMongoStore(client, databasePrefix),
+		mongodb.NewSchemaRegistry(client, databasePrefix), middlewares.NewSampledQuarantine(1, 0, 0, mongodb.NewQuarantine(client, databasePrefix, time.Hour, 100)),
+		mongodb.NewEventLog(client, databasePrefix, time.Hour))
 
 	data := counterDescriptorData{
 		Descriptors: []counterDescriptor{
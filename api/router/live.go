@@ -0,0 +1,177 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// events buffered for a slow stream before they are dropped
+	liveStreamBufferSize = 100
+	// concurrent streams allowed per app
+	maxLiveStreamsPerApp  = 10
+	liveKeepAliveInterval = 15 * time.Second
+)
+
+// LiveEvent is an ingested event sent to the live streams of its app
+type LiveEvent struct {
+	Name      string      `json:"name"`
+	DeviceId  string      `json:"deviceId"`
+	UserId    string      `json:"userId"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+type liveStream struct {
+	deviceId string
+	events   chan LiveEvent
+}
+
+// LiveHub fans the published events out to the live streams of their app. Only the events
+// published by this server instance are seen.
+type LiveHub struct {
+	mutex   sync.RWMutex
+	streams map[string]map[*liveStream]struct{}
+}
+
+func NewLiveHub() *LiveHub {
+	return &LiveHub{streams: make(map[string]map[*liveStream]struct{})}
+}
+
+// subscribe returns nil when the app has too many streams
+func (h *LiveHub) subscribe(appId, deviceId string) *liveStream {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	streams, ok := h.streams[appId]
+	if !ok {
+		streams = make(map[*liveStream]struct{})
+		h.streams[appId] = streams
+	}
+	if len(streams) >= maxLiveStreamsPerApp {
+		return nil
+	}
+	stream := &liveStream{deviceId: deviceId, events: make(chan LiveEvent, liveStreamBufferSize)}
+	streams[stream] = struct{}{}
+	return stream
+}
+
+func (h *LiveHub) unsubscribe(appId string, stream *liveStream) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.streams[appId], stream)
+	if len(h.streams[appId]) == 0 {
+		delete(h.streams, appId)
+	}
+}
+
+func (h *LiveHub) broadcast(name string, data interface{}) {
+	metadata := metaDataOf(data)
+	if metadata == nil {
+		return
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for stream := range h.streams[metadata.AppId] {
+		if stream.deviceId != "" && stream.deviceId != metadata.DeviceId {
+			continue
+		}
+		select {
+		case stream.events <- LiveEvent{
+			Name:      name,
+			DeviceId:  metadata.DeviceId,
+			UserId:    metadata.UserId,
+			Timestamp: metadata.Timestamp,
+			Data:      data,
+		}:
+		default:
+			// never block ingestion on a slow stream
+		}
+	}
+}
+
+// metaDataOf returns the MetaData of ingested events, which are either a MetaData or a struct
+// with a MetaData field. Other events, e.g. the scheduled ones, return nil.
+func metaDataOf(data interface{}) *middlewares.MetaData {
+	if metadata, ok := data.(*middlewares.MetaData); ok {
+		return metadata
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	field := v.FieldByName("MetaData")
+	if !field.IsValid() || !field.CanInterface() {
+		return nil
+	}
+	metadata, _ := field.Interface().(*middlewares.MetaData)
+	return metadata
+}
+
+type livePublisher struct {
+	publisher pubsub.Publisher
+	hub       *LiveHub
+}
+
+// NewLivePublisher returns a Publisher sending the published events to the live streams as well
+func NewLivePublisher(publisher pubsub.Publisher, hub *LiveHub) pubsub.Publisher {
+	return livePublisher{publisher: publisher, hub: hub}
+}
+
+func (lp livePublisher) Publish(event string, data interface{}) error {
+	err := lp.publisher.Publish(event, data)
+	if err == nil {
+		lp.hub.broadcast(event, data)
+	}
+	return err
+}
+
+func InstallLiveEndpoint(oRouter *gin.RouterGroup, hub *LiveHub) {
+	oRouter.GET("/live", liveStreamHandler(hub))
+}
+
+// liveStreamHandler streams the events of the app as Server-Sent Events, only those of the
+// device given by the deviceId query if present
+func liveStreamHandler(hub *LiveHub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		stream := hub.subscribe(appId, c.Query("deviceId"))
+		if stream == nil {
+			c.Set("error", utils.NewHttpError(http.StatusTooManyRequests, http.StatusTooManyRequests, "Too many live streams"))
+			return
+		}
+		defer hub.unsubscribe(appId, stream)
+
+		keepAlive := time.NewTicker(liveKeepAliveInterval)
+		defer keepAlive.Stop()
+		// set before the headers are flushed, EventSource rejects other content types
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		// let the client know the stream is open before the first event
+		c.Writer.Flush()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event := <-stream.events:
+				c.SSEvent("event", event)
+				return true
+			case <-keepAlive.C:
+				c.SSEvent("ping", utils.NowTimestamp())
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(event string, data interface{}) error {
+	return nil
+}
+
+func TestLiveStream(t *testing.T) {
+	hub := NewLiveHub()
+	publisher := NewLivePublisher(nopPublisher{}, hub)
+
+	router := gin.Default()
+	router.Use(appIdMiddleware, middlewares.ResponseMiddleware)
+	InstallLiveEndpoint(router.Group("/o"), hub)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/o/live?appId=" + appId + "&deviceId=d1")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	// wait for the stream to be subscribed
+	for i := 0; i < 100; i++ {
+		hub.mutex.RLock()
+		subscribed := len(hub.streams[appId]) > 0
+		hub.mutex.RUnlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	publish := func(p pubsub.Publisher, deviceId string) {
+		require.NoError(t, p.Publish("foo", &struct {
+			MetaData *middlewares.MetaData
+			Seconds  float64
+		}{&middlewares.MetaData{AppId: appId, DeviceId: deviceId}, 1.0}))
+	}
+	publish(publisher, "d2")
+	publish(publisher, "d1")
+	// events without MetaData are not streamed
+	require.NoError(t, publisher.Publish("bar", 1))
+
+	reader := bufio.NewReader(response.Body)
+	var name, data string
+	for name != "event" || data == "" {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if strings.HasPrefix(line, "event:") {
+			name, data = strings.TrimSpace(strings.TrimPrefix(line, "event:")), ""
+		} else if strings.HasPrefix(line, "data:") {
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	var event LiveEvent
+	require.NoError(t, json.Unmarshal([]byte(data), &event))
+	require.Equal(t, "foo", event.Name)
+	require.Equal(t, "d1", event.DeviceId)
+}
@@ -0,0 +1,40 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/utils"
+	"strconv"
+)
+
+const defaultQuarantineListLimit = 100
+
+func InstallQuarantineEndpoint(oRouter *gin.RouterGroup, quarantine storage.Quarantine, maxEntries int64) {
+	oRouter.GET("/quarantine", getQuarantineHandler(quarantine, maxEntries))
+}
+
+// getQuarantineHandler returns the latest quarantined requests of the app, optionally of a
+// reason given by the reason query
+func getQuarantineHandler(quarantine storage.Quarantine, maxEntries int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		limit := int64(defaultQuarantineListLimit)
+		if s := c.Query("limit"); s != "" {
+			var err error
+			limit, err = strconv.ParseInt(s, 10, 64)
+			if err != nil || limit <= 0 {
+				c.Set("error", utils.ParamError)
+				return
+			}
+		}
+		if maxEntries > 0 && limit > maxEntries {
+			limit = maxEntries
+		}
+		entries, err := quarantine.List(appId, c.Query("reason"), limit)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", entries)
+		}
+	}
+}
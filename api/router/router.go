@@ -6,30 +6,89 @@ import (
 	"github.com/lt90s/goanalytics/api/middlewares"
 	"github.com/lt90s/goanalytics/conf"
 	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/geoip"
 	"github.com/lt90s/goanalytics/metric"
+	"github.com/lt90s/goanalytics/metric/segment"
 	"github.com/lt90s/goanalytics/schedule"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/memory"
 	"github.com/lt90s/goanalytics/storage/mongodb"
 	"net/http"
+	"time"
 )
 
 func Setup(router *gin.Engine, publisher pubsub.Publisher) {
+	trustedProxyMiddleware, err := middlewares.NewTrustedProxyMiddleware(conf.GetConfStringSlice(conf.TrustedProxiesConfKey))
+	if err != nil {
+		panic(err)
+	}
+	router.Use(trustedProxyMiddleware.Middleware())
+
 	client := mongodb.DefaultClient
 	authStore := authentication.NewMongoStore(client, conf.GetConfString(conf.MongoDatabaseAdminKey))
 	counterStore := mongodb.NewCounter(client, conf.GetConfString(conf.MongoDatabasePrefixKey))
+	schemaRegistry := mongodb.NewSchemaRegistry(client, conf.GetConfString(conf.MongoDatabasePrefixKey))
+	quarantineRetention := time.Duration(conf.GetConfInt64(conf.QuarantineRetentionSecondsConfKey)) * time.Second
+	quarantineMaxEntries := conf.GetConfInt64(conf.QuarantineMaxEntriesConfKey)
+	quarantine := mongodb.NewQuarantine(client, conf.GetConfString(conf.MongoDatabasePrefixKey), quarantineRetention,
+		quarantineMaxEntries)
 
 	jwtMiddleware := middlewares.NewJwtMiddleware(authStore)
-	metadataMiddleware := middlewares.NewMetaDataMiddleware(authStore)
+	appConfigCacheTTL := time.Duration(conf.GetConfInt64(conf.AppConfigCacheSecondsConfKey)) * time.Second
+	appConfigGetter := middlewares.NewCachedAppConfigGetter(authStore, appConfigCacheTTL)
+	// rejected requests and schema mismatches share the sampling and the limit
+	sampledQuarantine := middlewares.NewSampledQuarantine(conf.GetConfFloat64(conf.QuarantineSampleRateConfKey),
+		conf.GetConfFloat64(conf.QuarantineAppRateConfKey), int(conf.GetConfInt64(conf.QuarantineAppBurstConfKey)),
+		quarantine)
+	quarantineMiddleware := middlewares.NewQuarantineMiddleware(sampledQuarantine, appConfigGetter)
+	metadataMiddleware := middlewares.NewMetaDataMiddleware(appConfigGetter)
+	rateLimitMiddleware := middlewares.NewRateLimitMiddleware(middlewares.RateLimit{
+		AppRate:     conf.GetConfFloat64(conf.RateLimitAppRateConfKey),
+		AppBurst:    int(conf.GetConfInt64(conf.RateLimitAppBurstConfKey)),
+		DeviceRate:  conf.GetConfFloat64(conf.RateLimitDeviceRateConfKey),
+		DeviceBurst: int(conf.GetConfInt64(conf.RateLimitDeviceBurstConfKey)),
+	}, appConfigGetter, counterStore)
+	replayWindow := time.Duration(conf.GetConfInt64(conf.ReplayWindowSecondsConfKey)) * time.Second
+	replayMiddleware := middlewares.NewReplayMiddleware(replayWindow, newSeenSet(conf.NonceStoreConfKey, "nonceCollection", 0),
+		appConfigGetter, counterStore)
+	dedupWindow := time.Duration(conf.GetConfInt64(conf.DedupWindowSecondsConfKey)) * time.Second
+	dedupStore := newSeenSet(conf.DedupStoreConfKey, "eventIdCollection", int(conf.GetConfInt64(conf.MemorySeenSetCapacityConfKey)))
+	dedupMiddleware := middlewares.NewDedupMiddleware(dedupWindow, dedupStore, counterStore)
+	clockSkewMiddleware := middlewares.NewClockSkewMiddleware(middlewares.ClockSkew{
+		Policy:    conf.GetConfString(conf.ClockSkewPolicyConfKey),
+		Threshold: conf.GetConfInt64(conf.ClockSkewThresholdConfKey),
+	}, appConfigGetter, counterStore)
 
 	router.Use(middlewares.ResponseMiddleware)
 
 	authentication.SetupRoute(router, jwtMiddleware, authStore, publisher)
 
-	iRouter := router.Group("/i", metadataMiddleware.Middleware())
+	iRouter := router.Group("/i", quarantineMiddleware.Middleware(), metadataMiddleware.Middleware(), rateLimitMiddleware.Middleware(),
+		replayMiddleware.Middleware(), clockSkewMiddleware.Middleware(), dedupMiddleware.Middleware())
+	if path := conf.GetConfString(conf.GeoIPDatabaseConfKey); path != "" {
+		reloadInterval := time.Duration(conf.GetConfInt64(conf.GeoIPReloadSecondsConfKey)) * time.Second
+		locator, err := geoip.NewReader(path, reloadInterval)
+		if err != nil {
+			panic(err)
+		}
+		iRouter.Use(middlewares.NewGeoIPMiddleware(locator).Middleware())
+	}
 	oRouter := router.Group("/o", jwtMiddleware.MiddlewareFunc(), appIdMiddleware)
 
-	InstallCounterEndpoint(iRouter, oRouter, counterStore)
+	eventRetention := time.Duration(conf.GetConfInt64(conf.EventRetentionDaysConfKey)) * 24 * time.Hour
+	eventLog := mongodb.NewEventLog(client, conf.GetConfString(conf.MongoDatabasePrefixKey), eventRetention)
+	segmentStore := segment.NewMongoStore(client, conf.GetConfString(conf.MongoDatabasePrefixKey))
+	InstallCounterEndpoint(iRouter, oRouter, counterStore, segmentStore, schemaRegistry, sampledQuarantine, eventLog)
+	InstallSchemaEndpoint(oRouter, schemaRegistry, counterStore)
+	InstallQuarantineEndpoint(oRouter, quarantine, quarantineMaxEntries)
+
+	liveHub := NewLiveHub()
+	InstallLiveEndpoint(oRouter, liveHub)
 
-	metric.SetupMetricApi(iRouter, oRouter, publisher)
+	// campaign tracking links
+	cRouter := router.Group("/c")
+
+	metric.SetupMetricApi(iRouter, oRouter, cRouter, NewLivePublisher(publisher, liveHub))
 
 	schedule.RunScheduler(authStore, publisher)
 }
@@ -43,3 +102,14 @@ func appIdMiddleware(c *gin.Context) {
 	c.Set("appId", appId)
 	c.Next()
 }
+
+// newSeenSet returns the seen set of the store configuration, capacity bounds the in-memory
+// store only
+func newSeenSet(storeConfKey, collectionName string, capacity int) storage.SeenSet {
+	switch conf.GetConfString(storeConfKey) {
+	case "mongodb":
+		return mongodb.NewSeenSet(mongodb.DefaultClient, conf.GetConfString(conf.MongoDatabasePrefixKey), collectionName)
+	default:
+		return memory.NewSeenSet(capacity)
+	}
+}
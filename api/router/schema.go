@@ -0,0 +1,150 @@
+package router
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SchemaValidationFailureSlotCounter counts the custom events mismatching their schema per
+	// day, slotted by event name
+	SchemaValidationFailureSlotCounter = "SchemaValidationFailureSlotCounter"
+
+	QuarantineReasonSchemaMismatch = "schema_mismatch"
+)
+
+func InstallSchemaEndpoint(oRouter *gin.RouterGroup, schemas storage.SchemaRegistry, counter storage.Counter) {
+	requireAdminRole := middlewares.RequireRoleMiddleware([]string{"admin"})
+	oRouter.GET("/schema", getEventSchemasHandler(schemas))
+	oRouter.POST("/schema", requireAdminRole, setEventSchemaHandler(schemas))
+	oRouter.DELETE("/schema", requireAdminRole, deleteEventSchemaHandler(schemas))
+	oRouter.GET("/schema/failures", getSchemaFailuresHandler(counter))
+}
+
+func getEventSchemasHandler(schemas storage.SchemaRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		data, err := schemas.GetEventSchemas(appId)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", data)
+		}
+	}
+}
+
+func setEventSchemaHandler(schemas storage.SchemaRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var data storage.EventSchema
+		err := c.ShouldBindJSON(&data)
+		if err != nil || !data.Valid() {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if err = schemas.SetEventSchema(appId, data); err != nil {
+			c.Set("error", err)
+		}
+	}
+}
+
+func deleteEventSchemaHandler(schemas storage.SchemaRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var tmp struct {
+			Name string `json:"name"`
+		}
+		if err := c.ShouldBindJSON(&tmp); err != nil || tmp.Name == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if err := schemas.DeleteEventSchema(appId, tmp.Name); err != nil {
+			c.Set("error", err)
+		}
+	}
+}
+
+// getSchemaFailuresHandler returns the validation failure count of each event between start and end
+func getSchemaFailuresHandler(counter storage.Counter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		if err1 != nil || err2 != nil || start > end {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		span, err := counter.GetSlotCounterSpan(appId, SchemaValidationFailureSlotCounter, start, end)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		failures := make(map[string]float64)
+		for _, slotCounter := range span {
+			for name, count := range slotCounter {
+				failures[name] += count
+			}
+		}
+		c.Set("data", failures)
+	}
+}
+
+// eventValidator validates custom events against the schemas registered for the app
+type eventValidator struct {
+	schemas    storage.SchemaRegistry
+	quarantine middlewares.SampledQuarantine
+	counter    storage.Counter
+}
+
+// validate reports whether the event matches its schema, events without a schema always
+// match. Mismatches are counted and, depending on the schema action, rejected with an error
+// set on the context or quarantined with the sampling and the limit of the rejected requests.
+func (v eventValidator) validate(c *gin.Context, metadata *middlewares.MetaData, name string, event interface{},
+	properties map[string]interface{}) bool {
+	entry := logrus.WithFields(logrus.Fields{"appId": metadata.AppId, "event": name})
+	schema, found, err := v.schemas.GetEventSchema(metadata.AppId, name)
+	if err != nil {
+		entry.Warn("GetEventSchema error: ", err.Error())
+		return true
+	}
+	if !found {
+		return true
+	}
+	mismatches := schema.Validate(properties)
+	if len(mismatches) == 0 {
+		return true
+	}
+
+	entry.WithFields(logrus.Fields{"mismatches": mismatches}).Debug("event schema mismatch")
+	err = v.counter.AddSlotCounter(metadata.AppId, SchemaValidationFailureSlotCounter, name, utils.TodayTimestamp(), 1.0)
+	if err != nil {
+		entry.Warn("add SchemaValidationFailureSlotCounter error: ", err.Error())
+	}
+
+	if schema.Action == storage.SchemaActionQuarantine {
+		body, _ := json.Marshal(event)
+		err = v.quarantine.Add(metadata.AppId, storage.QuarantineEntry{
+			Reason:    QuarantineReasonSchemaMismatch,
+			Details:   append([]string{name}, mismatches...),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Query:     c.Request.URL.RawQuery,
+			Body:      string(body),
+			Timestamp: metadata.ServerTimestamp,
+		})
+		if err != nil {
+			entry.Warn("quarantine error: ", err.Error())
+		}
+	} else {
+		middlewares.SetRejectReason(c, QuarantineReasonSchemaMismatch)
+		c.Set("error", utils.NewHttpError(http.StatusBadRequest, http.StatusBadRequest, strings.Join(mismatches, "; ")))
+	}
+	return false
+}
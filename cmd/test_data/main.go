@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/lt90s/goanalytics/api/authentication"
 	"github.com/lt90s/goanalytics/conf"
+	"github.com/lt90s/goanalytics/metric/revenue"
 	usage2 "github.com/lt90s/goanalytics/metric/usage"
 	"github.com/lt90s/goanalytics/metric/user"
 	"github.com/lt90s/goanalytics/storage"
@@ -14,6 +15,7 @@ import (
 	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 )
 
 
@@ -23,15 +25,17 @@ var (
 	channels = []string{"huawei", "xiaomi", "appStore", "google"}
 	versions = []string{"1.0.0", "1.2.0", "2.0.0"}
 	appId string
+	platforms string
 )
 
 func init() {
 	flag.StringVar(&appId, "appId", "", "appId")
+	flag.StringVar(&platforms, "platforms", "ios,android", "comma separated platforms")
 	flag.Usage = usage
 }
 
 func usage() {
-	_, _ = fmt.Fprintf(os.Stderr, "%s -appId appId\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "%s -appId appId [-platforms ios,android]\n", os.Args[0])
 }
 
 func setSlotCounterPercent(counter storage.Counter, name string, slots []string) {
@@ -71,7 +75,7 @@ func setCPVCounter(counter storage.Counter, name string, lower, upper int) {
 	tmpStart := start
 	for tmpStart <= end {
 		for _, c := range channels {
-			for _, p := range []string{"ios", "android"} {
+			for _, p := range strings.Split(platforms, ",") {
 				for _, v := range versions {
 					amount := rand.Intn(upper-lower) + lower
 					counter.AddSimpleCPVCounter(appId, c, p, v, name, tmpStart, float64(amount))
@@ -179,6 +183,7 @@ func main() {
 	setCPVCounter(counter, data.Name + storage.CustomizedCounterNameSuffix, 10, 100)
 
 	setUsageMetric(counter)
+	setRevenueMetric(counter)
 }
 
 
@@ -191,4 +196,19 @@ func setUsageMetric(counter storage.Counter) {
 	setSlotCounter(counter, usage2.EachUsageTimeDistributionSlotCounter, slots, 1, 1000)
 	setSimpleCounter(counter, usage2.DailyUsageAverageTimeSimpleCounter)
 	setSlotCounter(counter, usage2.DailyUsageTimeDistributionSlotCounter, slots, 1, 3600)
-}
\ No newline at end of file
+}
+
+func setRevenueMetric(counter storage.Counter) {
+	setCPVCounter(counter, revenue.RevenueCPVCounterPrefix+"USD", 100, 1000)
+	setCPVCounter(counter, revenue.PurchaseCPVCounter, 10, 100)
+	setCPVCounter(counter, revenue.PayingUserCPVCounter, 5, 50)
+	setCPVCounter(counter, revenue.NewPayingUserCPVCounter, 1, 10)
+	setSlotCounter(counter, revenue.RevenueSlotCounter, []string{"USD"}, 1000, 10000)
+	setSlotCounter(counter, revenue.ARPUSlotCounter, []string{"USD"}, 1, 10)
+	setSlotCounter(counter, revenue.ARPPUSlotCounter, []string{"USD"}, 10, 100)
+	ltvSlots := make([]string, 0, 31)
+	for i := 0; i <= 30; i++ {
+		ltvSlots = append(ltvSlots, strconv.Itoa(i))
+	}
+	setSlotCounter(counter, revenue.LTVRevenueSlotCounterPrefix+"USD", ltvSlots, 10, 100)
+}
@@ -2,8 +2,15 @@ package common
 
 const (
 	GlobalEventDropData = "GlobalEventDropData"
+	// retention of days newly added to an app's retention days is computed from history
+	GlobalEventBackfillRetention = "GlobalEventBackfillRetention"
 )
 
 type DropDataRequest struct {
 	AppId string `json:appId`
-}
\ No newline at end of file
+}
+
+type BackfillRetentionRequest struct {
+	AppId string `json:"appId"`
+	Days  []int  `json:"days"`
+}
@@ -1,6 +1,9 @@
 package conf
 
-import "github.com/spf13/viper"
+import (
+	"github.com/spf13/viper"
+	"strconv"
+)
 
 const (
 	ServerAddr = "SERVER_ADDR"
@@ -13,6 +16,67 @@ const (
 
 	TimezoneConfKey = "Timezone"
 
+	// seconds an app's settings are cached by the ingestion api
+	AppConfigCacheSecondsConfKey = "APP_CONFIG_CACHE_SECONDS"
+
+	// REPLAY PROTECTION CONFIG
+	// acceptance window of client timestamps in seconds, 0 disables the check
+	ReplayWindowSecondsConfKey = "REPLAY_WINDOW_SECONDS"
+	// memory or mongodb, use mongodb when several api instances are deployed
+	NonceStoreConfKey = "NONCE_STORE"
+	// seconds an event id is remembered, 0 disables deduplication
+	DedupWindowSecondsConfKey = "DEDUP_WINDOW_SECONDS"
+	// store of the seen event ids, memory or mongodb
+	DedupStoreConfKey = "DEDUP_STORE"
+	// event ids kept by the in-memory dedup store, 0 means unbounded. Nonces are never evicted
+	// before they expire, or they could be replayed.
+	MemorySeenSetCapacityConfKey = "MEMORY_SEEN_SET_CAPACITY"
+
+	// default ingestion rate limits in requests per second, 0 means no limit
+	RateLimitAppRateConfKey     = "RATE_LIMIT_APP_RATE"
+	RateLimitAppBurstConfKey    = "RATE_LIMIT_APP_BURST"
+	RateLimitDeviceRateConfKey  = "RATE_LIMIT_DEVICE_RATE"
+	RateLimitDeviceBurstConfKey = "RATE_LIMIT_DEVICE_BURST"
+
+	// default client clock skew policy (trust, clamp or reject) and threshold in seconds
+	ClockSkewPolicyConfKey    = "CLOCK_SKEW_POLICY"
+	ClockSkewThresholdConfKey = "CLOCK_SKEW_THRESHOLD_SECONDS"
+
+	// default accepted platforms, space separated when set by environment
+	PlatformsConfKey = "PLATFORMS"
+
+	// path of a MaxMind-format city or country database, geoip lookup is disabled when empty
+	GeoIPDatabaseConfKey = "GEOIP_DATABASE"
+	// seconds between checks of the geoip database file for modifications
+	GeoIPReloadSecondsConfKey = "GEOIP_RELOAD_SECONDS"
+	// ips and CIDRs of the proxies whose X-Forwarded-For is trusted, space separated when set by
+	// environment
+	TrustedProxiesConfKey = "TRUSTED_PROXIES"
+
+	// seconds a click of a campaign tracking link is attributed to an install reporting its
+	// click id as the referrer, and to an install with the same ip and platform
+	AttributionReferrerWindowSecondsConfKey    = "ATTRIBUTION_REFERRER_WINDOW_SECONDS"
+	AttributionFingerprintWindowSecondsConfKey = "ATTRIBUTION_FINGERPRINT_WINDOW_SECONDS"
+
+	// seconds quarantined requests are kept
+	QuarantineRetentionSecondsConfKey = "QUARANTINE_RETENTION_SECONDS"
+	// entries kept per app
+	QuarantineMaxEntriesConfKey = "QUARANTINE_MAX_ENTRIES"
+	// fraction of the rejected ingestion requests quarantined, 0 disables it
+	QuarantineSampleRateConfKey = "QUARANTINE_SAMPLE_RATE"
+	// token bucket of the quarantine writes per app, the rate is writes per second, 0 means no limit
+	QuarantineAppRateConfKey  = "QUARANTINE_APP_RATE"
+	QuarantineAppBurstConfKey = "QUARANTINE_APP_BURST"
+
+	// days the raw custom events are kept for the funnel and segment queries, 0 keeps them forever
+	EventRetentionDaysConfKey = "EVENT_RETENTION_DAYS"
+
+	// default days after the install or activity date counted by retention, space separated
+	// when set by environment
+	RetentionDaysConfKey = "RETENTION_DAYS"
+	// days without activity after which a user is churned
+	ChurnInactiveDaysConfKey = "CHURN_INACTIVE_DAYS"
+
 	// JWT MIDDLEWARE CONFIG
 	JWTRealmConfKey = "JWT_REAL_CONF_KEY"
 	JWTKeyConfKey   = "JWT_KEY_CONF_key"
@@ -34,6 +98,32 @@ func init() {
 	viper.SetDefault(MongoDatabasePrefixKey, "goanalytics_")
 	viper.SetDefault(MongoDatabaseAdminKey, "goanalytics_admin")
 	viper.SetDefault(TimezoneConfKey, "Asia/Shanghai")
+	viper.SetDefault(AppConfigCacheSecondsConfKey, 30)
+	viper.SetDefault(ReplayWindowSecondsConfKey, 24*3600)
+	viper.SetDefault(NonceStoreConfKey, "memory")
+	viper.SetDefault(DedupWindowSecondsConfKey, 24*3600)
+	viper.SetDefault(DedupStoreConfKey, "memory")
+	viper.SetDefault(MemorySeenSetCapacityConfKey, 1000000)
+	viper.SetDefault(RateLimitAppRateConfKey, 0)
+	viper.SetDefault(RateLimitAppBurstConfKey, 0)
+	viper.SetDefault(RateLimitDeviceRateConfKey, 1)
+	viper.SetDefault(RateLimitDeviceBurstConfKey, 30)
+	viper.SetDefault(ClockSkewPolicyConfKey, "trust")
+	viper.SetDefault(ClockSkewThresholdConfKey, 24*3600)
+	viper.SetDefault(PlatformsConfKey, []string{"ios", "android"})
+	viper.SetDefault(GeoIPDatabaseConfKey, "")
+	viper.SetDefault(GeoIPReloadSecondsConfKey, 60)
+	viper.SetDefault(TrustedProxiesConfKey, []string{})
+	viper.SetDefault(AttributionReferrerWindowSecondsConfKey, 7*24*3600)
+	viper.SetDefault(AttributionFingerprintWindowSecondsConfKey, 24*3600)
+	viper.SetDefault(QuarantineRetentionSecondsConfKey, 7*24*3600)
+	viper.SetDefault(QuarantineMaxEntriesConfKey, 1000)
+	viper.SetDefault(QuarantineSampleRateConfKey, 0.1)
+	viper.SetDefault(QuarantineAppRateConfKey, 1)
+	viper.SetDefault(QuarantineAppBurstConfKey, 10)
+	viper.SetDefault(EventRetentionDaysConfKey, 180)
+	viper.SetDefault(ChurnInactiveDaysConfKey, 7)
+	viper.SetDefault(RetentionDaysConfKey, []string{"1", "2", "3", "4", "5", "6", "7", "15", "30"})
 
 	// JWT Middleware Config defaults
 	viper.SetDefault(JWTRealmConfKey, "example.com")
@@ -59,10 +149,26 @@ func GetConfInt64(key string) int64 {
 	return viper.GetInt64(key)
 }
 
+func GetConfFloat64(key string) float64 {
+	return viper.GetFloat64(key)
+}
+
 func GetConfStringSlice(key string) []string {
 	return viper.GetStringSlice(key)
 }
 
+// GetConfIntSlice returns the integers of a string slice config, invalid integers are skipped
+func GetConfIntSlice(key string) []int {
+	var values []int
+	for _, s := range viper.GetStringSlice(key) {
+		value, err := strconv.Atoi(s)
+		if err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func GetConfByteSlice(key string) []byte {
 	return []byte(viper.GetString(key))
 }
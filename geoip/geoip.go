@@ -0,0 +1,115 @@
+package geoip
+
+import (
+	"errors"
+	"github.com/oschwald/maxminddb-golang"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Location of an ip address. Country and Region are ISO codes, e.g. "CN" and "CN-BJ"
+type Location struct {
+	Country string
+	Region  string
+	City    string
+}
+
+type Locator interface {
+	Lookup(ip net.IP) (Location, error)
+}
+
+// record is the part of a MaxMind GeoIP2/GeoLite2 City or Country record we use
+type record struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+type reader struct {
+	path    string
+	mutex   sync.RWMutex
+	db      *maxminddb.Reader
+	modTime time.Time
+}
+
+// NewReader opens the MaxMind-format database at path. The file is checked every
+// reloadInterval and reopened when it is modified, so that the database can be updated
+// without restarting.
+func NewReader(path string, reloadInterval time.Duration) (Locator, error) {
+	r := &reader{path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	if reloadInterval > 0 {
+		go func() {
+			for range time.Tick(reloadInterval) {
+				if err := r.reload(); err != nil {
+					log.WithFields(log.Fields{"path": path, "error": err.Error()}).Warn("[geoip] reload database error")
+				}
+			}
+		}()
+	}
+	return r, nil
+}
+
+func (r *reader) reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	r.mutex.RLock()
+	modified := !info.ModTime().Equal(r.modTime)
+	r.mutex.RUnlock()
+	if !modified {
+		return nil
+	}
+
+	db, err := maxminddb.Open(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	old := r.db
+	r.db = db
+	r.modTime = info.ModTime()
+	r.mutex.Unlock()
+
+	// lookups hold the read lock, so nobody uses the old database anymore
+	if old != nil {
+		old.Close()
+	}
+	log.WithFields(log.Fields{"path": r.path}).Info("[geoip] database loaded")
+	return nil
+}
+
+func (r *reader) Lookup(ip net.IP) (Location, error) {
+	var location Location
+	if ip == nil {
+		return location, errors.New("invalid ip")
+	}
+
+	var rec record
+	r.mutex.RLock()
+	err := r.db.Lookup(ip, &rec)
+	r.mutex.RUnlock()
+	if err != nil {
+		return location, err
+	}
+
+	location.Country = rec.Country.IsoCode
+	if len(rec.Subdivisions) > 0 && rec.Subdivisions[0].IsoCode != "" {
+		location.Region = rec.Country.IsoCode + "-" + rec.Subdivisions[0].IsoCode
+	}
+	location.City = rec.City.Names["en"]
+	return location, nil
+}
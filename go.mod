@@ -18,6 +18,7 @@ require (
 	github.com/mattn/go-isatty v0.0.7 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
+	github.com/oschwald/maxminddb-golang v1.3.1
 	github.com/segmentio/kafka-go v0.2.4
 	github.com/sirupsen/logrus v1.4.1
 	github.com/spf13/viper v1.3.2
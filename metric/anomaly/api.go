@@ -0,0 +1,48 @@
+package anomaly
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/utils"
+	"strconv"
+)
+
+func SetupRoute(oRoute *gin.RouterGroup, store Store) {
+	oGroup := oRoute.Group("/anomaly")
+	oGroup.GET("", getAnomaliesHandler(store))
+}
+
+// getAnomaliesHandler returns the anomalies of the dates between start and end, optionally only
+// the ones of the severity query or higher
+func getAnomaliesHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		if err1 != nil || err2 != nil || start > end {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		minRank := 0
+		if severity := c.Query("severity"); severity != "" {
+			rank, ok := severityRanks[severity]
+			if !ok {
+				c.Set("error", utils.ParamError)
+				return
+			}
+			minRank = rank
+		}
+
+		anomalies, err := store.getAnomalies(appId, start, end)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		filtered := make([]Anomaly, 0, len(anomalies))
+		for _, a := range anomalies {
+			if severityRanks[a.Severity] >= minRank {
+				filtered = append(filtered, a)
+			}
+		}
+		c.Set("data", filtered)
+	}
+}
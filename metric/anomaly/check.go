@@ -0,0 +1,91 @@
+package anomaly
+
+import (
+	"github.com/lt90s/goanalytics/metric/usage"
+	"github.com/lt90s/goanalytics/metric/user"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/utils"
+)
+
+// keyCounters are the cpv counters checked for every app
+var keyCounters = []string{
+	user.NewUserCPVCounter,
+	user.DailyActiveCPVCounter,
+	user.OpenAppCPVCounter,
+	usage.UsageTimeCPVCounter,
+}
+
+// dailyEvents are the daily computations that must be done before the date is checked
+var dailyEvents = []string{
+	user.DailyScheduleEvent,
+	usage.DailyScheduleEvent,
+}
+
+// dailyProcessed reports whether the daily computations of the date are done
+func dailyProcessed(store Store, appId string, date int64) (bool, error) {
+	for _, name := range dailyEvents {
+		processed, err := store.IsDailyProcessed(appId, name, date)
+		if err != nil || !processed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// counterValues returns the daily values of the counter between the dates start and end, slot
+// counters are summed over the slots
+func counterValues(store Store, appId, counterName, counterType string, start, end int64) (map[int64]float64, error) {
+	switch counterType {
+	case "simple":
+		return store.GetSimpleCounterSpan(appId, counterName, start, end)
+	case "slot":
+		slotCounters, err := store.GetSlotCounterSpan(appId, counterName, start, end)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[int64]float64, len(slotCounters))
+		for date, slots := range slotCounters {
+			for _, count := range slots {
+				values[date] += count
+			}
+		}
+		return values, nil
+	}
+	return store.GetSimpleCPVSumDate(appId, counterName, start, end)
+}
+
+// checkCounters checks the key counters and the customized counters of the app on the date and
+// replaces the anomalies recorded for the date
+func checkCounters(store Store, appId string, date int64) ([]Anomaly, error) {
+	start := date - baselineWeeks*7*24*3600
+	anomalies := make([]Anomaly, 0)
+	check := func(counterName, counterType, displayName string) error {
+		values, err := counterValues(store, appId, counterName, counterType, start, date)
+		if err != nil {
+			return err
+		}
+		if a, found := detect(values, date); found {
+			a.Counter = counterName
+			a.DisplayName = displayName
+			a.CreatedAt = utils.NowTimestamp()
+			anomalies = append(anomalies, a)
+		}
+		return nil
+	}
+
+	for _, counterName := range keyCounters {
+		if err := check(counterName, "cpv", ""); err != nil {
+			return nil, err
+		}
+	}
+	customizedCounters, err := store.GetCustomizedCounters(appId)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range customizedCounters {
+		if err = check(c.Name+storage.CustomizedCounterNameSuffix, c.Type, c.DisplayName); err != nil {
+			return nil, err
+		}
+	}
+	return anomalies, store.saveAnomalies(appId, date, anomalies)
+}
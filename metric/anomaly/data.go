@@ -0,0 +1,61 @@
+package anomaly
+
+const (
+	// published by the scheduler every hour, the date is checked once after its daily
+	// computations are done
+	ScheduleEvent = "AnomalyScheduleEvent"
+)
+
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+const (
+	// the baseline of a date is the same weekday of the previous weeks
+	baselineWeeks     = 8
+	minBaselinePoints = 4
+	// counters whose baseline median is below are too small to be checked
+	minBaselineMedian = 10
+	// the deviation scale is at least this ratio of the median, so that very stable counters
+	// are not flagged for a small change
+	minScaleRatio = 0.05
+	// MAD is scaled to the standard deviation of a normal distribution
+	madScale = 1.4826
+)
+
+// severity thresholds of the robust z-score, in decreasing order
+var severityScores = []struct {
+	severity string
+	score    float64
+}{
+	{SeverityHigh, 8},
+	{SeverityMedium, 5},
+	{SeverityLow, 3.5},
+}
+
+var severityRanks = map[string]int{SeverityLow: 1, SeverityMedium: 2, SeverityHigh: 3}
+
+// Anomaly is a daily counter value far from the counter baseline
+type Anomaly struct {
+	Counter string `json:"counter" bson:"counter"`
+	// display name of customized counters
+	DisplayName string  `json:"displayName" bson:"displayName"`
+	Date        int64   `json:"date" bson:"date"`
+	Value       float64 `json:"value" bson:"value"`
+	// median of the baseline
+	Baseline  float64 `json:"baseline" bson:"baseline"`
+	Score     float64 `json:"score" bson:"score"`
+	Direction string  `json:"direction" bson:"direction"`
+	Severity  string  `json:"severity" bson:"severity"`
+	CreatedAt int64   `json:"createdAt" bson:"createdAt"`
+}
+
+type ScheduleEventData struct {
+	AppId     string `json:"appId"`
+	Timestamp int64  `json:"timestamp"`
+}
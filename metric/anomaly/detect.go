@@ -0,0 +1,61 @@
+package anomaly
+
+import (
+	"math"
+	"sort"
+)
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// baselineOf returns the values of the same weekday of the previous weeks, dates without data
+// are left out so that a counter younger than the baseline is not compared to zeros
+func baselineOf(values map[int64]float64, date int64) []float64 {
+	const week = 7 * 24 * 3600
+	baseline := make([]float64, 0, baselineWeeks)
+	for i := int64(1); i <= baselineWeeks; i++ {
+		if value, ok := values[date-i*week]; ok {
+			baseline = append(baseline, value)
+		}
+	}
+	return baseline
+}
+
+// detect compares the value of the date to the median and the median absolute deviation of the
+// baseline. A missing value of the date counts as zero.
+func detect(values map[int64]float64, date int64) (a Anomaly, found bool) {
+	baseline := baselineOf(values, date)
+	if len(baseline) < minBaselinePoints {
+		return a, false
+	}
+	m := median(baseline)
+	if m < minBaselineMedian {
+		return a, false
+	}
+	deviations := make([]float64, len(baseline))
+	for i, value := range baseline {
+		deviations[i] = math.Abs(value - m)
+	}
+	scale := math.Max(madScale*median(deviations), minScaleRatio*m)
+
+	value := values[date]
+	score := (value - m) / scale
+	for _, s := range severityScores {
+		if math.Abs(score) >= s.score {
+			a = Anomaly{Date: date, Value: value, Baseline: m, Score: score, Severity: s.severity, Direction: DirectionUp}
+			if score < 0 {
+				a.Direction = DirectionDown
+			}
+			return a, true
+		}
+	}
+	return a, false
+}
@@ -0,0 +1,100 @@
+package anomaly
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/metric/user"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const (
+	appId = "testAppId"
+	day   = 24 * 3600
+)
+
+// weeklyValues returns 8 weeks of daily values before the date, higher on the weekday of the date
+func weeklyValues(date int64) map[int64]float64 {
+	values := make(map[int64]float64)
+	for i := int64(1); i <= 8*7; i++ {
+		values[date-i*day] = 100 + float64(i%3)
+		if i%7 == 0 {
+			values[date-i*day] = 200 + float64(i%3)
+		}
+	}
+	return values
+}
+
+func TestDetect(t *testing.T) {
+	date := utils.TodayDiff(1).Unix()
+
+	// a weekday peak is not an anomaly
+	values := weeklyValues(date)
+	values[date] = 205
+	_, found := detect(values, date)
+	require.False(t, found)
+
+	values[date] = 100
+	a, found := detect(values, date)
+	require.True(t, found)
+	require.Equal(t, DirectionDown, a.Direction)
+	require.Equal(t, SeverityHigh, a.Severity)
+	require.Equal(t, 201.0, a.Baseline)
+
+	values[date] = 262
+	a, found = detect(values, date)
+	require.True(t, found)
+	require.Equal(t, DirectionUp, a.Direction)
+	require.Equal(t, SeverityMedium, a.Severity)
+
+	// a missing value is zero
+	delete(values, date)
+	a, found = detect(values, date)
+	require.True(t, found)
+	require.Equal(t, 0.0, a.Value)
+
+	// too short a baseline
+	short := map[int64]float64{date - 7*day: 100, date - 14*day: 100, date: 0}
+	_, found = detect(short, date)
+	require.False(t, found)
+
+	// too small a counter
+	small := make(map[int64]float64)
+	for i := int64(1); i <= 8; i++ {
+		small[date-i*7*day] = 2
+	}
+	_, found = detect(small, date)
+	require.False(t, found)
+}
+
+func TestCheckCounters(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, "test_")
+	defer client.Database("test_" + appId).Drop(context.Background())
+	defer store.DropAllCounter(appId)
+
+	date := utils.TodayDiff(1).Unix()
+	for d, count := range weeklyValues(date) {
+		require.NoError(t, store.SetSimpleCPVCounter(appId, "c", "ios", "1.0", user.DailyActiveCPVCounter, d, count))
+		require.NoError(t, store.SetSimpleCounter(appId, "login"+storage.CustomizedCounterNameSuffix, d, count))
+	}
+	// daily active users halve, the customized counter is as usual
+	require.NoError(t, store.SetSimpleCPVCounter(appId, "c", "ios", "1.0", user.DailyActiveCPVCounter, date, 100))
+	require.NoError(t, store.SetSimpleCounter(appId, "login"+storage.CustomizedCounterNameSuffix, date, 201))
+	require.NoError(t, store.AddCustomizedCounter(appId, storage.CustomizedCounter{Name: "login", DisplayName: "Login", Type: "simple"}))
+
+	anomalies, err := checkCounters(store, appId, date)
+	require.NoError(t, err)
+	require.Len(t, anomalies, 1)
+	require.Equal(t, user.DailyActiveCPVCounter, anomalies[0].Counter)
+
+	// checking again replaces the anomalies of the date
+	_, err = checkCounters(store, appId, date)
+	require.NoError(t, err)
+	saved, err := store.getAnomalies(appId, date, date)
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	require.Equal(t, SeverityHigh, saved[0].Severity)
+}
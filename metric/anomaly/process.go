@@ -0,0 +1,47 @@
+package anomaly
+
+import (
+	"errors"
+	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/sirupsen/logrus"
+)
+
+func SetupProcessor(subscriber pubsub.Subscriber, store Store) {
+	err := subscriber.Subscribe(ScheduleEvent, scheduleEventHandler(store), ScheduleEventData{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func scheduleEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		eventData, ok := data.(*ScheduleEventData)
+		if !ok {
+			return errors.New("AnomalyScheduleEventHandler: data is not of type *ScheduleEventData")
+		}
+		entry := logrus.WithFields(logrus.Fields{"data": eventData})
+		appId, date := eventData.AppId, eventData.Timestamp
+		checked, err := store.IsDailyProcessed(appId, ScheduleEvent, date)
+		if err != nil || checked {
+			return err
+		}
+		processed, err := dailyProcessed(store, appId, date)
+		if err != nil {
+			entry.Warn("dailyProcessed error: ", err.Error())
+			return err
+		}
+		if !processed {
+			entry.Debug("Daily computations are not done yet")
+			return nil
+		}
+		anomalies, err := checkCounters(store, appId, date)
+		if err != nil {
+			entry.Warn("checkCounters error: ", err.Error())
+			return err
+		}
+		for _, a := range anomalies {
+			entry.WithFields(logrus.Fields{"anomaly": a}).Info("Counter anomaly")
+		}
+		return store.MarkDailyProcessed(appId, ScheduleEvent, date)
+	})
+}
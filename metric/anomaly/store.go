@@ -0,0 +1,81 @@
+package anomaly
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store reads the counters of metric/user, metric/usage and the customized counters
+type Store interface {
+	storage.Counter
+	storage.DailyTracker
+	// saveAnomalies replaces the anomalies of the date
+	saveAnomalies(appId string, date int64, anomalies []Anomaly) error
+	// getAnomalies returns the anomalies between the dates start and end, latest first
+	getAnomalies(appId string, start, end int64) ([]Anomaly, error)
+}
+
+const (
+	anomalyCollectionName = "anomalyCollection"
+)
+
+type mongodbStore struct {
+	storage.Counter
+	storage.DailyTracker
+	client         *mongo.Client
+	databasePrefix string
+}
+
+func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
+	return &mongodbStore{
+		Counter:        mongodb.NewCounter(client, databasePrefix),
+		DailyTracker:   mongodb.NewDailyTracker(client, databasePrefix),
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (ms *mongodbStore) database(appId string) *mongo.Database {
+	return ms.client.Database(ms.databasePrefix + appId)
+}
+
+func (ms *mongodbStore) saveAnomalies(appId string, date int64, anomalies []Anomaly) error {
+	ctx := context.Background()
+	collection := ms.database(appId).Collection(anomalyCollectionName)
+	if _, err := collection.DeleteMany(ctx, bson.M{"date": date}); err != nil {
+		return err
+	}
+	if len(anomalies) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, len(anomalies))
+	for i, a := range anomalies {
+		documents[i] = a
+	}
+	_, err := collection.InsertMany(ctx, documents)
+	return err
+}
+
+func (ms *mongodbStore) getAnomalies(appId string, start, end int64) ([]Anomaly, error) {
+	ctx := context.Background()
+	filter := bson.M{"date": bson.M{"$gte": start, "$lte": end}}
+	option := options.Find().SetSort(bson.D{{Key: "date", Value: -1}, {Key: "counter", Value: 1}})
+	cursor, err := ms.database(appId).Collection(anomalyCollectionName).Find(ctx, filter, option)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	anomalies := make([]Anomaly, 0)
+	for cursor.Next(ctx) {
+		var a Anomaly
+		if err = cursor.Decode(&a); err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, a)
+	}
+	return anomalies, cursor.Err()
+}
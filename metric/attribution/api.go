@@ -0,0 +1,171 @@
+package attribution
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// SetupRoute installs the tracking link redirect on cRoute, which must not require any
+// authentication, and the campaign management on oRoute. The tracking link of a campaign
+// is <cRoute>/<appId>/<campaignId>.
+func SetupRoute(cRoute *gin.RouterGroup, oRoute *gin.RouterGroup, store Store) {
+	cRoute.GET("/:appId/:campaignId", trackingLinkHandler(store))
+
+	requireAdminRole := middlewares.RequireRoleMiddleware([]string{"admin"})
+	oGroup := oRoute.Group("/attribution")
+	oGroup.GET("/campaign", getCampaignsHandler(store))
+	oGroup.POST("/campaign", requireAdminRole, createCampaignHandler(store))
+	oGroup.DELETE("/campaign", requireAdminRole, deleteCampaignHandler(store))
+	oGroup.GET("/report", campaignReportHandler(store))
+}
+
+// trackingLinkHandler records the click and redirects to the campaign destination with the
+// click id as the referrer
+func trackingLinkHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.Param("appId")
+		entry := log.WithFields(log.Fields{"appId": appId, "campaignId": c.Param("campaignId")})
+		campaign, found, err := store.getCampaign(appId, c.Param("campaignId"))
+		if err != nil {
+			entry.Warn("getCampaign error: ", err.Error())
+			c.Set("error", err)
+			return
+		}
+		if !found {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		userAgent := c.Request.UserAgent()
+		platform := platformOfUserAgent(userAgent)
+		destination, err := url.Parse(campaign.destination(platform))
+		if err != nil {
+			entry.Warn("campaign destination error: ", err.Error())
+			c.Set("error", err)
+			return
+		}
+
+		clickId, err := utils.RandomHexStringKey(32)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		now := utils.NowTimestamp()
+		err = store.saveClick(appId, click{
+			Id:          clickId,
+			CampaignId:  campaign.Id,
+			Fingerprint: Fingerprint(c.ClientIP(), platform),
+			ClientIP:    c.ClientIP(),
+			Platform:    platform,
+			UserAgent:   userAgent,
+			Timestamp:   now,
+		})
+		if err != nil {
+			// the user still gets to the store, the install can not be attributed though
+			entry.Warn("saveClick error: ", err.Error())
+		} else {
+			store.AddSlotCounter(appId, CampaignClickSlotCounter, campaign.Id, utils.TimestampToDate(now).Unix(), 1.0)
+		}
+
+		query := destination.Query()
+		query.Set(ReferrerQueryKey, clickId)
+		destination.RawQuery = query.Encode()
+		c.Redirect(http.StatusFound, destination.String())
+	}
+}
+
+func getCampaignsHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		campaigns, err := store.getCampaigns(appId)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", campaigns)
+		}
+	}
+}
+
+func isUrlValid(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func createCampaignHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var requestData campaignRequestData
+		err := c.ShouldBindJSON(&requestData)
+		if err != nil || requestData.Name == "" || !isUrlValid(requestData.Url) {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		for _, u := range requestData.PlatformUrls {
+			if !isUrlValid(u) {
+				c.Set("error", utils.ParamError)
+				return
+			}
+		}
+
+		id, err := utils.RandomHexStringKey(16)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		campaign := Campaign{
+			Id:           id,
+			Name:         requestData.Name,
+			Channel:      requestData.Channel,
+			Url:          requestData.Url,
+			PlatformUrls: requestData.PlatformUrls,
+			CreatedAt:    utils.NowTimestamp(),
+		}
+		if err = store.createCampaign(appId, campaign); err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", campaign)
+	}
+}
+
+func deleteCampaignHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var tmp struct {
+			Id string `json:"id"`
+		}
+		if err := c.ShouldBindJSON(&tmp); err != nil || tmp.Id == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if err := store.deleteCampaign(appId, tmp.Id); err != nil {
+			c.Set("error", err)
+		}
+	}
+}
+
+// campaignReportHandler reports the clicks and the attributed new users of each campaign
+// between start and end
+func campaignReportHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		if err1 != nil || err2 != nil || start > end {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		reports, err := getCampaignReports(store, appId, start, end)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", reports)
+	}
+}
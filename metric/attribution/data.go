@@ -0,0 +1,95 @@
+package attribution
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+const (
+	// clicks of the tracking links per day, slotted by campaign id
+	CampaignClickSlotCounter = "CampaignClickSlotCounter"
+	// counters below are maintained by metric/user, slotted by campaign id
+	CampaignNewUserSlotCounter     = "CampaignNewUserSlotCounter"
+	CampaignDailyActiveSlotCounter = "CampaignDailyActiveSlotCounter"
+	// suffixed by campaign id, slotted by retention day on the install date
+	CampaignNewUserRetentionSlotCounterPrefix = "campaignNewUserRetentionSlotCounter_"
+)
+
+const (
+	// the click id is passed to the store in this query parameter of the destination url, and
+	// reported back by the sdk on the first open as the referrer
+	ReferrerQueryKey = "referrer"
+
+	// how a new user is matched to a click
+	MatchedByReferrer    = "referrer"
+	MatchedByFingerprint = "fingerprint"
+)
+
+type Campaign struct {
+	Id      string `json:"id" bson:"_id"`
+	Name    string `json:"name" bson:"name"`
+	Channel string `json:"channel" bson:"channel"`
+	// destination of the tracking link
+	Url string `json:"url" bson:"url"`
+	// destination by platform, e.g. the app store and the play store page, Url otherwise
+	PlatformUrls map[string]string `json:"platformUrls" bson:"platformUrls"`
+	CreatedAt    int64             `json:"createdAt" bson:"createdAt"`
+}
+
+func (c Campaign) destination(platform string) string {
+	if url, ok := c.PlatformUrls[platform]; ok {
+		return url
+	}
+	return c.Url
+}
+
+type campaignRequestData struct {
+	Name         string            `json:"name"`
+	Channel      string            `json:"channel"`
+	Url          string            `json:"url"`
+	PlatformUrls map[string]string `json:"platformUrls"`
+}
+
+type click struct {
+	Id          string `bson:"_id"`
+	CampaignId  string `bson:"campaignId"`
+	Fingerprint string `bson:"fingerprint"`
+	ClientIP    string `bson:"clientIp"`
+	Platform    string `bson:"platform"`
+	UserAgent   string `bson:"userAgent"`
+	Timestamp   int64  `bson:"timestamp"`
+	// set when the click is matched to a new user
+	DeviceId string    `bson:"deviceId,omitempty"`
+	ExpireAt time.Time `bson:"expireAt"`
+}
+
+type CampaignReport struct {
+	CampaignId string  `json:"campaignId"`
+	Name       string  `json:"name"`
+	Channel    string  `json:"channel"`
+	Clicks     float64 `json:"clicks"`
+	NewUsers   float64 `json:"newUsers"`
+	// new users per click
+	ConversionRate float64 `json:"conversionRate"`
+}
+
+// Fingerprint identifies a device by its ip and platform, both known to the click and to the
+// first open. It is only good enough to match a click shortly before an install.
+func Fingerprint(ip, platform string) string {
+	hash := sha1.Sum([]byte(ip + "|" + platform))
+	return hex.EncodeToString(hash[:])
+}
+
+// platformOfUserAgent returns the platform of a browser user agent, empty when unknown
+func platformOfUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return "ios"
+	case strings.Contains(ua, "android"):
+		return "android"
+	}
+	return ""
+}
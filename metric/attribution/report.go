@@ -0,0 +1,69 @@
+package attribution
+
+import "sort"
+
+func sumSlotCounterSpan(store Store, appId, counterName string, start, end int64) (map[string]float64, error) {
+	span, err := store.GetSlotCounterSpan(appId, counterName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]float64)
+	for _, slotCounter := range span {
+		for slot, count := range slotCounter {
+			sums[slot] += count
+		}
+	}
+	return sums, nil
+}
+
+// getCampaignReports returns the reports of the campaigns with clicks or new users between
+// start and end, sorted by new users
+func getCampaignReports(store Store, appId string, start, end int64) ([]CampaignReport, error) {
+	clicks, err := sumSlotCounterSpan(store, appId, CampaignClickSlotCounter, start, end)
+	if err != nil {
+		return nil, err
+	}
+	newUsers, err := sumSlotCounterSpan(store, appId, CampaignNewUserSlotCounter, start, end)
+	if err != nil {
+		return nil, err
+	}
+	campaigns, err := store.getCampaigns(appId)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]Campaign)
+	for _, campaign := range campaigns {
+		byId[campaign.Id] = campaign
+	}
+
+	ids := make(map[string]bool)
+	for id := range clicks {
+		ids[id] = true
+	}
+	for id := range newUsers {
+		ids[id] = true
+	}
+	reports := make([]CampaignReport, 0, len(ids))
+	for id := range ids {
+		// deleted campaigns are reported by id only
+		campaign := byId[id]
+		report := CampaignReport{
+			CampaignId: id,
+			Name:       campaign.Name,
+			Channel:    campaign.Channel,
+			Clicks:     clicks[id],
+			NewUsers:   newUsers[id],
+		}
+		if report.Clicks > 0 {
+			report.ConversionRate = report.NewUsers / report.Clicks
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].NewUsers != reports[j].NewUsers {
+			return reports[i].NewUsers > reports[j].NewUsers
+		}
+		return reports[i].CampaignId < reports[j].CampaignId
+	})
+	return reports, nil
+}
@@ -0,0 +1,112 @@
+package attribution
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	storage.Counter
+	createCampaign(appId string, campaign Campaign) error
+	getCampaign(appId, campaignId string) (campaign Campaign, found bool, err error)
+	getCampaigns(appId string) ([]Campaign, error)
+	deleteCampaign(appId, campaignId string) error
+	saveClick(appId string, c click) error
+}
+
+const (
+	campaignCollectionName = "campaignCollection"
+	// clicks are matched to new users by metric/user
+	ClickCollectionName = "campaignClickCollection"
+
+	// clicks older than this can not be matched, the attribution windows must be shorter
+	clickRetention = 30 * 24 * time.Hour
+)
+
+type mongodbStore struct {
+	storage.Counter
+	client         *mongo.Client
+	databasePrefix string
+	// apps whose click ttl index has been created
+	indexed sync.Map
+}
+
+func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
+	return &mongodbStore{
+		Counter:        mongodb.NewCounter(client, databasePrefix),
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (ms *mongodbStore) database(appId string) *mongo.Database {
+	return ms.client.Database(ms.databasePrefix + appId)
+}
+
+func (ms *mongodbStore) createCampaign(appId string, campaign Campaign) error {
+	_, err := ms.database(appId).Collection(campaignCollectionName).InsertOne(context.Background(), campaign)
+	return err
+}
+
+func (ms *mongodbStore) getCampaign(appId, campaignId string) (campaign Campaign, found bool, err error) {
+	result := ms.database(appId).Collection(campaignCollectionName).FindOne(context.Background(), bson.M{"_id": campaignId})
+	err = result.Decode(&campaign)
+	if err == mongo.ErrNoDocuments {
+		return campaign, false, nil
+	}
+	return campaign, err == nil, err
+}
+
+func (ms *mongodbStore) getCampaigns(appId string) ([]Campaign, error) {
+	ctx := context.Background()
+	option := options.Find().SetSort(bson.M{"createdAt": -1})
+	cursor, err := ms.database(appId).Collection(campaignCollectionName).Find(ctx, bson.M{}, option)
+	if err != nil {
+		return nil, err
+	}
+	campaigns := make([]Campaign, 0)
+	for cursor.Next(ctx) {
+		var campaign Campaign
+		if err = cursor.Decode(&campaign); err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, cursor.Err()
+}
+
+// deleteCampaign deletes the link only, users already attributed to the campaign keep it
+func (ms *mongodbStore) deleteCampaign(appId, campaignId string) error {
+	_, err := ms.database(appId).Collection(campaignCollectionName).DeleteOne(context.Background(), bson.M{"_id": campaignId})
+	return err
+}
+
+func (ms *mongodbStore) ensureClickIndex(appId string) error {
+	if _, ok := ms.indexed.Load(appId); ok {
+		return nil
+	}
+	_, err := ms.database(appId).Collection(ClickCollectionName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"expireAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+	ms.indexed.Store(appId, true)
+	return nil
+}
+
+func (ms *mongodbStore) saveClick(appId string, c click) error {
+	if err := ms.ensureClickIndex(appId); err != nil {
+		return err
+	}
+	c.ExpireAt = time.Unix(c.Timestamp, 0).Add(clickRetention)
+	_, err := ms.database(appId).Collection(ClickCollectionName).InsertOne(context.Background(), c)
+	return err
+}
@@ -0,0 +1,77 @@
+package attribution
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const (
+	appId = "testAppId"
+)
+
+func TestTrackingLink(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, "test_")
+	defer client.Database("test_" + appId).Drop(context.Background())
+	defer store.DropAllCounter(appId)
+
+	campaign := Campaign{
+		Id:           "c1",
+		Name:         "spring",
+		Url:          "https://example.com/app",
+		PlatformUrls: map[string]string{"android": "https://play.google.com/store/apps/details?id=foo"},
+	}
+	require.NoError(t, store.createCampaign(appId, campaign))
+
+	router := gin.Default()
+	SetupRoute(router.Group("/c"), router.Group("/o"), store)
+
+	click := func(path, userAgent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := click("/c/"+appId+"/c1", "Mozilla/5.0 (Linux; Android 9; Pixel 3)")
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "play.google.com", location.Host)
+	require.Equal(t, "foo", location.Query().Get("id"))
+	require.NotEmpty(t, location.Query().Get(ReferrerQueryKey))
+
+	w = click("/c/"+appId+"/c1", "Mozilla/5.0 (Windows NT 10.0)")
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Contains(t, w.Header().Get("Location"), "https://example.com/app?referrer=")
+
+	w = click("/c/"+appId+"/unknown", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	count, err := client.Database("test_"+appId).Collection(ClickCollectionName).CountDocuments(context.Background(), map[string]interface{}{})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	today := utils.TodayTimestamp()
+	store.AddSlotCounter(appId, CampaignNewUserSlotCounter, "c1", today, 1.0)
+	reports, err := getCampaignReports(store, appId, today, today)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.Equal(t, "spring", reports[0].Name)
+	require.Equal(t, 2.0, reports[0].Clicks)
+	require.Equal(t, 0.5, reports[0].ConversionRate)
+}
+
+func TestPlatformOfUserAgent(t *testing.T) {
+	require.Equal(t, "ios", platformOfUserAgent("Mozilla/5.0 (iPhone; CPU iPhone OS 12_2 like Mac OS X)"))
+	require.Equal(t, "android", platformOfUserAgent("Mozilla/5.0 (Linux; Android 9; Pixel 3)"))
+	require.Equal(t, "", platformOfUserAgent("curl/7.64.0"))
+}
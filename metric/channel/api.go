@@ -0,0 +1,94 @@
+package channel
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func SetupRoute(oRoute *gin.RouterGroup, store Store, appConfigGetter middlewares.AppConfigGetter) {
+	oGroup := oRoute.Group("/channel")
+	oGroup.GET("/report", reportHandler(store, appConfigGetter))
+}
+
+func isColumnValid(column string) bool {
+	for _, c := range reportColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// reportHandler returns the channel quality report of the users installed between start and
+// end, sorted by the sort query column, in the order query, asc or desc. The report is exported
+// as csv with the format query csv.
+func reportHandler(store Store, appConfigGetter middlewares.AppConfigGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		if err1 != nil || err2 != nil || start > end {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		column := c.DefaultQuery("sort", "newUsers")
+		order := c.DefaultQuery("order", "desc")
+		format := c.DefaultQuery("format", "json")
+		if !isColumnValid(column) || (order != "asc" && order != "desc") || (format != "json" && format != "csv") {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		retentionDays := middlewares.RetentionDaysOf(appConfigGetter, appId)
+		reports, err := getReports(store, appId, start, end, utils.TodayTimestamp(), retentionDays)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		sortReports(reports, column, order == "desc")
+
+		if format == "json" {
+			c.Set("data", reports)
+			return
+		}
+		filename := fmt.Sprintf("channel_report_%d_%d.csv", start, end)
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err = writeCSV(csv.NewWriter(c.Writer), reports); err != nil {
+			log.WithFields(log.Fields{"appId": appId, "error": err.Error()}).Warn("write channel report csv error")
+		}
+	}
+}
+
+// csvCell escapes the cells read as formulas by spreadsheet applications
+func csvCell(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@") {
+		return "'" + value
+	}
+	return value
+}
+
+func writeCSV(w *csv.Writer, reports []Report) error {
+	if err := w.Write(reportColumns); err != nil {
+		return err
+	}
+	for _, report := range reports {
+		record := make([]string, 0, len(reportColumns))
+		for _, column := range reportColumns {
+			record = append(record, csvCell(report.cell(column)))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
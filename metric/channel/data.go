@@ -0,0 +1,77 @@
+package channel
+
+import "strconv"
+
+// Report is the quality of the users installed from a channel between the report dates
+type Report struct {
+	Channel  string  `json:"channel"`
+	NewUsers float64 `json:"newUsers"`
+	// new user retention of the install dates whose retention day has passed, null when the
+	// retention day is not tracked for the app or has not passed for any install date
+	Retention1  *float64 `json:"retention1"`
+	Retention7  *float64 `json:"retention7"`
+	Retention30 *float64 `json:"retention30"`
+	// usage seconds per daily active user
+	AverageUsageTime float64 `json:"averageUsageTime"`
+	// devices installed between the dates that registered
+	RegistrationRate float64 `json:"registrationRate"`
+	// paying users per daily active user
+	PayingRate float64 `json:"payingRate"`
+}
+
+var reportRetentionDays = []int{1, 7, 30}
+
+// reportColumns are the report fields, by their json name, in the order of the csv export
+var reportColumns = []string{"channel", "newUsers", "retention1", "retention7", "retention30", "averageUsageTime",
+	"registrationRate", "payingRate"}
+
+func valueOf(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+// value returns the field of the report by json name, the channel is not a number and
+// retention missing is 0
+func (r Report) value(column string) float64 {
+	switch column {
+	case "newUsers":
+		return r.NewUsers
+	case "retention1":
+		return valueOf(r.Retention1)
+	case "retention7":
+		return valueOf(r.Retention7)
+	case "retention30":
+		return valueOf(r.Retention30)
+	case "averageUsageTime":
+		return r.AverageUsageTime
+	case "registrationRate":
+		return r.RegistrationRate
+	case "payingRate":
+		return r.PayingRate
+	}
+	return 0
+}
+
+// cell returns the field of the report by json name as a csv cell, empty when retention is missing
+func (r Report) cell(column string) string {
+	var value *float64
+	switch column {
+	case "channel":
+		return r.Channel
+	case "retention1":
+		value = r.Retention1
+	case "retention7":
+		value = r.Retention7
+	case "retention30":
+		value = r.Retention30
+	default:
+		v := r.value(column)
+		value = &v
+	}
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
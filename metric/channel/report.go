@@ -0,0 +1,151 @@
+package channel
+
+import (
+	"github.com/lt90s/goanalytics/metric/revenue"
+	"github.com/lt90s/goanalytics/metric/usage"
+	"github.com/lt90s/goanalytics/metric/user"
+	"sort"
+	"strconv"
+)
+
+const day = 24 * 3600
+
+// channelDates returns the cpv counter between start and end by channel and date
+func channelDates(store Store, appId, counterName string, start, end int64) (map[string]map[int64]float64, error) {
+	dateCPV, err := store.GetSimpleCPVDateCPV(appId, counterName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	channels := make(map[string]map[int64]float64)
+	for date, counts := range dateCPV["channel"] {
+		for channel, count := range counts {
+			if channels[channel] == nil {
+				channels[channel] = make(map[int64]float64)
+			}
+			channels[channel][date] = count
+		}
+	}
+	return channels, nil
+}
+
+func sumByChannel(store Store, appId, counterName string, start, end int64) (map[string]float64, error) {
+	channels, err := channelDates(store, appId, counterName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]float64, len(channels))
+	for channel, dates := range channels {
+		for _, count := range dates {
+			sums[channel] += count
+		}
+	}
+	return sums, nil
+}
+
+func rate(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+func isTracked(retentionDays []int, retentionDay int) bool {
+	for _, day := range retentionDays {
+		if day == retentionDay {
+			return true
+		}
+	}
+	return false
+}
+
+// retentionRates returns the retention of the report days of the channel's new users, only the
+// install dates whose retention day is before today are counted. The retention of a report day
+// is nil when the app does not track it, see metric/user, or when it has not passed for any
+// install date.
+func retentionRates(store Store, appId, channel string, newUsers map[int64]float64, start, end, today int64,
+	retentionDays []int) ([]*float64, error) {
+	span, err := store.GetSlotCounterSpan(appId, user.ChannelNewUserRetentionSlotCounterPrefix+channel, start, end)
+	if err != nil {
+		return nil, err
+	}
+	rates := make([]*float64, len(reportRetentionDays))
+	for i, retentionDay := range reportRetentionDays {
+		if !isTracked(retentionDays, retentionDay) {
+			continue
+		}
+		var retained, installed float64
+		passed := false
+		slot := strconv.Itoa(retentionDay)
+		for date, count := range newUsers {
+			if date+int64(retentionDay)*day >= today {
+				continue
+			}
+			passed = true
+			installed += count
+			retained += span[date][slot]
+		}
+		if passed {
+			r := rate(retained, installed)
+			rates[i] = &r
+		}
+	}
+	return rates, nil
+}
+
+// getReports returns the reports of the channels with new users or active users between start
+// and end, sorted by new users. retentionDays are the retention days tracked for the app.
+func getReports(store Store, appId string, start, end, today int64, retentionDays []int) ([]Report, error) {
+	newUsers, err := channelDates(store, appId, user.NewUserCPVCounter, start, end)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]map[string]float64)
+	for _, counterName := range []string{user.RegisteredDeviceCPVCounter, user.DailyActiveCPVCounter,
+		usage.UsageTimeCPVCounter, revenue.PayingUserCPVCounter} {
+		sums[counterName], err = sumByChannel(store, appId, counterName, start, end)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dailyActive := sums[user.DailyActiveCPVCounter]
+
+	channels := make(map[string]bool)
+	for channel := range newUsers {
+		channels[channel] = true
+	}
+	for channel := range dailyActive {
+		channels[channel] = true
+	}
+	reports := make([]Report, 0, len(channels))
+	for channel := range channels {
+		report := Report{Channel: channel}
+		for _, count := range newUsers[channel] {
+			report.NewUsers += count
+		}
+		retention, err := retentionRates(store, appId, channel, newUsers[channel], start, end, today, retentionDays)
+		if err != nil {
+			return nil, err
+		}
+		report.Retention1, report.Retention7, report.Retention30 = retention[0], retention[1], retention[2]
+		report.AverageUsageTime = rate(sums[usage.UsageTimeCPVCounter][channel], dailyActive[channel])
+		report.RegistrationRate = rate(sums[user.RegisteredDeviceCPVCounter][channel], report.NewUsers)
+		report.PayingRate = rate(sums[revenue.PayingUserCPVCounter][channel], dailyActive[channel])
+		reports = append(reports, report)
+	}
+	sortReports(reports, "newUsers", true)
+	return reports, nil
+}
+
+// sortReports sorts the reports by the column, ties are sorted by channel
+func sortReports(reports []Report, column string, descending bool) {
+	sort.Slice(reports, func(i, j int) bool {
+		a, b := reports[i], reports[j]
+		if column != "channel" && a.value(column) != b.value(column) {
+			return (a.value(column) > b.value(column)) == descending
+		}
+		if column == "channel" && descending {
+			return a.Channel > b.Channel
+		}
+		return a.Channel < b.Channel
+	})
+}
@@ -0,0 +1,98 @@
+package channel
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/metric/revenue"
+	"github.com/lt90s/goanalytics/metric/usage"
+	"github.com/lt90s/goanalytics/metric/user"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	appId = "testAppId"
+)
+
+type mockAppConfigGetter struct{}
+
+func (m mockAppConfigGetter) GetAppConfig(id string) (middlewares.AppConfig, error) {
+	return middlewares.AppConfig{RetentionDays: []int{1, 7}}, nil
+}
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestGetReports(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, "test_")
+	defer client.Database("test_" + appId).Drop(context.Background())
+	defer store.DropAllCounter(appId)
+
+	today := utils.TodayTimestamp()
+	install := utils.TodayDiff(10).Unix()
+	recent := utils.TodayDiff(3).Unix()
+	add := func(channel, counterName string, date int64, amount float64) {
+		require.NoError(t, store.AddSimpleCPVCounter(appId, channel, "android", "1.0.0", counterName, date, amount))
+	}
+	add("a", user.NewUserCPVCounter, install, 10)
+	add("a", user.NewUserCPVCounter, recent, 10)
+	add("a", user.RegisteredDeviceCPVCounter, install, 5)
+	add("a", user.DailyActiveCPVCounter, install, 10)
+	add("a", user.DailyActiveCPVCounter, recent, 10)
+	add("a", usage.UsageTimeCPVCounter, install, 600)
+	add("a", usage.UsageTimeCPVCounter, recent, 400)
+	add("a", revenue.PayingUserCPVCounter, recent, 2)
+	add("b", user.NewUserCPVCounter, install, 4)
+	add("b", user.DailyActiveCPVCounter, install, 4)
+	add("=cmd", user.NewUserCPVCounter, install, 1)
+	prefix := user.ChannelNewUserRetentionSlotCounterPrefix
+	store.AddSlotCounter(appId, prefix+"a", "1", install, 5)
+	store.AddSlotCounter(appId, prefix+"a", "7", install, 2)
+	store.AddSlotCounter(appId, prefix+"a", "1", recent, 10)
+	store.AddSlotCounter(appId, prefix+"b", "1", install, 3)
+
+	reports, err := getReports(store, appId, install, recent, today, []int{1, 7})
+	require.NoError(t, err)
+	require.Len(t, reports, 3)
+	// day 7 of the recent install date has not passed, day 30 is not tracked
+	require.Equal(t, Report{
+		Channel:          "a",
+		NewUsers:         20,
+		Retention1:       float(0.75),
+		Retention7:       float(0.2),
+		Retention30:      nil,
+		AverageUsageTime: 50,
+		RegistrationRate: 0.25,
+		PayingRate:       0.1,
+	}, reports[0])
+	require.Equal(t, "b", reports[1].Channel)
+
+	sortReports(reports, "retention7", false)
+	require.Equal(t, "a", reports[2].Channel)
+	sortReports(reports, "channel", false)
+	require.Equal(t, "a", reports[1].Channel)
+
+	router := gin.Default()
+	SetupRoute(router.Group("/o"), store, mockAppConfigGetter{})
+	query := "/o/channel/report?format=csv&sort=newUsers&order=asc&start=" + strconv.FormatInt(install, 10) +
+		"&end=" + strconv.FormatInt(recent, 10)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, query, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Equal(t, []string{
+		strings.Join(reportColumns, ","),
+		"'=cmd,1,0,0,,0,0,0",
+		"b,4,0.75,0,,0,0,0",
+		"a,20,0.75,0.2,,50,0.25,0.1",
+	}, lines)
+}
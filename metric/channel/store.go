@@ -0,0 +1,22 @@
+package channel
+
+import (
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Store reads the counters of metric/user, metric/usage and metric/revenue
+type Store interface {
+	storage.Counter
+}
+
+type mongodbStore struct {
+	storage.Counter
+}
+
+func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
+	return &mongodbStore{
+		Counter: mongodb.NewCounter(client, databasePrefix),
+	}
+}
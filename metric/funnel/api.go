@@ -0,0 +1,136 @@
+package funnel
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/utils"
+	"net/http"
+	"strconv"
+)
+
+func SetupRoute(oRoute *gin.RouterGroup, store Store) {
+	requireAdminRole := middlewares.RequireRoleMiddleware([]string{"admin"})
+	oGroup := oRoute.Group("/funnel")
+	oGroup.GET("", getFunnelsHandler(store))
+	oGroup.POST("", requireAdminRole, createFunnelHandler(store))
+	oGroup.DELETE("", requireAdminRole, deleteFunnelHandler(store))
+	oGroup.GET("/query", queryFunnelHandler(store))
+}
+
+func getFunnelsHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		funnels, err := store.getFunnels(appId)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", funnels)
+		}
+	}
+}
+
+func createFunnelHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var requestData funnelRequestData
+		if err := c.ShouldBindJSON(&requestData); err != nil {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		id, err := utils.RandomHexStringKey(16)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		f := Funnel{
+			Id:         id,
+			Name:       requestData.Name,
+			Steps:      requestData.Steps,
+			WindowDays: requestData.WindowDays,
+			CreatedAt:  utils.NowTimestamp(),
+		}
+		if !f.valid() {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if err = store.saveFunnel(appId, f); err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", f)
+	}
+}
+
+func deleteFunnelHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var tmp struct {
+			Id string `json:"id"`
+		}
+		if err := c.ShouldBindJSON(&tmp); err != nil || tmp.Id == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if err := store.deleteFunnel(appId, tmp.Id); err != nil {
+			c.Set("error", err)
+		}
+	}
+}
+
+// queryFunnelHandler computes the funnel for the devices entering it between the start and
+// end dates, optionally broken down by channel, platform or version
+func queryFunnelHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		if err1 != nil || err2 != nil || start > end {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		breakdown := c.Query("breakdown")
+		if breakdown != "" && breakdown != BreakdownChannel && breakdown != BreakdownPlatform &&
+			breakdown != BreakdownVersion {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		f, found, err := store.getFunnel(appId, c.Query("id"))
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		if !found {
+			c.Set("error", utils.NewHttpError(http.StatusNotFound, http.StatusNotFound, "Funnel not found"))
+			return
+		}
+
+		result, err := queryFunnel(store, appId, f, start, end, breakdown)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", result)
+	}
+}
+
+func queryFunnel(store Store, appId string, f Funnel, start, end int64, breakdown string) (Result, error) {
+	// end is the last entry date
+	end += 24 * 3600
+	window := int64(f.WindowDays) * 24 * 3600
+	counter := newFunnelCounter(f, start, end)
+	for i, step := range f.Steps {
+		var err error
+		if i == 0 {
+			err = store.scanOccurrences(appId, step, start, end, counter.enter)
+		} else {
+			err = store.scanOccurrences(appId, step, start, end+window, func(o occurrence) {
+				counter.advance(i, o)
+			})
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	return counter.result(breakdown), nil
+}
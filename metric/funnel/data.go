@@ -0,0 +1,112 @@
+package funnel
+
+const (
+	// the device is installed, i.e. seen for the first time
+	StepNewUser = "new_user"
+	StepOpenApp = "open_app"
+	// the device reports a user id for the first time
+	StepRegistration = "registration"
+	StepPurchase     = "purchase"
+	// a custom event reported with the customized counters, named by Event
+	StepCustom = "custom"
+)
+
+const (
+	BreakdownChannel  = "channel"
+	BreakdownPlatform = "platform"
+	BreakdownVersion  = "version"
+)
+
+const (
+	maxFunnelSteps      = 10
+	maxFunnelWindowDays = 90
+)
+
+type Step struct {
+	Type  string `json:"type" bson:"type"`
+	Event string `json:"event" bson:"event"`
+}
+
+func (s Step) name() string {
+	if s.Type == StepCustom {
+		return s.Event
+	}
+	return s.Type
+}
+
+func (s Step) valid() bool {
+	switch s.Type {
+	case StepNewUser, StepOpenApp, StepRegistration, StepPurchase:
+		return s.Event == ""
+	case StepCustom:
+		return s.Event != ""
+	}
+	return false
+}
+
+// Funnel is an ordered list of steps a device has to go through within WindowDays of the
+// first step
+type Funnel struct {
+	Id         string `json:"id" bson:"_id"`
+	Name       string `json:"name" bson:"name"`
+	Steps      []Step `json:"steps" bson:"steps"`
+	WindowDays int    `json:"windowDays" bson:"windowDays"`
+	CreatedAt  int64  `json:"createdAt" bson:"createdAt"`
+}
+
+func (f Funnel) valid() bool {
+	if f.Name == "" || len(f.Steps) < 2 || len(f.Steps) > maxFunnelSteps {
+		return false
+	}
+	if f.WindowDays <= 0 || f.WindowDays > maxFunnelWindowDays {
+		return false
+	}
+	for _, step := range f.Steps {
+		if !step.valid() {
+			return false
+		}
+	}
+	return true
+}
+
+type funnelRequestData struct {
+	Name       string `json:"name"`
+	Steps      []Step `json:"steps"`
+	WindowDays int    `json:"windowDays"`
+}
+
+// occurrence is a step done by a device
+type occurrence struct {
+	DeviceId  string `bson:"deviceId"`
+	Timestamp int64  `bson:"timestamp"`
+	Channel   string `bson:"channel"`
+	Platform  string `bson:"platform"`
+	Version   string `bson:"version"`
+}
+
+func (o occurrence) dimension(breakdown string) string {
+	switch breakdown {
+	case BreakdownChannel:
+		return o.Channel
+	case BreakdownPlatform:
+		return o.Platform
+	case BreakdownVersion:
+		return o.Version
+	}
+	return ""
+}
+
+type StepResult struct {
+	Name  string  `json:"name"`
+	Count float64 `json:"count"`
+	// rate from the first step
+	Conversion float64 `json:"conversion"`
+	// rate from the previous step
+	StepConversion float64 `json:"stepConversion"`
+}
+
+type Result struct {
+	Steps []StepResult `json:"steps"`
+	// results by the channel, platform or version of the first step
+	Breakdown map[string][]StepResult `json:"breakdown,omitempty"`
+}
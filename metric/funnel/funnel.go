@@ -0,0 +1,108 @@
+package funnel
+
+// progress is how far a device went through the funnel
+type progress struct {
+	entry occurrence
+	// timestamp of the latest step reached
+	last    int64
+	reached int
+}
+
+// funnelCounter counts the devices reaching each step. A device enters the funnel at its first
+// occurrence of the first step between start and end, every following step must occur after
+// the previous one and within window seconds of the entry.
+//
+// Occurrences are streamed step by step and sorted by timestamp, only the progress of the
+// devices entering the funnel is kept.
+type funnelCounter struct {
+	f       Funnel
+	window  int64
+	start   int64
+	end     int64
+	devices map[string]*progress
+}
+
+func newFunnelCounter(f Funnel, start, end int64) *funnelCounter {
+	return &funnelCounter{
+		f:       f,
+		window:  int64(f.WindowDays) * 24 * 3600,
+		start:   start,
+		end:     end,
+		devices: make(map[string]*progress),
+	}
+}
+
+// enter adds an occurrence of the first step
+func (fc *funnelCounter) enter(o occurrence) {
+	if o.Timestamp < fc.start || o.Timestamp >= fc.end {
+		return
+	}
+	if _, ok := fc.devices[o.DeviceId]; ok {
+		return
+	}
+	fc.devices[o.DeviceId] = &progress{entry: o, last: o.Timestamp, reached: 1}
+}
+
+// advance adds an occurrence of the step, the occurrences of the previous step must have been added
+func (fc *funnelCounter) advance(step int, o occurrence) {
+	p, ok := fc.devices[o.DeviceId]
+	// the device did not reach the previous step or already reached this one
+	if !ok || p.reached != step {
+		return
+	}
+	if o.Timestamp > p.entry.Timestamp+fc.window {
+		return
+	}
+	// a repeated step needs another occurrence
+	if o.Timestamp > p.last || (o.Timestamp == p.last && fc.f.Steps[step] != fc.f.Steps[step-1]) {
+		p.last = o.Timestamp
+		p.reached++
+	}
+}
+
+func (fc *funnelCounter) result(breakdown string) Result {
+	total := make([]float64, len(fc.f.Steps))
+	byDimension := make(map[string][]float64)
+	for _, p := range fc.devices {
+		var counts []float64
+		if breakdown != "" {
+			dimension := p.entry.dimension(breakdown)
+			counts = byDimension[dimension]
+			if counts == nil {
+				counts = make([]float64, len(fc.f.Steps))
+				byDimension[dimension] = counts
+			}
+		}
+		for step := 0; step < p.reached; step++ {
+			total[step]++
+			if counts != nil {
+				counts[step]++
+			}
+		}
+	}
+
+	result := Result{Steps: stepResults(fc.f, total)}
+	if breakdown != "" {
+		result.Breakdown = make(map[string][]StepResult)
+		for dimension, counts := range byDimension {
+			result.Breakdown[dimension] = stepResults(fc.f, counts)
+		}
+	}
+	return result
+}
+
+func stepResults(f Funnel, counts []float64) []StepResult {
+	results := make([]StepResult, len(f.Steps))
+	for i, step := range f.Steps {
+		results[i] = StepResult{Name: step.name(), Count: counts[i]}
+		if counts[0] > 0 {
+			results[i].Conversion = counts[i] / counts[0]
+		}
+		if i == 0 {
+			results[i].StepConversion = results[i].Conversion
+		} else if counts[i-1] > 0 {
+			results[i].StepConversion = counts[i] / counts[i-1]
+		}
+	}
+	return results
+}
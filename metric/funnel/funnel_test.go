@@ -0,0 +1,81 @@
+package funnel
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// computeFunnel streams the occurrences of each step by device, sorted by timestamp
+func computeFunnel(f Funnel, occurrences []map[string][]occurrence, start, end int64, breakdown string) Result {
+	counter := newFunnelCounter(f, start, end)
+	for step, devices := range occurrences {
+		for deviceId, steps := range devices {
+			for _, o := range steps {
+				o.DeviceId = deviceId
+				if step == 0 {
+					counter.enter(o)
+				} else {
+					counter.advance(step, o)
+				}
+			}
+		}
+	}
+	return counter.result(breakdown)
+}
+
+func TestComputeFunnel(t *testing.T) {
+	day := int64(24 * 3600)
+	f := Funnel{
+		Steps: []Step{
+			{Type: StepNewUser},
+			{Type: StepRegistration},
+			{Type: StepPurchase},
+		},
+		WindowDays: 7,
+	}
+	occurrences := []map[string][]occurrence{
+		{
+			"a": {{Timestamp: 10, Channel: "appstore"}},
+			"b": {{Timestamp: 20, Channel: "appstore"}},
+			"c": {{Timestamp: 30, Channel: "google"}},
+			// entered before start
+			"d": {{Timestamp: -10, Channel: "google"}},
+		},
+		{
+			"a": {{Timestamp: 100}},
+			"b": {{Timestamp: 200}},
+			"c": {{Timestamp: 8 * day}},
+			"d": {{Timestamp: 100}},
+		},
+		{
+			// purchase before registration is not counted
+			"a": {{Timestamp: 50}, {Timestamp: 300}},
+			"b": {{Timestamp: 150}},
+		},
+	}
+
+	result := computeFunnel(f, occurrences, 0, day, BreakdownChannel)
+	require.Equal(t, []StepResult{
+		{Name: StepNewUser, Count: 3, Conversion: 1, StepConversion: 1},
+		{Name: StepRegistration, Count: 2, Conversion: 2.0 / 3, StepConversion: 2.0 / 3},
+		{Name: StepPurchase, Count: 1, Conversion: 1.0 / 3, StepConversion: 0.5},
+	}, result.Steps)
+	require.Equal(t, 2.0, result.Breakdown["appstore"][1].Count)
+	require.Equal(t, 1.0, result.Breakdown["appstore"][2].Count)
+	require.Equal(t, 0.0, result.Breakdown["google"][1].Count)
+}
+
+func TestComputeFunnel_RepeatedStep(t *testing.T) {
+	f := Funnel{
+		Steps:      []Step{{Type: StepOpenApp}, {Type: StepOpenApp}},
+		WindowDays: 1,
+	}
+	occurrences := []map[string][]occurrence{
+		{"a": {{Timestamp: 10}}, "b": {{Timestamp: 10}, {Timestamp: 20}}},
+		{"a": {{Timestamp: 10}}, "b": {{Timestamp: 10}, {Timestamp: 20}}},
+	}
+	result := computeFunnel(f, occurrences, 0, 100, "")
+	require.Equal(t, 2.0, result.Steps[0].Count)
+	require.Equal(t, 1.0, result.Steps[1].Count)
+	require.Nil(t, result.Breakdown)
+}
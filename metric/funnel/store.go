@@ -0,0 +1,127 @@
+package funnel
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Store interface {
+	saveFunnel(appId string, f Funnel) error
+	getFunnel(appId, id string) (f Funnel, found bool, err error)
+	getFunnels(appId string) ([]Funnel, error)
+	deleteFunnel(appId, id string) error
+	// scanOccurrences calls fn for the occurrences of the step between start and end sorted by
+	// timestamp, occurrences are streamed from a cursor
+	scanOccurrences(appId string, step Step, start, end int64, fn func(o occurrence)) error
+}
+
+const (
+	funnelCollectionName = "funnelCollection"
+	// maintained by metric/user
+	openAppDataCollectionName = "openAppData"
+	userCollectionName        = "userCollection"
+	// maintained by metric/revenue
+	purchaseCollectionName = "purchaseCollection"
+)
+
+type mongodbStore struct {
+	client         *mongo.Client
+	databasePrefix string
+}
+
+func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
+	return &mongodbStore{
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (ms *mongodbStore) database(appId string) *mongo.Database {
+	return ms.client.Database(ms.databasePrefix + appId)
+}
+
+func (ms *mongodbStore) saveFunnel(appId string, f Funnel) error {
+	_, err := ms.database(appId).Collection(funnelCollectionName).InsertOne(context.Background(), f)
+	return err
+}
+
+func (ms *mongodbStore) getFunnel(appId, id string) (f Funnel, found bool, err error) {
+	result := ms.database(appId).Collection(funnelCollectionName).FindOne(context.Background(), bson.M{"_id": id})
+	err = result.Decode(&f)
+	if err == mongo.ErrNoDocuments {
+		return f, false, nil
+	}
+	return f, err == nil, err
+}
+
+func (ms *mongodbStore) getFunnels(appId string) ([]Funnel, error) {
+	ctx := context.Background()
+	option := options.Find().SetSort(bson.M{"createdAt": -1})
+	cursor, err := ms.database(appId).Collection(funnelCollectionName).Find(ctx, bson.M{}, option)
+	if err != nil {
+		return nil, err
+	}
+	funnels := make([]Funnel, 0)
+	for cursor.Next(ctx) {
+		var f Funnel
+		if err = cursor.Decode(&f); err != nil {
+			return nil, err
+		}
+		funnels = append(funnels, f)
+	}
+	return funnels, cursor.Err()
+}
+
+func (ms *mongodbStore) deleteFunnel(appId, id string) error {
+	_, err := ms.database(appId).Collection(funnelCollectionName).DeleteOne(context.Background(), bson.M{"_id": id})
+	return err
+}
+
+func (ms *mongodbStore) scanOccurrences(appId string, step Step, start, end int64, fn func(o occurrence)) error {
+	collectionName, timestampField := openAppDataCollectionName, "timestamp"
+	match := bson.M{}
+	switch step.Type {
+	case StepNewUser:
+		collectionName, timestampField = userCollectionName, "createdAt"
+	case StepRegistration:
+		collectionName, timestampField = userCollectionName, "registeredAt"
+	case StepPurchase:
+		collectionName = purchaseCollectionName
+	case StepCustom:
+		collectionName = mongodb.EventCollectionName
+		match["name"] = step.Event
+	}
+	match[timestampField] = bson.M{"$gte": start, "$lt": end}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{
+			"$project": bson.M{
+				"deviceId":  1,
+				"timestamp": "$" + timestampField,
+				"channel":   1,
+				"platform":  1,
+				"version":   1,
+			},
+		},
+		{"$sort": bson.M{"timestamp": 1}},
+	}
+	ctx := context.Background()
+	option := options.Aggregate().SetAllowDiskUse(true)
+	cursor, err := ms.database(appId).Collection(collectionName).Aggregate(ctx, pipeline, option)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var o occurrence
+		if err = cursor.Decode(&o); err != nil {
+			return err
+		}
+		fn(o)
+	}
+	return cursor.Err()
+}
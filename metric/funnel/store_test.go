@@ -0,0 +1,53 @@
+package funnel
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+const (
+	appId = "testAppId"
+)
+
+func TestQueryFunnel(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, "test_")
+	database := client.Database("test_" + appId)
+	defer database.Drop(context.Background())
+
+	ctx := context.Background()
+	_, err := database.Collection(userCollectionName).InsertMany(ctx, []interface{}{
+		bson.M{"deviceId": "a", "channel": "appstore", "createdAt": int64(100), "registeredAt": int64(200)},
+		bson.M{"deviceId": "b", "channel": "google", "createdAt": int64(100)},
+	})
+	require.NoError(t, err)
+	events := mongodb.NewEventLog(client, "test_", time.Hour)
+	require.NoError(t, events.SaveEvent(appId, storage.Event{Name: "level_up", DeviceId: "a", Timestamp: 300}))
+	require.NoError(t, events.SaveEvent(appId, storage.Event{Name: "level_up", DeviceId: "b", Timestamp: 300}))
+
+	f := Funnel{
+		Id:         "f1",
+		Name:       "onboarding",
+		Steps:      []Step{{Type: StepNewUser}, {Type: StepRegistration}, {Type: StepCustom, Event: "level_up"}},
+		WindowDays: 7,
+	}
+	require.True(t, f.valid())
+	require.NoError(t, store.saveFunnel(appId, f))
+	saved, found, err := store.getFunnel(appId, "f1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, f, saved)
+
+	result, err := queryFunnel(store, appId, f, 0, 0, BreakdownChannel)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, result.Steps[0].Count)
+	require.Equal(t, 1.0, result.Steps[1].Count)
+	require.Equal(t, 1.0, result.Steps[2].Count)
+	require.Equal(t, "level_up", result.Steps[2].Name)
+	require.Equal(t, 1.0, result.Breakdown["appstore"][2].Count)
+}
@@ -2,31 +2,83 @@ package metric
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/authentication"
+	"github.com/lt90s/goanalytics/api/middlewares"
 	"github.com/lt90s/goanalytics/conf"
 	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/metric/anomaly"
+	"github.com/lt90s/goanalytics/metric/attribution"
+	"github.com/lt90s/goanalytics/metric/channel"
+	"github.com/lt90s/goanalytics/metric/funnel"
+	"github.com/lt90s/goanalytics/metric/release"
+	"github.com/lt90s/goanalytics/metric/retention"
+	"github.com/lt90s/goanalytics/metric/revenue"
+	"github.com/lt90s/goanalytics/metric/segment"
 	"github.com/lt90s/goanalytics/metric/usage"
 	"github.com/lt90s/goanalytics/metric/user"
 	"github.com/lt90s/goanalytics/storage/mongodb"
+	"time"
 )
 
 func SetupMetricProcessor(subscriber pubsub.Subscriber) {
 	mongoClient := mongodb.DefaultClient
 	prefix := conf.GetConfString(conf.MongoDatabasePrefixKey)
 
+	authStore := authentication.NewMongoStore(mongoClient, conf.GetConfString(conf.MongoDatabaseAdminKey))
+	appConfigCacheTTL := time.Duration(conf.GetConfInt64(conf.AppConfigCacheSecondsConfKey)) * time.Second
+	appConfigGetter := middlewares.NewCachedAppConfigGetter(authStore, appConfigCacheTTL)
+
 	userStore := user.NewMongoStore(mongoClient, prefix)
-	user.SetupProcessor(subscriber, userStore)
+	user.SetupProcessor(subscriber, userStore, appConfigGetter)
 
 	usageStore := usage.NewMongoStore(mongoClient, prefix)
 	usage.SetupProcessor(subscriber, usageStore)
+
+	revenueStore := revenue.NewMongoStore(mongoClient, prefix)
+	revenue.SetupProcessor(subscriber, revenueStore)
+
+	segmentStore := segment.NewMongoStore(mongoClient, prefix)
+	segment.SetupProcessor(subscriber, segmentStore)
+
+	anomalyStore := anomaly.NewMongoStore(mongoClient, prefix)
+	anomaly.SetupProcessor(subscriber, anomalyStore)
 }
 
-func SetupMetricApi(iRouter *gin.RouterGroup, oRouter *gin.RouterGroup, publisher pubsub.Publisher) {
+func SetupMetricApi(iRouter *gin.RouterGroup, oRouter *gin.RouterGroup, cRouter *gin.RouterGroup, publisher pubsub.Publisher) {
 	mongoClient := mongodb.DefaultClient
 	prefix := conf.GetConfString(conf.MongoDatabasePrefixKey)
 
+	authStore := authentication.NewMongoStore(mongoClient, conf.GetConfString(conf.MongoDatabaseAdminKey))
+	appConfigCacheTTL := time.Duration(conf.GetConfInt64(conf.AppConfigCacheSecondsConfKey)) * time.Second
+	appConfigGetter := middlewares.NewCachedAppConfigGetter(authStore, appConfigCacheTTL)
+
 	userStore := user.NewMongoStore(mongoClient, prefix)
 	user.SetupRoute(iRouter, oRouter, publisher, userStore)
 
 	usageStore := usage.NewMongoStore(mongoClient, prefix)
 	usage.SetupRoute(iRouter, oRouter, publisher, usageStore)
+
+	revenueStore := revenue.NewMongoStore(mongoClient, prefix)
+	revenue.SetupRoute(iRouter, oRouter, publisher, revenueStore)
+
+	attributionStore := attribution.NewMongoStore(mongoClient, prefix)
+	attribution.SetupRoute(cRouter, oRouter, attributionStore)
+
+	funnelStore := funnel.NewMongoStore(mongoClient, prefix)
+	funnel.SetupRoute(oRouter, funnelStore)
+
+	segmentStore := segment.NewMongoStore(mongoClient, prefix)
+	segment.SetupRoute(oRouter, publisher, segmentStore)
+
+	retentionStore := retention.NewMongoStore(mongoClient, prefix)
+	retention.SetupRoute(oRouter, retentionStore, segmentStore)
+
+	releaseStore := release.NewMongoStore(mongoClient, prefix)
+	release.SetupRoute(oRouter, releaseStore)
+
+	channelStore := channel.NewMongoStore(mongoClient, prefix)
+	channel.SetupRoute(oRouter, channelStore, appConfigGetter)
+
+	anomalyStore := anomaly.NewMongoStore(mongoClient, prefix)
+	anomaly.SetupRoute(oRouter, anomalyStore)
 }
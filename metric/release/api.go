@@ -0,0 +1,155 @@
+package release
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/utils"
+	"strconv"
+)
+
+const (
+	defaultAdoptionDays = 30
+	maxAdoptionDays     = 365
+	// users active in the recent days are counted as stuck on deprecated versions
+	defaultStuckActiveDays = 30
+)
+
+func SetupRoute(oRoute *gin.RouterGroup, store Store) {
+	oGroup := oRoute.Group("/release")
+	oGroup.GET("", getReleasesHandler(store))
+	oGroup.POST("", saveReleaseHandler(store))
+	oGroup.DELETE("", deleteReleaseHandler(store))
+	oGroup.GET("/adoption", adoptionHandler(store))
+	oGroup.GET("/upgrades", upgradesHandler(store))
+	oGroup.GET("/stuck", stuckUsersHandler(store))
+}
+
+func getReleasesHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		releases, err := store.getReleases(appId)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", releases)
+		}
+	}
+}
+
+// saveReleaseHandler registers a release or updates a registered one, e.g. to deprecate it
+func saveReleaseHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var requestData releaseRequestData
+		err := c.ShouldBindJSON(&requestData)
+		if err != nil || requestData.Version == "" || requestData.ReleasedAt <= 0 {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		r := Release{
+			Version:    requestData.Version,
+			ReleasedAt: utils.TimestampToDate(requestData.ReleasedAt).Unix(),
+			Deprecated: requestData.Deprecated,
+			UpdatedAt:  utils.NowTimestamp(),
+		}
+		if err = store.saveRelease(appId, r); err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", r)
+	}
+}
+
+func deleteReleaseHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var tmp struct {
+			Version string `json:"version"`
+		}
+		if err := c.ShouldBindJSON(&tmp); err != nil || tmp.Version == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if err := store.deleteRelease(appId, tmp.Version); err != nil {
+			c.Set("error", err)
+		}
+	}
+}
+
+// adoptionHandler returns the adoption curves of the registered releases, or of the release
+// given by the version query, for the days query days since the release
+func adoptionHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultAdoptionDays)))
+		if err != nil || days < 1 || days > maxAdoptionDays {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		releases, err := store.getReleases(appId)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		if version := c.Query("version"); version != "" {
+			selected := make([]Release, 0, 1)
+			for _, r := range releases {
+				if r.Version == version {
+					selected = append(selected, r)
+				}
+			}
+			releases = selected
+		}
+
+		curves, err := getAdoptionCurves(store, appId, releases, days, utils.TodayTimestamp())
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", curves)
+	}
+}
+
+// upgradesHandler returns the number of devices moving from each version to another between
+// start and end
+func upgradesHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		if err1 != nil || err2 != nil || start > end {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		counts, err := getUpgradeCounts(store, appId, start, end)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", counts)
+	}
+}
+
+// stuckUsersHandler returns the users active in the activeDays query days who are still on a
+// deprecated version
+func stuckUsersHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		activeDays, err := strconv.Atoi(c.DefaultQuery("activeDays", strconv.Itoa(defaultStuckActiveDays)))
+		if err != nil || activeDays < 1 {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		releases, err := store.getReleases(appId)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		activeSince := utils.TodayDiff(activeDays - 1).Unix()
+		stuck, err := getStuckUsers(store, appId, releases, activeSince)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", stuck)
+	}
+}
@@ -0,0 +1,59 @@
+package release
+
+import "strings"
+
+const (
+	// version transitions of devices per day, slotted by UpgradeSlot, maintained by metric/user
+	VersionUpgradeSlotCounter = "VersionUpgradeSlotCounter"
+
+	upgradeSlotSeparator = "->"
+)
+
+// UpgradeSlot returns the slot of the transition from one version to another
+func UpgradeSlot(from, to string) string {
+	return from + upgradeSlotSeparator + to
+}
+
+func parseUpgradeSlot(slot string) (from, to string, ok bool) {
+	parts := strings.SplitN(slot, upgradeSlotSeparator, 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Release is a version of the app in the release registry
+type Release struct {
+	Version string `json:"version" bson:"_id"`
+	// the date timestamp of the release
+	ReleasedAt int64 `json:"releasedAt" bson:"releasedAt"`
+	// users are expected to upgrade from deprecated versions
+	Deprecated bool  `json:"deprecated" bson:"deprecated"`
+	UpdatedAt  int64 `json:"updatedAt" bson:"updatedAt"`
+}
+
+type releaseRequestData struct {
+	Version    string `json:"version"`
+	ReleasedAt int64  `json:"releasedAt"`
+	Deprecated bool   `json:"deprecated"`
+}
+
+// AdoptionCurve is the share of the daily active users on the version by day since its
+// release, Shares[0] is the share on the release date
+type AdoptionCurve struct {
+	Version    string    `json:"version"`
+	ReleasedAt int64     `json:"releasedAt"`
+	Shares     []float64 `json:"shares"`
+}
+
+type UpgradeCount struct {
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+	Count float64 `json:"count"`
+}
+
+// StuckUsers is the number of recently active users whose latest version is deprecated
+type StuckUsers struct {
+	Version string  `json:"version"`
+	Users   float64 `json:"users"`
+}
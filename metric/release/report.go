@@ -0,0 +1,98 @@
+package release
+
+import "sort"
+
+const day = 24 * 3600
+
+// getAdoptionCurves returns the adoption curves of the releases for the days since their
+// release, up to today
+func getAdoptionCurves(store Store, appId string, releases []Release, days int, today int64) ([]AdoptionCurve, error) {
+	curves := make([]AdoptionCurve, 0, len(releases))
+	if len(releases) == 0 {
+		return curves, nil
+	}
+	start := today
+	for _, r := range releases {
+		if r.ReleasedAt < start {
+			start = r.ReleasedAt
+		}
+	}
+	dateCPV, err := store.GetSimpleCPVDateCPV(appId, dailyActiveCounterName, start, today)
+	if err != nil {
+		return nil, err
+	}
+	versions := dateCPV["version"]
+
+	for _, r := range releases {
+		curve := AdoptionCurve{Version: r.Version, ReleasedAt: r.ReleasedAt, Shares: make([]float64, 0, days)}
+		for i := 0; i < days; i++ {
+			date := r.ReleasedAt + int64(i)*day
+			if date > today {
+				break
+			}
+			var total float64
+			for _, count := range versions[date] {
+				total += count
+			}
+			var share float64
+			if total > 0 {
+				share = versions[date][r.Version] / total
+			}
+			curve.Shares = append(curve.Shares, share)
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
+
+// getUpgradeCounts returns the version transitions between start and end, sorted by count
+func getUpgradeCounts(store Store, appId string, start, end int64) ([]UpgradeCount, error) {
+	span, err := store.GetSlotCounterSpan(appId, VersionUpgradeSlotCounter, start, end)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]float64)
+	for _, slotCounter := range span {
+		for slot, count := range slotCounter {
+			sums[slot] += count
+		}
+	}
+	counts := make([]UpgradeCount, 0, len(sums))
+	for slot, count := range sums {
+		from, to, ok := parseUpgradeSlot(slot)
+		if !ok {
+			continue
+		}
+		counts = append(counts, UpgradeCount{From: from, To: to, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return UpgradeSlot(counts[i].From, counts[i].To) < UpgradeSlot(counts[j].From, counts[j].To)
+	})
+	return counts, nil
+}
+
+// getStuckUsers returns the users active in the recent days whose latest version is
+// deprecated, by deprecated version
+func getStuckUsers(store Store, appId string, releases []Release, activeSince int64) ([]StuckUsers, error) {
+	deprecated := make([]string, 0)
+	for _, r := range releases {
+		if r.Deprecated {
+			deprecated = append(deprecated, r.Version)
+		}
+	}
+	stuck := make([]StuckUsers, 0, len(deprecated))
+	if len(deprecated) == 0 {
+		return stuck, nil
+	}
+	counts, err := store.countUsersOnVersions(appId, deprecated, activeSince)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range deprecated {
+		stuck = append(stuck, StuckUsers{Version: version, Users: counts[version]})
+	}
+	return stuck, nil
+}
@@ -0,0 +1,115 @@
+package release
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Store interface {
+	storage.Counter
+	saveRelease(appId string, r Release) error
+	getReleases(appId string) ([]Release, error)
+	deleteRelease(appId, version string) error
+	// countUsersOnVersions counts the users whose latest version is one of the versions and
+	// who were active since the timestamp, by version
+	countUsersOnVersions(appId string, versions []string, activeSince int64) (map[string]float64, error)
+}
+
+const (
+	releaseCollectionName = "releaseCollection"
+	// upgrade events of devices, maintained by metric/user
+	UpgradeCollectionName = "versionUpgradeCollection"
+
+	// maintained by metric/user
+	userCollectionName     = "userCollection"
+	dailyActiveCounterName = "DailyActiveCPVCounter"
+)
+
+type mongodbStore struct {
+	storage.Counter
+	client         *mongo.Client
+	databasePrefix string
+}
+
+func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
+	return &mongodbStore{
+		Counter:        mongodb.NewCounter(client, databasePrefix),
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (ms *mongodbStore) database(appId string) *mongo.Database {
+	return ms.client.Database(ms.databasePrefix + appId)
+}
+
+// saveRelease adds the release to the registry or replaces the release of the same version
+func (ms *mongodbStore) saveRelease(appId string, r Release) error {
+	option := options.Replace().SetUpsert(true)
+	_, err := ms.database(appId).Collection(releaseCollectionName).ReplaceOne(context.Background(),
+		bson.M{"_id": r.Version}, r, option)
+	return err
+}
+
+func (ms *mongodbStore) getReleases(appId string) ([]Release, error) {
+	ctx := context.Background()
+	option := options.Find().SetSort(bson.M{"releasedAt": -1})
+	cursor, err := ms.database(appId).Collection(releaseCollectionName).Find(ctx, bson.M{}, option)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	releases := make([]Release, 0)
+	for cursor.Next(ctx) {
+		var r Release
+		if err = cursor.Decode(&r); err != nil {
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+	return releases, cursor.Err()
+}
+
+func (ms *mongodbStore) deleteRelease(appId, version string) error {
+	_, err := ms.database(appId).Collection(releaseCollectionName).DeleteOne(context.Background(), bson.M{"_id": version})
+	return err
+}
+
+func (ms *mongodbStore) countUsersOnVersions(appId string, versions []string, activeSince int64) (map[string]float64, error) {
+	ctx := context.Background()
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"version":   bson.M{"$in": versions},
+				"updatedAt": bson.M{"$gte": activeSince},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":   "$version",
+				"count": bson.M{"$sum": 1},
+			},
+		},
+	}
+	cursor, err := ms.database(appId).Collection(userCollectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	counts := make(map[string]float64)
+	var tmp struct {
+		Version string  `bson:"_id"`
+		Count   float64 `bson:"count"`
+	}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&tmp); err != nil {
+			return nil, err
+		}
+		counts[tmp.Version] = tmp.Count
+	}
+	return counts, cursor.Err()
+}
@@ -0,0 +1,59 @@
+package release
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+const (
+	appId = "testAppId"
+)
+
+func TestReleaseReports(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, "test_")
+	defer client.Database("test_" + appId).Drop(context.Background())
+	defer store.DropAllCounter(appId)
+
+	today := utils.TodayTimestamp()
+	yesterday := utils.TodayDiff(1).Unix()
+	require.NoError(t, store.saveRelease(appId, Release{Version: "1.0.0", ReleasedAt: utils.TodayDiff(10).Unix()}))
+	require.NoError(t, store.saveRelease(appId, Release{Version: "1.1.0", ReleasedAt: yesterday}))
+	// deprecate 1.0.0
+	require.NoError(t, store.saveRelease(appId, Release{Version: "1.0.0", ReleasedAt: utils.TodayDiff(10).Unix(), Deprecated: true}))
+	releases, err := store.getReleases(appId)
+	require.NoError(t, err)
+	require.Len(t, releases, 2)
+	require.Equal(t, "1.1.0", releases[0].Version)
+
+	// daily active users by version
+	store.AddSimpleCPVCounter(appId, "c", "android", "1.0.0", dailyActiveCounterName, yesterday, 4)
+	store.AddSimpleCPVCounter(appId, "c", "android", "1.1.0", dailyActiveCounterName, yesterday, 1)
+	store.AddSimpleCPVCounter(appId, "c", "android", "1.0.0", dailyActiveCounterName, today, 2)
+	store.AddSimpleCPVCounter(appId, "c", "ios", "1.1.0", dailyActiveCounterName, today, 2)
+	curves, err := getAdoptionCurves(store, appId, releases[:1], 7, today)
+	require.NoError(t, err)
+	require.Equal(t, []AdoptionCurve{{Version: "1.1.0", ReleasedAt: yesterday, Shares: []float64{0.2, 0.5}}}, curves)
+
+	store.AddSlotCounter(appId, VersionUpgradeSlotCounter, UpgradeSlot("1.0.0", "1.1.0"), yesterday, 1)
+	store.AddSlotCounter(appId, VersionUpgradeSlotCounter, UpgradeSlot("1.0.0", "1.1.0"), today, 2)
+	store.AddSlotCounter(appId, VersionUpgradeSlotCounter, UpgradeSlot("0.9.0", "1.1.0"), today, 1)
+	upgrades, err := getUpgradeCounts(store, appId, yesterday, today)
+	require.NoError(t, err)
+	require.Equal(t, []UpgradeCount{{From: "1.0.0", To: "1.1.0", Count: 3}, {From: "0.9.0", To: "1.1.0", Count: 1}}, upgrades)
+
+	users := client.Database("test_" + appId).Collection(userCollectionName)
+	for deviceId, version := range map[string]string{"a": "1.0.0", "b": "1.0.0", "c": "1.1.0"} {
+		_, err = users.InsertOne(context.Background(), bson.M{"deviceId": deviceId, "version": version, "updatedAt": today + 60})
+		require.NoError(t, err)
+	}
+	_, err = users.InsertOne(context.Background(), bson.M{"deviceId": "d", "version": "1.0.0", "updatedAt": utils.TodayDiff(60).Unix()})
+	require.NoError(t, err)
+	stuck, err := getStuckUsers(store, appId, releases, utils.TodayDiff(29).Unix())
+	require.NoError(t, err)
+	require.Equal(t, []StuckUsers{{Version: "1.0.0", Users: 2}}, stuck)
+}
@@ -0,0 +1,76 @@
+package retention
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/utils"
+	"net/http"
+	"strconv"
+)
+
+// SegmentMembers returns the members of a segment, nil when the segment does not exist
+type SegmentMembers interface {
+	SegmentMembers(appId, segmentId string) (map[string]bool, error)
+}
+
+func SetupRoute(oRoute *gin.RouterGroup, store Store, segments SegmentMembers) {
+	oGroup := oRoute.Group("/retention")
+	oGroup.GET("/matrix", matrixHandler(store, segments))
+}
+
+// matrixHandler returns the cohort retention matrix. Cohorts are daily, weekly or monthly by
+// the granularity query, and devices return by opening the app, purchasing or sending the
+// custom event given by the returnType and event queries. Cohorts can be filtered by the
+// channel, platform and version at install queries, and to the members of the segment query.
+func matrixHandler(store Store, segments SegmentMembers) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		periods, err3 := strconv.Atoi(c.Query("periods"))
+		if err1 != nil || err2 != nil || err3 != nil || start > end {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		granularity := c.DefaultQuery("granularity", GranularityDay)
+		maxPeriod, ok := maxPeriods[granularity]
+		if !ok || periods < 1 || periods > maxPeriod {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		r := returnEvent{Type: c.DefaultQuery("returnType", ReturnOpenApp), Event: c.Query("event")}
+		if !r.valid() {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		filter := make(map[string]string)
+		for _, key := range []string{"channel", "platform", "version"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		var members map[string]bool
+		if segmentId := c.Query("segment"); segmentId != "" {
+			var err error
+			members, err = segments.SegmentMembers(appId, segmentId)
+			if err != nil {
+				c.Set("error", err)
+				return
+			}
+			if members == nil {
+				c.Set("error", utils.NewHttpError(http.StatusNotFound, http.StatusNotFound, "Segment not found"))
+				return
+			}
+		}
+
+		matrix, err := getMatrix(store, appId, granularity, start, end, periods, r, filter, members, utils.NowTimestamp())
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", matrix)
+	}
+}
@@ -0,0 +1,96 @@
+package retention
+
+import (
+	"github.com/lt90s/goanalytics/utils"
+	"math"
+	"time"
+)
+
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+const (
+	ReturnOpenApp  = "open_app"
+	ReturnPurchase = "purchase"
+	// a custom event reported with the customized counters
+	ReturnCustom = "custom"
+)
+
+// return periods are limited to a year
+var maxPeriods = map[string]int{
+	GranularityDay:   365,
+	GranularityWeek:  52,
+	GranularityMonth: 12,
+}
+
+type returnEvent struct {
+	Type  string
+	Event string
+}
+
+func (r returnEvent) valid() bool {
+	switch r.Type {
+	case ReturnOpenApp, ReturnPurchase:
+		return r.Event == ""
+	case ReturnCustom:
+		return r.Event != ""
+	}
+	return false
+}
+
+// CohortRow is the retention of the devices installed in the period starting at Start.
+// Retained[i] is the number of devices returning in the (i+1)th period after the install, the
+// periods end at the period in progress, which is partial.
+type CohortRow struct {
+	Start    int64     `json:"start"`
+	Size     float64   `json:"size"`
+	Retained []float64 `json:"retained"`
+	Rates    []float64 `json:"rates"`
+}
+
+type Matrix struct {
+	Granularity string      `json:"granularity"`
+	Periods     int         `json:"periods"`
+	Cohorts     []CohortRow `json:"cohorts"`
+}
+
+// periodStart returns the start of the day, the week starting on monday or the month of timestamp
+func periodStart(granularity string, timestamp int64) time.Time {
+	date := utils.TimestampToDate(timestamp)
+	switch granularity {
+	case GranularityWeek:
+		return date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+	case GranularityMonth:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	}
+	return date
+}
+
+// periodAfter returns the start of the nth period after start
+func periodAfter(granularity string, start time.Time, n int) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7*n)
+	case GranularityMonth:
+		return start.AddDate(0, n, 0)
+	}
+	return start.AddDate(0, 0, n)
+}
+
+// periodsBetween returns the number of periods from the period starting at start to the period
+// of timestamp
+func periodsBetween(granularity string, start time.Time, timestamp int64) int {
+	end := periodStart(granularity, timestamp)
+	if granularity == GranularityMonth {
+		return (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month())
+	}
+	// days are not always 24 hours long with daylight saving time
+	days := int(math.Round(end.Sub(start).Hours() / 24))
+	if granularity == GranularityWeek {
+		return days / 7
+	}
+	return days
+}
@@ -0,0 +1,77 @@
+package retention
+
+import (
+	"sort"
+	"time"
+)
+
+// getMatrix computes the retention of the cohorts of devices installed in the periods between
+// the periods of start and end, for the given number of periods after the install. Installs are
+// restricted to the members when members is not nil. The periods of a cohort end at the period
+// in progress at now.
+func getMatrix(store Store, appId, granularity string, start, end int64, periods int, r returnEvent,
+	filter map[string]string, members map[string]bool, now int64) (Matrix, error) {
+	first := periodStart(granularity, start)
+	last := periodStart(granularity, end)
+	installs, err := store.getInstalls(appId, first.Unix(), periodAfter(granularity, last, 1).Unix(), filter)
+	if err != nil {
+		return Matrix{}, err
+	}
+
+	rows := make(map[int64]*CohortRow)
+	cohortOf := make(map[string]time.Time, len(installs))
+	for deviceId, createdAt := range installs {
+		if members != nil && !members[deviceId] {
+			continue
+		}
+		cohort := periodStart(granularity, createdAt)
+		cohortOf[deviceId] = cohort
+		row, ok := rows[cohort.Unix()]
+		if !ok {
+			row = &CohortRow{Start: cohort.Unix(), Retained: make([]float64, periods), Rates: make([]float64, periods)}
+			rows[cohort.Unix()] = row
+		}
+		row.Size++
+	}
+
+	// a device is counted once per period
+	returned := make(map[string]map[int]bool)
+	scanEnd := periodAfter(granularity, last, periods+1).Unix()
+	err = store.scanReturns(appId, r, periodAfter(granularity, first, 1).Unix(), scanEnd, func(deviceId string, timestamp int64) {
+		cohort, ok := cohortOf[deviceId]
+		if !ok {
+			return
+		}
+		n := periodsBetween(granularity, cohort, timestamp)
+		if n < 1 || n > periods || returned[deviceId][n] {
+			return
+		}
+		if returned[deviceId] == nil {
+			returned[deviceId] = make(map[int]bool)
+		}
+		returned[deviceId][n] = true
+		rows[cohort.Unix()].Retained[n-1]++
+	})
+	if err != nil {
+		return Matrix{}, err
+	}
+
+	matrix := Matrix{Granularity: granularity, Periods: periods, Cohorts: make([]CohortRow, 0, len(rows))}
+	for _, row := range rows {
+		// periods that have not started yet are left out rather than reported as 0
+		cohort := time.Unix(row.Start, 0)
+		started := 0
+		for started < periods && periodAfter(granularity, cohort, started+1).Unix() <= now {
+			started++
+		}
+		row.Retained, row.Rates = row.Retained[:started], row.Rates[:started]
+		for i := range row.Retained {
+			row.Rates[i] = row.Retained[i] / row.Size
+		}
+		matrix.Cohorts = append(matrix.Cohorts, *row)
+	}
+	sort.Slice(matrix.Cohorts, func(i, j int) bool {
+		return matrix.Cohorts[i].Start < matrix.Cohorts[j].Start
+	})
+	return matrix, nil
+}
@@ -0,0 +1,89 @@
+package retention
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type mockStore struct {
+	installs map[string]int64
+	returns  map[string][]int64
+}
+
+func (m mockStore) getInstalls(appId string, start, end int64, filter map[string]string) (map[string]int64, error) {
+	installs := make(map[string]int64)
+	for deviceId, createdAt := range m.installs {
+		if createdAt >= start && createdAt < end {
+			installs[deviceId] = createdAt
+		}
+	}
+	return installs, nil
+}
+
+func (m mockStore) scanReturns(appId string, r returnEvent, start, end int64, fn func(deviceId string, timestamp int64)) error {
+	for deviceId, timestamps := range m.returns {
+		for _, timestamp := range timestamps {
+			if timestamp >= start && timestamp < end {
+				fn(deviceId, timestamp)
+			}
+		}
+	}
+	return nil
+}
+
+func TestPeriods(t *testing.T) {
+	// a wednesday
+	date := time.Date(2019, 5, 15, 0, 0, 0, 0, time.Local)
+	require.Equal(t, time.Date(2019, 5, 13, 0, 0, 0, 0, time.Local), periodStart(GranularityWeek, date.Unix()+3600))
+	require.Equal(t, time.Date(2019, 5, 1, 0, 0, 0, 0, time.Local), periodStart(GranularityMonth, date.Unix()))
+
+	require.Equal(t, 2, periodsBetween(GranularityDay, date, date.AddDate(0, 0, 2).Unix()+60))
+	require.Equal(t, 1, periodsBetween(GranularityWeek, periodStart(GranularityWeek, date.Unix()), date.AddDate(0, 0, 5).Unix()))
+	require.Equal(t, 12, periodsBetween(GranularityMonth, periodStart(GranularityMonth, date.Unix()), date.AddDate(1, 0, 0).Unix()))
+}
+
+func TestGetMatrix(t *testing.T) {
+	day1 := time.Date(2019, 5, 13, 0, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	now := time.Now().Unix()
+	store := mockStore{
+		installs: map[string]int64{
+			"a": day1.Unix() + 100,
+			"b": day1.Unix() + 200,
+			"c": day2.Unix() + 100,
+		},
+		returns: map[string][]int64{
+			// returning twice a day is counted once
+			"a": {day1.AddDate(0, 0, 1).Unix(), day1.AddDate(0, 0, 1).Unix() + 10, day1.AddDate(0, 0, 2).Unix()},
+			"b": {day1.Unix() + 300, day1.AddDate(0, 0, 2).Unix()},
+			"c": {day2.AddDate(0, 0, 1).Unix()},
+		},
+	}
+
+	matrix, err := getMatrix(store, "app", GranularityDay, day1.Unix(), day2.Unix(), 2, returnEvent{Type: ReturnOpenApp}, nil, nil, now)
+	require.NoError(t, err)
+	require.Len(t, matrix.Cohorts, 2)
+	require.Equal(t, CohortRow{Start: day1.Unix(), Size: 2, Retained: []float64{1, 2}, Rates: []float64{0.5, 1}}, matrix.Cohorts[0])
+	require.Equal(t, CohortRow{Start: day2.Unix(), Size: 1, Retained: []float64{1, 0}, Rates: []float64{1, 0}}, matrix.Cohorts[1])
+
+	matrix, err = getMatrix(store, "app", GranularityWeek, day1.Unix(), day2.Unix(), 1, returnEvent{Type: ReturnOpenApp}, nil, nil, now)
+	require.NoError(t, err)
+	require.Len(t, matrix.Cohorts, 1)
+	require.Equal(t, 3.0, matrix.Cohorts[0].Size)
+	require.Equal(t, 0.0, matrix.Cohorts[0].Retained[0])
+
+	// restricted to the segment members
+	members := map[string]bool{"b": true, "c": true}
+	matrix, err = getMatrix(store, "app", GranularityDay, day1.Unix(), day2.Unix(), 2, returnEvent{Type: ReturnOpenApp}, nil, members, now)
+	require.NoError(t, err)
+	require.Len(t, matrix.Cohorts, 2)
+	require.Equal(t, CohortRow{Start: day1.Unix(), Size: 1, Retained: []float64{0, 1}, Rates: []float64{0, 1}}, matrix.Cohorts[0])
+
+	// the second period of the day1 cohort is in progress and the day2 cohort has no period yet
+	matrix, err = getMatrix(store, "app", GranularityDay, day1.Unix(), day2.Unix(), 2, returnEvent{Type: ReturnOpenApp},
+		nil, nil, day2.Unix()+3600)
+	require.NoError(t, err)
+	require.Equal(t, CohortRow{Start: day1.Unix(), Size: 2, Retained: []float64{1}, Rates: []float64{0.5}}, matrix.Cohorts[0])
+	require.Equal(t, CohortRow{Start: day2.Unix(), Size: 1, Retained: []float64{}, Rates: []float64{}}, matrix.Cohorts[1])
+}
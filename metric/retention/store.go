@@ -0,0 +1,107 @@
+package retention
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Store interface {
+	// getInstalls returns the install timestamps of the devices installed between start and end
+	// and matching the filter on channel, platform and the version at install
+	getInstalls(appId string, start, end int64, filter map[string]string) (map[string]int64, error)
+	// scanReturns calls fn for every return event between start and end
+	scanReturns(appId string, r returnEvent, start, end int64, fn func(deviceId string, timestamp int64)) error
+}
+
+const (
+	// maintained by metric/user
+	userCollectionName         = "userCollection"
+	deviceActiveCollectionName = "deviceActiveCollection"
+	// maintained by metric/revenue
+	purchaseCollectionName = "purchaseCollection"
+)
+
+type mongodbStore struct {
+	client         *mongo.Client
+	databasePrefix string
+}
+
+func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
+	return &mongodbStore{
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (ms *mongodbStore) database(appId string) *mongo.Database {
+	return ms.client.Database(ms.databasePrefix + appId)
+}
+
+func (ms *mongodbStore) getInstalls(appId string, start, end int64, filter map[string]string) (map[string]int64, error) {
+	match := bson.M{"createdAt": bson.M{"$gte": start, "$lt": end}}
+	for key, value := range filter {
+		match[key] = value
+	}
+	// cohorts are filtered by the version at install so upgrades do not change their members,
+	// records created before installVersion was kept fall back to the current version
+	if version, ok := filter["version"]; ok {
+		delete(match, "version")
+		match["$or"] = bson.A{
+			bson.M{"installVersion": version},
+			bson.M{"installVersion": bson.M{"$exists": false}, "version": version},
+		}
+	}
+	ctx := context.Background()
+	option := options.Find().SetProjection(bson.M{"deviceId": 1, "createdAt": 1})
+	cursor, err := ms.database(appId).Collection(userCollectionName).Find(ctx, match, option)
+	if err != nil {
+		return nil, err
+	}
+	installs := make(map[string]int64)
+	var tmp struct {
+		DeviceId  string `bson:"deviceId"`
+		CreatedAt int64  `bson:"createdAt"`
+	}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&tmp); err != nil {
+			return nil, err
+		}
+		installs[tmp.DeviceId] = tmp.CreatedAt
+	}
+	return installs, cursor.Err()
+}
+
+func (ms *mongodbStore) scanReturns(appId string, r returnEvent, start, end int64,
+	fn func(deviceId string, timestamp int64)) error {
+	// a device is active once a day in deviceActiveCollection, timestamped by the date
+	collectionName := deviceActiveCollectionName
+	match := bson.M{"timestamp": bson.M{"$gte": start, "$lt": end}}
+	switch r.Type {
+	case ReturnPurchase:
+		collectionName = purchaseCollectionName
+	case ReturnCustom:
+		collectionName = mongodb.EventCollectionName
+		match["name"] = r.Event
+	}
+
+	ctx := context.Background()
+	option := options.Find().SetProjection(bson.M{"deviceId": 1, "timestamp": 1})
+	cursor, err := ms.database(appId).Collection(collectionName).Find(ctx, match, option)
+	if err != nil {
+		return err
+	}
+	var tmp struct {
+		DeviceId  string `bson:"deviceId"`
+		Timestamp int64  `bson:"timestamp"`
+	}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&tmp); err != nil {
+			return err
+		}
+		fn(tmp.DeviceId, tmp.Timestamp)
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,80 @@
+package revenue
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func SetupRoute(iRoute *gin.RouterGroup, oRoute *gin.RouterGroup, publisher pubsub.Publisher, store Store) {
+	iGroup := iRoute.Group("/revenue")
+	iGroup.POST("/purchase", purchaseHandler(publisher))
+
+	oGroup := oRoute.Group("/revenue")
+	oGroup.GET("/ltv", ltvHandler(store))
+}
+
+func purchaseHandler(publisher pubsub.Publisher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var requestData purchaseRequestData
+		err := c.ShouldBindJSON(&requestData)
+		if err != nil {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		requestData.Currency = strings.ToUpper(requestData.Currency)
+		if requestData.Amount <= 0 || len(requestData.Currency) != 3 ||
+			requestData.ProductId == "" || requestData.TransactionId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		metadata, ok := middlewares.GetMetaData(c)
+		if !ok {
+			log.Error("[purchaseHandler] MetaData missing")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		data := &purchaseData{
+			MetaData:      metadata,
+			Amount:        requestData.Amount,
+			Currency:      requestData.Currency,
+			ProductId:     requestData.ProductId,
+			TransactionId: requestData.TransactionId,
+		}
+
+		publisher.Publish(EventPurchase, data)
+	}
+}
+
+func ltvHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		days, err3 := strconv.Atoi(c.DefaultQuery("days", "30"))
+		currency := strings.ToUpper(c.Query("currency"))
+		if err1 != nil || err2 != nil || err3 != nil || start > end || currency == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if days < 0 || days > ltvMaxDays {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		cohorts, err := getLTVCohorts(store, appId, currency, start, end, days)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", cohorts)
+	}
+}
@@ -0,0 +1,49 @@
+package revenue
+
+import "github.com/lt90s/goanalytics/api/middlewares"
+
+const (
+	EventPurchase = "EventPurchase"
+)
+
+const (
+	PurchaseCPVCounter          = "PurchaseCPVCounter"
+	PayingUserCPVCounter        = "PayingUserCPVCounter"
+	NewPayingUserCPVCounter     = "NewPayingUserCPVCounter"
+	RevenueCPVCounterPrefix     = "RevenueCPVCounter_"
+	RevenueSlotCounter          = "RevenueSlotCounter"
+	ARPUSlotCounter             = "ARPUSlotCounter"
+	ARPPUSlotCounter            = "ARPPUSlotCounter"
+	LTVRevenueSlotCounterPrefix = "LTVRevenueSlotCounter_"
+)
+
+const (
+	DailyScheduleEvent    = "RevenueDailyScheduleEvent"
+	LateDataScheduleEvent = "RevenueLateDataScheduleEvent"
+)
+
+const (
+	// revenue made more than ltvMaxDays after install is not tracked in the ltv curve
+	ltvMaxDays = 365
+)
+
+type purchaseRequestData struct {
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	ProductId     string  `json:"productId"`
+	TransactionId string  `json:"transactionId"`
+}
+
+type purchaseData struct {
+	MetaData      *middlewares.MetaData `json:"metadata"`
+	Amount        float64               `json:"amount"`
+	Currency      string                `json:"currency"`
+	ProductId     string                `json:"productId"`
+	TransactionId string                `json:"transactionId"`
+}
+
+type LTVCohort struct {
+	Date     int64     `json:"date"`
+	NewUsers float64   `json:"newUsers"`
+	LTV      []float64 `json:"ltv"`
+}
@@ -0,0 +1,38 @@
+package revenue
+
+import (
+	"github.com/lt90s/goanalytics/metric/user"
+	"strconv"
+)
+
+// getLTVCohorts returns the cumulative revenue per new user of each install date cohort
+// in [start, end], for the first `days` days after install
+func getLTVCohorts(store Store, appId, currency string, start, end int64, days int) ([]LTVCohort, error) {
+	revenueSpan, err := store.GetSlotCounterSpan(appId, LTVRevenueSlotCounterPrefix+currency, start, end)
+	if err != nil {
+		return nil, err
+	}
+	newUserSpan, err := store.GetSimpleCPVSumDate(appId, user.NewUserCPVCounter, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	cohorts := make([]LTVCohort, 0)
+	for date := start; date <= end; date += 24 * 3600 {
+		cohort := LTVCohort{
+			Date:     date,
+			NewUsers: newUserSpan[date],
+			LTV:      make([]float64, days+1),
+		}
+		revenue := revenueSpan[date]
+		var total float64
+		for day := 0; day <= days; day++ {
+			total += revenue[strconv.Itoa(day)]
+			if cohort.NewUsers > 0 {
+				cohort.LTV[day] = total / cohort.NewUsers
+			}
+		}
+		cohorts = append(cohorts, cohort)
+	}
+	return cohorts, nil
+}
@@ -0,0 +1,88 @@
+package revenue
+
+import (
+	"errors"
+	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+)
+
+func SetupProcessor(subscriber pubsub.Subscriber, store Store) {
+	err := subscriber.Subscribe(EventPurchase, purchaseEventHandler(store), purchaseData{})
+	if err != nil {
+		log.WithFields(log.Fields{"event": EventPurchase, "error": err.Error()}).Error("Subscribe error")
+		return
+	}
+
+	err = subscriber.Subscribe(DailyScheduleEvent, dailyScheduleEventHandler(store), DailyScheduleEventData{})
+	if err != nil {
+		log.WithFields(log.Fields{"event": DailyScheduleEvent, "error": err.Error()}).Error("Subscribe error")
+		return
+	}
+
+	err = subscriber.Subscribe(LateDataScheduleEvent, lateDataScheduleEventHandler(store), LateDataScheduleEventData{})
+	if err != nil {
+		log.WithFields(log.Fields{"event": LateDataScheduleEvent, "error": err.Error()}).Error("Subscribe error")
+	}
+}
+
+func purchaseEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		entry := log.WithFields(log.Fields{"data": data, "handler": "purchaseEventHandler"})
+		purchase, ok := data.(*purchaseData)
+		if !ok {
+			entry.Warn("data type is not *purchaseData")
+			return errors.New("data type is not *purchaseData")
+		}
+		metadata := purchase.MetaData
+
+		entry.Debug("Handle purchase event")
+
+		// the sdk may report the same transaction more than once
+		isNew, err := store.savePurchase(purchase)
+		if err != nil {
+			entry.Warn("savePurchase error: ", err.Error())
+			return err
+		}
+		if !isNew {
+			entry.Debug("Duplicated transaction")
+			return nil
+		}
+
+		store.AddSlotCounter(metadata.AppId, RevenueSlotCounter, purchase.Currency, metadata.DateTimestamp, purchase.Amount)
+		store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform, metadata.Version,
+			RevenueCPVCounterPrefix+purchase.Currency, metadata.DateTimestamp, purchase.Amount)
+
+		// derived daily data of a past day has to be computed again
+		if metadata.DateTimestamp < utils.TodayTimestamp() {
+			store.MarkDailyLate(metadata.AppId, DailyScheduleEvent, metadata.DateTimestamp)
+		}
+		store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform, metadata.Version,
+			PurchaseCPVCounter, metadata.DateTimestamp, 1.0)
+
+		if store.deviceFirstPurchaseToday(metadata) {
+			store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform, metadata.Version,
+				PayingUserCPVCounter, metadata.DateTimestamp, 1.0)
+		}
+
+		if store.deviceFirstPurchase(metadata) {
+			entry.Debug("New paying user")
+			store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform, metadata.Version,
+				NewPayingUserCPVCounter, metadata.DateTimestamp, 1.0)
+		}
+
+		// ltv: revenue is credited to the install date cohort
+		installDate, err := store.getUserCreatedDate(metadata.AppId, metadata.DeviceId)
+		if err != nil {
+			entry.Warn("getUserCreatedDate error: ", err.Error())
+			return nil
+		}
+		delta := int((metadata.DateTimestamp - installDate) / (24 * 3600))
+		if delta >= 0 && delta <= ltvMaxDays {
+			store.AddSlotCounter(metadata.AppId, LTVRevenueSlotCounterPrefix+purchase.Currency, strconv.Itoa(delta),
+				installDate, purchase.Amount)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,99 @@
+package revenue
+
+import (
+	"errors"
+	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/metric/user"
+	"github.com/sirupsen/logrus"
+)
+
+type DailyScheduleEventData struct {
+	Timestamp int64  `json:"timestamp"`
+	AppId     string `json:"appIds"`
+}
+
+func dailyScheduleEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		eventData, ok := data.(*DailyScheduleEventData)
+		entry := logrus.WithFields(logrus.Fields{"data": data})
+		if !ok {
+			return errors.New("RevenueDailyScheduleEventHandler: data is not of type *DailyScheduleEventData")
+		}
+
+		return calculateDaily(eventData, store, entry)
+	})
+}
+
+type LateDataScheduleEventData struct {
+	AppId string `json:"appId"`
+}
+
+// lateDataScheduleEventHandler recomputes the days that received data after their daily computations ran
+func lateDataScheduleEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		eventData, ok := data.(*LateDataScheduleEventData)
+		if !ok {
+			return errors.New("RevenueLateDataScheduleEventHandler: data is not of type *LateDataScheduleEventData")
+		}
+		dates, err := store.PopDailyLate(eventData.AppId, DailyScheduleEvent)
+		if err != nil {
+			return err
+		}
+		for _, date := range dates {
+			entry := logrus.WithFields(logrus.Fields{"appId": eventData.AppId, "timestamp": date})
+			entry.Info("Recompute revenue daily data")
+			err = calculateDaily(&DailyScheduleEventData{Timestamp: date, AppId: eventData.AppId}, store, entry)
+		}
+		return err
+	})
+}
+
+func calculateDaily(data *DailyScheduleEventData, store Store, entry *logrus.Entry) error {
+	err := calculateARPU(data, store)
+	if err != nil {
+		entry.Warn("calculateARPU error: ", err.Error())
+	}
+
+	if err := store.MarkDailyProcessed(data.AppId, DailyScheduleEvent, data.Timestamp); err != nil {
+		entry.Warn("MarkDailyProcessed error: ", err.Error())
+	}
+	return err
+}
+
+// calculateARPU computes ARPU (revenue / daily active users) and ARPPU (revenue / paying users)
+// for every currency that had revenue on the day
+func calculateARPU(data *DailyScheduleEventData, store Store) error {
+	revenues, err := store.GetSlotCounterSpan(data.AppId, RevenueSlotCounter, data.Timestamp, data.Timestamp)
+	if err != nil {
+		return err
+	}
+	revenue, ok := revenues[data.Timestamp]
+	if !ok {
+		return nil
+	}
+
+	activeCount, err := store.GetSimpleCPVSumTotal(data.AppId, user.DailyActiveCPVCounter, data.Timestamp, data.Timestamp)
+	if err != nil {
+		return err
+	}
+	payingCount, err := store.GetSimpleCPVSumTotal(data.AppId, PayingUserCPVCounter, data.Timestamp, data.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	for currency, amount := range revenue {
+		if activeCount > 0 {
+			err = store.SetSlotCounter(data.AppId, ARPUSlotCounter, currency, data.Timestamp, amount/activeCount)
+			if err != nil {
+				return err
+			}
+		}
+		if payingCount > 0 {
+			err = store.SetSlotCounter(data.AppId, ARPPUSlotCounter, currency, data.Timestamp, amount/payingCount)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
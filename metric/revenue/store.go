@@ -0,0 +1,142 @@
+package revenue
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Store interface {
+	storage.Counter
+	storage.DailyTracker
+	savePurchase(data *purchaseData) (bool, error)
+	deviceFirstPurchaseToday(data *middlewares.MetaData) bool
+	deviceFirstPurchase(data *middlewares.MetaData) bool
+	getUserCreatedDate(appId, deviceId string) (int64, error)
+}
+
+const (
+	purchaseCollectionName     = "purchaseCollection"
+	devicePayingCollectionName = "devicePayingCollection"
+	payingUserCollectionName   = "payingUserCollection"
+	// maintained by metric/user
+	userCollectionName = "userCollection"
+)
+
+type mongodbStore struct {
+	storage.Counter
+	storage.DailyTracker
+	client         *mongo.Client
+	databasePrefix string
+}
+
+func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
+	return &mongodbStore{
+		Counter:        mongodb.NewCounter(client, databasePrefix),
+		DailyTracker:   mongodb.NewDailyTracker(client, databasePrefix),
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (ms *mongodbStore) database(appId string) *mongo.Database {
+	return ms.client.Database(ms.databasePrefix + appId)
+}
+
+// savePurchase saves the purchase and reports whether the transaction is seen for the first time
+func (ms *mongodbStore) savePurchase(data *purchaseData) (bool, error) {
+	metadata := data.MetaData
+	filter := bson.M{
+		"transactionId": data.TransactionId,
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"timestamp": metadata.Timestamp,
+			"deviceId":  metadata.DeviceId,
+			"channel":   metadata.Channel,
+			"platform":  metadata.Platform,
+			"version":   metadata.Version,
+			"userId":    metadata.UserId,
+			"amount":    data.Amount,
+			"currency":  data.Currency,
+			"productId": data.ProductId,
+		},
+	}
+	upsert := true
+	option := &options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	ctx := context.Background()
+	result, err := ms.database(metadata.AppId).Collection(purchaseCollectionName).UpdateOne(ctx, filter, update, option)
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+func (ms *mongodbStore) deviceFirstPurchaseToday(data *middlewares.MetaData) bool {
+	filter := bson.M{
+		"deviceId":  data.DeviceId,
+		"timestamp": data.DateTimestamp,
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"f": 1,
+		},
+	}
+	upsert := true
+	option := &options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	result, err := ms.database(data.AppId).Collection(devicePayingCollectionName).UpdateOne(context.Background(), filter, update, option)
+	if err != nil {
+		return false
+	}
+	return result.UpsertedCount > 0
+}
+
+func (ms *mongodbStore) deviceFirstPurchase(data *middlewares.MetaData) bool {
+	filter := bson.M{
+		"deviceId": data.DeviceId,
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"channel":   data.Channel,
+			"createdAt": data.Timestamp,
+		},
+	}
+	upsert := true
+	option := &options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	result, err := ms.database(data.AppId).Collection(payingUserCollectionName).UpdateOne(context.Background(), filter, update, option)
+	if err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Warn("deviceFirstPurchase error")
+		return false
+	}
+	return result.UpsertedCount > 0
+}
+
+func (ms *mongodbStore) getUserCreatedDate(appId, deviceId string) (int64, error) {
+	ctx := context.Background()
+	filter := bson.M{"deviceId": deviceId}
+	option := &options.FindOneOptions{
+		Projection: bson.M{"createdAt": 1},
+	}
+	result := ms.database(appId).Collection(userCollectionName).FindOne(ctx, filter, option)
+	var ob struct {
+		CreatedAt int64 `bson:"createdAt"`
+	}
+
+	if err := result.Decode(&ob); err != nil {
+		return 0, err
+	}
+
+	return utils.TimestampToDate(ob.CreatedAt).Unix(), nil
+}
@@ -0,0 +1,92 @@
+package revenue
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+const prefix = "metric_revenue"
+const appId = "test_metric_revenue"
+
+var client *mongo.Client
+var ms *mongodbStore
+
+func init() {
+	client = mongodb.DefaultClient
+	ms = NewMongoStore(client, prefix).(*mongodbStore)
+}
+
+func drop() {
+	client.Database(prefix + appId).Drop(context.Background())
+}
+
+func newPurchaseData(transactionId string) *purchaseData {
+	return &purchaseData{
+		MetaData: &middlewares.MetaData{
+			AppId:         appId,
+			DeviceId:      "a",
+			Channel:       "c",
+			Platform:      "android",
+			Version:       "1.0.0",
+			Timestamp:     utils.NowTimestamp(),
+			DateTimestamp: utils.TodayTimestamp(),
+		},
+		Amount:        6,
+		Currency:      "USD",
+		ProductId:     "coin",
+		TransactionId: transactionId,
+	}
+}
+
+func TestSavePurchase(t *testing.T) {
+	defer drop()
+
+	data := newPurchaseData("t1")
+	isNew, err := ms.savePurchase(data)
+	require.NoError(t, err)
+	require.True(t, isNew)
+
+	isNew, err = ms.savePurchase(data)
+	require.NoError(t, err)
+	require.False(t, isNew)
+
+	isNew, err = ms.savePurchase(newPurchaseData("t2"))
+	require.NoError(t, err)
+	require.True(t, isNew)
+}
+
+func TestDeviceFirstPurchase(t *testing.T) {
+	defer drop()
+
+	data := newPurchaseData("t1")
+	require.True(t, ms.deviceFirstPurchaseToday(data.MetaData))
+	require.False(t, ms.deviceFirstPurchaseToday(data.MetaData))
+	require.True(t, ms.deviceFirstPurchase(data.MetaData))
+	require.False(t, ms.deviceFirstPurchase(data.MetaData))
+
+	data.MetaData.DateTimestamp = utils.TodayDiff(1).Unix()
+	require.True(t, ms.deviceFirstPurchaseToday(data.MetaData))
+}
+
+func TestPurchaseEventHandler(t *testing.T) {
+	defer drop()
+
+	handler := purchaseEventHandler(ms)
+	data := newPurchaseData("t1")
+	require.NoError(t, handler.Handle(data))
+	require.NoError(t, handler.Handle(data))
+
+	today := utils.TodayTimestamp()
+	total, err := ms.GetSimpleCPVSumTotal(appId, RevenueCPVCounterPrefix+"USD", today, today)
+	require.NoError(t, err)
+	require.Equal(t, 6.0, total)
+
+	payingCount, err := ms.GetSimpleCPVSumTotal(appId, PayingUserCPVCounter, today, today)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, payingCount)
+}
@@ -0,0 +1,82 @@
+package segment
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
+)
+
+func SetupRoute(oRoute *gin.RouterGroup, publisher pubsub.Publisher, store Store) {
+	requireAdminRole := middlewares.RequireRoleMiddleware([]string{"admin"})
+	oGroup := oRoute.Group("/segment")
+	oGroup.GET("", getSegmentsHandler(store))
+	oGroup.POST("", requireAdminRole, createSegmentHandler(publisher, store))
+	oGroup.DELETE("", requireAdminRole, deleteSegmentHandler(store))
+}
+
+func getSegmentsHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		segments, err := store.getSegments(appId)
+		if err != nil {
+			c.Set("error", err)
+		} else {
+			c.Set("data", segments)
+		}
+	}
+}
+
+// createSegmentHandler saves the segment, its members are evaluated in the background and then
+// every night
+func createSegmentHandler(publisher pubsub.Publisher, store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var requestData segmentRequestData
+		if err := c.ShouldBindJSON(&requestData); err != nil {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		id, err := utils.RandomHexStringKey(16)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		s := Segment{
+			Id:         id,
+			Name:       requestData.Name,
+			Rules:      requestData.Rules,
+			WindowDays: requestData.WindowDays,
+			CreatedAt:  utils.NowTimestamp(),
+		}
+		if s.WindowDays == 0 {
+			s.WindowDays = defaultWindowDays
+		}
+		if !s.valid() {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if err = store.createSegment(appId, s); err != nil {
+			c.Set("error", err)
+			return
+		}
+		publisher.Publish(EvaluateEvent, &evaluateEventData{AppId: appId, SegmentId: s.Id})
+		c.Set("data", s)
+	}
+}
+
+func deleteSegmentHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		var tmp struct {
+			Id string `json:"id"`
+		}
+		if err := c.ShouldBindJSON(&tmp); err != nil || tmp.Id == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+		if err := store.deleteSegment(appId, tmp.Id); err != nil {
+			c.Set("error", err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package segment
+
+import (
+	"github.com/lt90s/goanalytics/utils"
+	"net/http"
+	"strings"
+)
+
+// rule fields over the device record maintained by metric/user
+const (
+	FieldChannel  = "channel"
+	FieldPlatform = "platform"
+	FieldVersion  = "version"
+	FieldCountry  = "country"
+	// the campaign the device is attributed to, see metric/attribution
+	FieldCampaign = "campaign"
+	// the timestamp the device is seen for the first time
+	FieldFirstSeen = "firstSeen"
+)
+
+// rule fields evaluated by the daily schedule only
+const (
+	// prefix of a property of the custom events of the device, e.g. "property.level"
+	FieldPropertyPrefix = "property."
+	// days the device is active and times the device opens the app in the segment window
+	FieldActiveDays = "activeDays"
+	FieldOpenCount  = "openCount"
+)
+
+const (
+	OperatorEq  = "eq"
+	OperatorNe  = "ne"
+	OperatorIn  = "in"
+	OperatorGt  = "gt"
+	OperatorGte = "gte"
+	OperatorLt  = "lt"
+	OperatorLte = "lte"
+)
+
+const (
+	DailyScheduleEvent = "SegmentDailyScheduleEvent"
+	// published when a segment is created, evaluated in the background
+	EvaluateEvent = "SegmentEvaluateEvent"
+)
+
+const (
+	maxSegmentRules      = 20
+	defaultWindowDays    = 30
+	maxSegmentWindowDays = 90
+)
+
+// Rule matches the devices whose Field compares to Value by Operator. Value is a list of
+// values for the in operator.
+type Rule struct {
+	Field    string      `json:"field" bson:"field"`
+	Operator string      `json:"operator" bson:"operator"`
+	Value    interface{} `json:"value" bson:"value"`
+}
+
+func isAttributeField(field string) bool {
+	switch field {
+	case FieldChannel, FieldPlatform, FieldVersion, FieldCountry, FieldCampaign, FieldFirstSeen:
+		return true
+	}
+	return false
+}
+
+func isNumberField(field string) bool {
+	return field == FieldFirstSeen || field == FieldActiveDays || field == FieldOpenCount
+}
+
+func isPropertyField(field string) bool {
+	key := strings.TrimPrefix(field, FieldPropertyPrefix)
+	// the key becomes a mongo field path
+	return strings.HasPrefix(field, FieldPropertyPrefix) && key != "" && !strings.ContainsAny(key, "$.")
+}
+
+// isScalar reports whether value is a string, a number or a boolean, values are used as is in
+// mongo filters and must not be documents holding operators
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, bool:
+		return true
+	}
+	_, ok := toFloat(value)
+	return ok
+}
+
+// isValueOf reports whether value has the type of the field, properties take any scalar
+func isValueOf(field string, value interface{}) bool {
+	if isNumberField(field) {
+		_, ok := toFloat(value)
+		return ok
+	}
+	if isAttributeField(field) {
+		_, ok := value.(string)
+		return ok
+	}
+	return isScalar(value)
+}
+
+func (r Rule) valid() bool {
+	if !isAttributeField(r.Field) && !isNumberField(r.Field) && !isPropertyField(r.Field) {
+		return false
+	}
+	switch r.Operator {
+	case OperatorEq, OperatorNe:
+		return isValueOf(r.Field, r.Value)
+	case OperatorIn:
+		values, ok := r.Value.([]interface{})
+		if !ok || len(values) == 0 || isNumberField(r.Field) {
+			return false
+		}
+		for _, value := range values {
+			if !isValueOf(r.Field, value) {
+				return false
+			}
+		}
+		return true
+	case OperatorGt, OperatorGte, OperatorLt, OperatorLte:
+		// string attributes are not ordered
+		if isAttributeField(r.Field) && !isNumberField(r.Field) {
+			return false
+		}
+		_, ok := toFloat(r.Value)
+		return ok
+	}
+	return false
+}
+
+// Segment is a saved set of rules, a device is a member when it matches all the rules.
+// Activity rules count the activity of the WindowDays days up to the evaluation date.
+type Segment struct {
+	Id         string `json:"id" bson:"_id"`
+	Name       string `json:"name" bson:"name"`
+	Rules      []Rule `json:"rules" bson:"rules"`
+	WindowDays int    `json:"windowDays" bson:"windowDays"`
+	CreatedAt  int64  `json:"createdAt" bson:"createdAt"`
+	// the date timestamp of the latest evaluation of the members
+	EvaluatedAt int64 `json:"evaluatedAt" bson:"evaluatedAt"`
+	// members of other generations are being written or deleted
+	Generation string `json:"-" bson:"generation"`
+}
+
+func (s Segment) valid() bool {
+	if s.Name == "" || len(s.Rules) == 0 || len(s.Rules) > maxSegmentRules {
+		return false
+	}
+	if s.WindowDays <= 0 || s.WindowDays > maxSegmentWindowDays {
+		return false
+	}
+	for _, rule := range s.Rules {
+		if !rule.valid() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsAttributeOnly reports whether the segment only has rules over the device record, whose
+// membership is updated as the device opens the app
+func (s Segment) IsAttributeOnly() bool {
+	for _, rule := range s.Rules {
+		if !isAttributeField(rule.Field) {
+			return false
+		}
+	}
+	return true
+}
+
+type segmentRequestData struct {
+	Name       string `json:"name"`
+	Rules      []Rule `json:"rules"`
+	WindowDays int    `json:"windowDays"`
+}
+
+type evaluateEventData struct {
+	AppId     string `json:"appId"`
+	SegmentId string `json:"segmentId"`
+}
+
+type DailyScheduleEventData struct {
+	AppId     string `json:"appId"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+var (
+	SegmentNotExistError     = utils.NewHttpError(http.StatusNotFound, http.StatusNotFound, "Segment not found")
+	CounterNotSupportedError = utils.NewHttpError(http.StatusBadRequest, http.StatusBadRequest,
+		"Counter cannot be filtered by segment or campaign, only new user, daily active, open app, purchase, paying user, "+
+			"usage time, revenue, country new user and customized counters can")
+)
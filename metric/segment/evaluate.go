@@ -0,0 +1,67 @@
+package segment
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+// evaluate returns the members of the segment on the date of timestamp. Property and activity
+// rules look at the WindowDays days up to and including the date.
+func evaluate(store Store, appId string, s Segment, timestamp int64) (map[string]bool, error) {
+	const day = 24 * 3600
+	windowStart := timestamp - int64(s.WindowDays-1)*day
+	windowEnd := timestamp + day
+
+	members := make(map[string]bool)
+	err := store.scanDevices(appId, AttributeFilter(s.Rules), func(deviceId string) {
+		members[deviceId] = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range s.Rules {
+		if len(members) == 0 {
+			break
+		}
+		var matched func(deviceId string) bool
+		switch {
+		case strings.HasPrefix(rule.Field, FieldPropertyPrefix):
+			filter := propertyFilter(rule)
+			filter["timestamp"] = bson.M{"$gte": windowStart, "$lt": windowEnd}
+			devices, err := store.propertyDevices(appId, filter)
+			if err != nil {
+				return nil, err
+			}
+			matched = func(deviceId string) bool { return devices[deviceId] }
+		case rule.Field == FieldActiveDays || rule.Field == FieldOpenCount:
+			collectionName := deviceActiveCollectionName
+			if rule.Field == FieldOpenCount {
+				collectionName = openAppDataCollectionName
+			}
+			counts, err := store.activityCounts(appId, collectionName, windowStart, windowEnd)
+			if err != nil {
+				return nil, err
+			}
+			value, _ := toFloat(rule.Value)
+			matched = func(deviceId string) bool { return compare(rule.Operator, counts[deviceId], value) }
+		default:
+			continue
+		}
+		for deviceId := range members {
+			if !matched(deviceId) {
+				delete(members, deviceId)
+			}
+		}
+	}
+	return members, nil
+}
+
+// evaluateSegment replaces the members of the segment by the ones of the date of timestamp
+func evaluateSegment(store Store, appId string, s Segment, timestamp int64) error {
+	members, err := evaluate(store, appId, s, timestamp)
+	if err != nil {
+		return err
+	}
+	return store.replaceMembers(appId, s.Id, members, timestamp)
+}
@@ -0,0 +1,60 @@
+package segment
+
+import (
+	"errors"
+	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func SetupProcessor(subscriber pubsub.Subscriber, store Store) {
+	err := subscriber.Subscribe(EvaluateEvent, evaluateEventHandler(store), evaluateEventData{})
+	if err != nil {
+		panic(err)
+	}
+
+	err = subscriber.Subscribe(DailyScheduleEvent, dailyScheduleEventHandler(store), DailyScheduleEventData{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// evaluateEventHandler evaluates a newly created segment on today
+func evaluateEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		eventData, ok := data.(*evaluateEventData)
+		if !ok {
+			return errors.New("SegmentEvaluateEventHandler: data is not of type *evaluateEventData")
+		}
+		s, found, err := store.getSegment(eventData.AppId, eventData.SegmentId)
+		if err != nil || !found {
+			return err
+		}
+		err = evaluateSegment(store, eventData.AppId, s, utils.TodayTimestamp())
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"data": eventData, "error": err.Error()}).Warn("evaluateSegment error")
+		}
+		return err
+	})
+}
+
+// dailyScheduleEventHandler evaluates every segment of the app on the date
+func dailyScheduleEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		eventData, ok := data.(*DailyScheduleEventData)
+		if !ok {
+			return errors.New("SegmentDailyScheduleEventHandler: data is not of type *DailyScheduleEventData")
+		}
+		segments, err := store.getSegments(eventData.AppId)
+		if err != nil {
+			return err
+		}
+		for _, s := range segments {
+			if err = evaluateSegment(store, eventData.AppId, s, eventData.Timestamp); err != nil {
+				logrus.WithFields(logrus.Fields{"data": eventData, "segment": s.Id, "error": err.Error()}).
+					Warn("evaluateSegment error")
+			}
+		}
+		return err
+	})
+}
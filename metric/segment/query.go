@@ -0,0 +1,115 @@
+package segment
+
+import (
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+// counterSource is the raw data a counter is counted from, so that the counter can be counted
+// again over the segment members
+type counterSource struct {
+	collectionName string
+	timeField      string
+	filter         bson.M
+	// summed field, every record counts 1 when empty
+	amountField string
+	// slot of the record for slot counters
+	slotField string
+	// a device is counted once a day
+	unique bool
+}
+
+// counter names are declared in metric/user, metric/revenue and metric/usage, which depend on
+// this package. Other counters are derived (ratios, distributions, retention) or lack raw
+// per device data and cannot be filtered by segment, see CounterNotSupportedError.
+var counterSources = map[string]counterSource{
+	"NewUserCPVCounter":     {collectionName: userCollectionName, timeField: "createdAt"},
+	"DailyActiveCPVCounter": activeSource,
+	"OpenAppCPVCounter":     {collectionName: openAppDataCollectionName, timeField: "timestamp"},
+	"PurchaseCPVCounter":    {collectionName: purchaseCollectionName, timeField: "timestamp"},
+	"PayingUserCPVCounter":  {collectionName: purchaseCollectionName, timeField: "timestamp", unique: true},
+	"UsageTimeCPVCounter":   {collectionName: deviceUsageTimeCollectionName, timeField: "date", amountField: "time"},
+
+	"RevenueSlotCounter": {collectionName: purchaseCollectionName, timeField: "timestamp", amountField: "amount",
+		slotField: "currency"},
+	"CountryNewUserSlotCounter": {collectionName: userCollectionName, timeField: "createdAt", slotField: "country"},
+}
+
+// revenue cpv counters are per currency, maintained by metric/revenue
+const revenueCPVCounterPrefix = "RevenueCPVCounter_"
+
+// a device is active once a day in deviceActiveCollection, timestamped by the date
+var activeSource = counterSource{collectionName: deviceActiveCollectionName, timeField: "timestamp"}
+
+// sourceOf returns the source of the counter, customized counters are counted from the event log
+func sourceOf(counterName string) (counterSource, bool) {
+	if strings.HasSuffix(counterName, storage.CustomizedCounterNameSuffix) {
+		return counterSource{
+			collectionName: mongodb.EventCollectionName,
+			timeField:      "timestamp",
+			filter:         bson.M{"name": strings.TrimSuffix(counterName, storage.CustomizedCounterNameSuffix)},
+			amountField:    "properties.amount",
+			slotField:      "properties.slot",
+		}, true
+	}
+	if strings.HasPrefix(counterName, revenueCPVCounterPrefix) {
+		return counterSource{
+			collectionName: purchaseCollectionName,
+			timeField:      "timestamp",
+			filter:         bson.M{"currency": strings.TrimPrefix(counterName, revenueCPVCounterPrefix)},
+			amountField:    "amount",
+		}, true
+	}
+	source, ok := counterSources[counterName]
+	return source, ok
+}
+
+// counted calls fn for the records of the source counted over the members
+func counted(store Store, appId string, source counterSource, members map[string]bool, start, end int64,
+	fn func(date int64, slot string, amount float64)) error {
+	seen := make(map[int64]map[string]bool)
+	return store.scanSource(appId, source, members, start, end, func(deviceId string, date int64, slot string, amount float64) {
+		if source.unique {
+			if seen[date] == nil {
+				seen[date] = make(map[string]bool)
+			}
+			if seen[date][deviceId] {
+				return
+			}
+			seen[date][deviceId] = true
+		}
+		fn(date, slot, amount)
+	})
+}
+
+// dateSum sums the source over the members by date between the dates start and end
+func dateSum(store Store, appId string, source counterSource, members map[string]bool, start, end int64) (map[int64]float64, error) {
+	sums := make(map[int64]float64)
+	err := counted(store, appId, source, members, start, end, func(date int64, slot string, amount float64) {
+		sums[date] += amount
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// slotSpan sums the source over the members by date and slot between the dates start and end
+func slotSpan(store Store, appId string, source counterSource, members map[string]bool, start, end int64) (storage.SlotCounters, error) {
+	span := make(storage.SlotCounters)
+	err := counted(store, appId, source, members, start, end, func(date int64, slot string, amount float64) {
+		if slot == "" {
+			return
+		}
+		if span[date] == nil {
+			span[date] = make(storage.SlotCounter)
+		}
+		span[date][slot] += amount
+	})
+	if err != nil {
+		return nil, err
+	}
+	return span, nil
+}
@@ -0,0 +1,80 @@
+package segment
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+var mongoOperators = map[string]string{
+	OperatorNe:  "$ne",
+	OperatorIn:  "$in",
+	OperatorGt:  "$gt",
+	OperatorGte: "$gte",
+	OperatorLt:  "$lt",
+	OperatorLte: "$lte",
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func condition(r Rule) interface{} {
+	if r.Operator == OperatorEq {
+		return r.Value
+	}
+	return bson.M{mongoOperators[r.Operator]: r.Value}
+}
+
+// AttributeFilter returns the filter of the device records matching the attribute rules
+func AttributeFilter(rules []Rule) bson.M {
+	filter := bson.M{}
+	conditions := make([]bson.M, 0)
+	for _, rule := range rules {
+		if !isAttributeField(rule.Field) {
+			continue
+		}
+		field := rule.Field
+		if field == FieldFirstSeen {
+			field = "createdAt"
+		}
+		conditions = append(conditions, bson.M{field: condition(rule)})
+	}
+	// several rules may apply to the same field
+	if len(conditions) > 0 {
+		filter["$and"] = conditions
+	}
+	return filter
+}
+
+// propertyFilter returns the filter of the custom events matching the property rule
+func propertyFilter(r Rule) bson.M {
+	return bson.M{"properties." + strings.TrimPrefix(r.Field, FieldPropertyPrefix): condition(r)}
+}
+
+func compare(operator string, a, b float64) bool {
+	switch operator {
+	case OperatorEq:
+		return a == b
+	case OperatorNe:
+		return a != b
+	case OperatorGt:
+		return a > b
+	case OperatorGte:
+		return a >= b
+	case OperatorLt:
+		return a < b
+	case OperatorLte:
+		return a <= b
+	}
+	return false
+}
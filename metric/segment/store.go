@@ -0,0 +1,384 @@
+package segment
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Store interface {
+	createSegment(appId string, s Segment) error
+	getSegment(appId, segmentId string) (s Segment, found bool, err error)
+	getSegments(appId string) ([]Segment, error)
+	// deleteSegment deletes the segment and its members
+	deleteSegment(appId, segmentId string) error
+
+	// scanDevices calls fn for the devices whose record matches the filter
+	scanDevices(appId string, filter bson.M, fn func(deviceId string)) error
+	// propertyDevices returns the devices with a custom event matching the filter
+	propertyDevices(appId string, filter bson.M) (map[string]bool, error)
+	// activityCounts counts the records of the collection of each device between start and end
+	activityCounts(appId, collectionName string, start, end int64) (map[string]float64, error)
+	// replaceMembers writes the members under a new generation and then switches the segment to
+	// it, so that readers never see a partially written segment
+	replaceMembers(appId, segmentId string, members map[string]bool, evaluatedAt int64) error
+	// getMembers returns the members of the current generation of the segment
+	getMembers(appId string, s Segment) (map[string]bool, error)
+	// scanSource calls fn for the records of the members in the counter source between the dates
+	// start and end
+	scanSource(appId string, source counterSource, members map[string]bool, start, end int64,
+		fn func(deviceId string, date int64, slot string, amount float64)) error
+
+	// SegmentDateSum sums the counter over the segment members by date, see counterSources
+	SegmentDateSum(appId, segmentId, counterName string, start, end int64) (map[int64]float64, error)
+	// SegmentSlotSpan sums the slot counter over the segment members by date and slot
+	SegmentSlotSpan(appId, segmentId, counterName string, start, end int64) (storage.SlotCounters, error)
+	// SegmentUniqueActive counts the segment members active between the dates start and end
+	SegmentUniqueActive(appId, segmentId string, start, end int64) (float64, error)
+	// SegmentMembers returns the members of the segment, nil when the segment does not exist
+	SegmentMembers(appId, segmentId string) (map[string]bool, error)
+
+	// CampaignDateSum sums the counter over the devices attributed to the campaign by date
+	CampaignDateSum(appId, campaignId, counterName string, start, end int64) (map[int64]float64, error)
+	// CampaignSlotSpan sums the slot counter over the devices attributed to the campaign by date and slot
+	CampaignSlotSpan(appId, campaignId, counterName string, start, end int64) (storage.SlotCounters, error)
+}
+
+const (
+	SegmentCollectionName = "segmentCollection"
+	// {segmentId, deviceId, generation}, replaced by the daily evaluation and updated by
+	// metric/user as devices open the app for attribute only segments
+	MemberCollectionName = "segmentMemberCollection"
+
+	// maintained by metric/user
+	userCollectionName         = "userCollection"
+	deviceActiveCollectionName = "deviceActiveCollection"
+	openAppDataCollectionName  = "openAppData"
+	// maintained by metric/revenue
+	purchaseCollectionName = "purchaseCollection"
+	// maintained by metric/usage
+	deviceUsageTimeCollectionName = "deviceUsageTimeCollection"
+)
+
+type mongodbStore struct {
+	client         *mongo.Client
+	databasePrefix string
+}
+
+func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
+	return &mongodbStore{
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (ms *mongodbStore) database(appId string) *mongo.Database {
+	return ms.client.Database(ms.databasePrefix + appId)
+}
+
+func (ms *mongodbStore) createSegment(appId string, s Segment) error {
+	_, err := ms.database(appId).Collection(SegmentCollectionName).InsertOne(context.Background(), s)
+	return err
+}
+
+func (ms *mongodbStore) getSegment(appId, segmentId string) (s Segment, found bool, err error) {
+	result := ms.database(appId).Collection(SegmentCollectionName).FindOne(context.Background(), bson.M{"_id": segmentId})
+	err = result.Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return s, false, nil
+	}
+	return s, err == nil, err
+}
+
+func (ms *mongodbStore) getSegments(appId string) ([]Segment, error) {
+	ctx := context.Background()
+	option := options.Find().SetSort(bson.M{"createdAt": -1})
+	cursor, err := ms.database(appId).Collection(SegmentCollectionName).Find(ctx, bson.M{}, option)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	segments := make([]Segment, 0)
+	for cursor.Next(ctx) {
+		var s Segment
+		if err = cursor.Decode(&s); err != nil {
+			return nil, err
+		}
+		segments = append(segments, s)
+	}
+	return segments, cursor.Err()
+}
+
+func (ms *mongodbStore) deleteSegment(appId, segmentId string) error {
+	ctx := context.Background()
+	_, err := ms.database(appId).Collection(SegmentCollectionName).DeleteOne(ctx, bson.M{"_id": segmentId})
+	if err != nil {
+		return err
+	}
+	_, err = ms.database(appId).Collection(MemberCollectionName).DeleteMany(ctx, bson.M{"segmentId": segmentId})
+	return err
+}
+
+func (ms *mongodbStore) scanDevices(appId string, filter bson.M, fn func(deviceId string)) error {
+	ctx := context.Background()
+	option := options.Find().SetProjection(bson.M{"deviceId": 1})
+	cursor, err := ms.database(appId).Collection(userCollectionName).Find(ctx, filter, option)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	var tmp struct {
+		DeviceId string `bson:"deviceId"`
+	}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&tmp); err != nil {
+			return err
+		}
+		fn(tmp.DeviceId)
+	}
+	return cursor.Err()
+}
+
+func (ms *mongodbStore) propertyDevices(appId string, filter bson.M) (map[string]bool, error) {
+	ctx := context.Background()
+	values, err := ms.database(appId).Collection(mongodb.EventCollectionName).Distinct(ctx, "deviceId", filter)
+	if err != nil {
+		return nil, err
+	}
+	devices := make(map[string]bool, len(values))
+	for _, value := range values {
+		if deviceId, ok := value.(string); ok {
+			devices[deviceId] = true
+		}
+	}
+	return devices, nil
+}
+
+func (ms *mongodbStore) activityCounts(appId, collectionName string, start, end int64) (map[string]float64, error) {
+	ctx := context.Background()
+	pipeline := []bson.M{
+		{"$match": bson.M{"timestamp": bson.M{"$gte": start, "$lt": end}}},
+		{"$group": bson.M{"_id": "$deviceId", "count": bson.M{"$sum": 1}}},
+	}
+	cursor, err := ms.database(appId).Collection(collectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	counts := make(map[string]float64)
+	var tmp struct {
+		DeviceId string  `bson:"_id"`
+		Count    float64 `bson:"count"`
+	}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&tmp); err != nil {
+			return nil, err
+		}
+		counts[tmp.DeviceId] = tmp.Count
+	}
+	return counts, cursor.Err()
+}
+
+func (ms *mongodbStore) replaceMembers(appId, segmentId string, members map[string]bool, evaluatedAt int64) error {
+	generation, err := utils.RandomHexStringKey(8)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	collection := ms.database(appId).Collection(MemberCollectionName)
+	documents := make([]interface{}, 0, len(members))
+	for deviceId := range members {
+		documents = append(documents, bson.M{"segmentId": segmentId, "deviceId": deviceId, "generation": generation})
+	}
+	if len(documents) > 0 {
+		if _, err = collection.InsertMany(ctx, documents); err != nil {
+			return err
+		}
+	}
+	_, err = ms.database(appId).Collection(SegmentCollectionName).UpdateOne(ctx, bson.M{"_id": segmentId},
+		bson.M{"$set": bson.M{"generation": generation, "evaluatedAt": evaluatedAt}})
+	if err != nil {
+		return err
+	}
+	_, err = collection.DeleteMany(ctx, bson.M{"segmentId": segmentId, "generation": bson.M{"$ne": generation}})
+	return err
+}
+
+func (ms *mongodbStore) getMembers(appId string, s Segment) (map[string]bool, error) {
+	ctx := context.Background()
+	filter := bson.M{"segmentId": s.Id, "generation": s.Generation}
+	option := options.Find().SetProjection(bson.M{"deviceId": 1})
+	cursor, err := ms.database(appId).Collection(MemberCollectionName).Find(ctx, filter, option)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	members := make(map[string]bool)
+	var tmp struct {
+		DeviceId string `bson:"deviceId"`
+	}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&tmp); err != nil {
+			return nil, err
+		}
+		members[tmp.DeviceId] = true
+	}
+	return members, cursor.Err()
+}
+
+// members matched at once by scanSource
+const scanBatchSize = 1000
+
+// scanSource matches the members in batches, so that only their records are read
+func (ms *mongodbStore) scanSource(appId string, source counterSource, members map[string]bool, start, end int64,
+	fn func(deviceId string, date int64, slot string, amount float64)) error {
+	deviceIds := make([]string, 0, len(members))
+	for deviceId := range members {
+		deviceIds = append(deviceIds, deviceId)
+	}
+	for len(deviceIds) > 0 {
+		batch := deviceIds
+		if len(batch) > scanBatchSize {
+			batch = batch[:scanBatchSize]
+		}
+		deviceIds = deviceIds[len(batch):]
+		if err := ms.scanSourceBatch(appId, source, batch, start, end, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *mongodbStore) scanSourceBatch(appId string, source counterSource, deviceIds []string, start, end int64,
+	fn func(deviceId string, date int64, slot string, amount float64)) error {
+	ctx := context.Background()
+	match := bson.M{
+		source.timeField: bson.M{"$gte": start, "$lt": end + 24*3600},
+		"deviceId":       bson.M{"$in": deviceIds},
+	}
+	for key, value := range source.filter {
+		match[key] = value
+	}
+	amount := interface{}(bson.M{"$literal": 1})
+	if source.amountField != "" {
+		amount = "$" + source.amountField
+	}
+	project := bson.M{"deviceId": 1, "timestamp": "$" + source.timeField, "amount": amount}
+	if source.slotField != "" {
+		project["slot"] = "$" + source.slotField
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$project": project},
+	}
+	cursor, err := ms.database(appId).Collection(source.collectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	var tmp struct {
+		DeviceId  string  `bson:"deviceId"`
+		Timestamp int64   `bson:"timestamp"`
+		Amount    float64 `bson:"amount"`
+		Slot      string  `bson:"slot"`
+	}
+	for cursor.Next(ctx) {
+		tmp.Amount = 0
+		tmp.Slot = ""
+		if err = cursor.Decode(&tmp); err != nil {
+			return err
+		}
+		fn(tmp.DeviceId, utils.TimestampToDate(tmp.Timestamp).Unix(), tmp.Slot, tmp.Amount)
+	}
+	return cursor.Err()
+}
+
+func (ms *mongodbStore) SegmentMembers(appId, segmentId string) (map[string]bool, error) {
+	s, found, err := ms.getSegment(appId, segmentId)
+	if err != nil || !found {
+		return nil, err
+	}
+	return ms.getMembers(appId, s)
+}
+
+func (ms *mongodbStore) SegmentDateSum(appId, segmentId, counterName string, start, end int64) (map[int64]float64, error) {
+	source, ok := sourceOf(counterName)
+	if !ok {
+		return nil, CounterNotSupportedError
+	}
+	members, err := ms.SegmentMembers(appId, segmentId)
+	if err != nil {
+		return nil, err
+	}
+	if members == nil {
+		return nil, SegmentNotExistError
+	}
+	return dateSum(ms, appId, source, members, start, end)
+}
+
+func (ms *mongodbStore) SegmentSlotSpan(appId, segmentId, counterName string, start, end int64) (storage.SlotCounters, error) {
+	source, ok := sourceOf(counterName)
+	if !ok || source.slotField == "" {
+		return nil, CounterNotSupportedError
+	}
+	members, err := ms.SegmentMembers(appId, segmentId)
+	if err != nil {
+		return nil, err
+	}
+	if members == nil {
+		return nil, SegmentNotExistError
+	}
+	return slotSpan(ms, appId, source, members, start, end)
+}
+
+func (ms *mongodbStore) SegmentUniqueActive(appId, segmentId string, start, end int64) (float64, error) {
+	members, err := ms.SegmentMembers(appId, segmentId)
+	if err != nil {
+		return 0, err
+	}
+	if members == nil {
+		return 0, SegmentNotExistError
+	}
+	active := make(map[string]bool)
+	err = ms.scanSource(appId, activeSource, members, start, end, func(deviceId string, date int64, slot string, amount float64) {
+		active[deviceId] = true
+	})
+	return float64(len(active)), err
+}
+
+// campaignDevices returns the devices attributed to the campaign
+func (ms *mongodbStore) campaignDevices(appId, campaignId string) (map[string]bool, error) {
+	devices := make(map[string]bool)
+	err := ms.scanDevices(appId, bson.M{FieldCampaign: campaignId}, func(deviceId string) {
+		devices[deviceId] = true
+	})
+	return devices, err
+}
+
+func (ms *mongodbStore) CampaignDateSum(appId, campaignId, counterName string, start, end int64) (map[int64]float64, error) {
+	source, ok := sourceOf(counterName)
+	if !ok {
+		return nil, CounterNotSupportedError
+	}
+	devices, err := ms.campaignDevices(appId, campaignId)
+	if err != nil {
+		return nil, err
+	}
+	return dateSum(ms, appId, source, devices, start, end)
+}
+
+func (ms *mongodbStore) CampaignSlotSpan(appId, campaignId, counterName string, start, end int64) (storage.SlotCounters, error) {
+	source, ok := sourceOf(counterName)
+	if !ok || source.slotField == "" {
+		return nil, CounterNotSupportedError
+	}
+	devices, err := ms.campaignDevices(appId, campaignId)
+	if err != nil {
+		return nil, err
+	}
+	return slotSpan(ms, appId, source, devices, start, end)
+}
@@ -0,0 +1,146 @@
+package segment
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+const (
+	appId = "testAppId"
+)
+
+func TestSegmentValid(t *testing.T) {
+	rules := []Rule{
+		{Field: FieldChannel, Operator: OperatorIn, Value: []interface{}{"a", "b"}},
+		{Field: FieldFirstSeen, Operator: OperatorGte, Value: float64(100)},
+		{Field: "property.level", Operator: OperatorGt, Value: float64(3)},
+		{Field: FieldActiveDays, Operator: OperatorGte, Value: float64(2)},
+	}
+	s := Segment{Name: "s", Rules: rules, WindowDays: 7}
+	require.True(t, s.valid())
+	require.False(t, s.IsAttributeOnly())
+	require.True(t, Segment{Name: "s", Rules: rules[:2], WindowDays: 7}.IsAttributeOnly())
+
+	require.False(t, Segment{Name: "s", Rules: rules, WindowDays: 0}.valid())
+	require.False(t, Rule{Field: "unknown", Operator: OperatorEq, Value: "a"}.valid())
+	require.False(t, Rule{Field: "property.", Operator: OperatorEq, Value: "a"}.valid())
+	require.False(t, Rule{Field: FieldOpenCount, Operator: OperatorGt, Value: "a"}.valid())
+	require.False(t, Rule{Field: FieldChannel, Operator: OperatorIn, Value: "a"}.valid())
+
+	// values and property keys must not inject mongo operators
+	require.False(t, Rule{Field: FieldChannel, Operator: OperatorEq, Value: map[string]interface{}{"$regex": "."}}.valid())
+	require.False(t, Rule{Field: "property.level", Operator: OperatorNe, Value: map[string]interface{}{"$where": "1"}}.valid())
+	require.False(t, Rule{Field: FieldChannel, Operator: OperatorIn, Value: []interface{}{"a", map[string]interface{}{}}}.valid())
+	require.False(t, Rule{Field: FieldChannel, Operator: OperatorEq, Value: 1.0}.valid())
+	require.False(t, Rule{Field: "property.$where", Operator: OperatorEq, Value: "a"}.valid())
+	require.False(t, Rule{Field: "property.a.b", Operator: OperatorEq, Value: "a"}.valid())
+	require.True(t, Rule{Field: "property.vip", Operator: OperatorEq, Value: true}.valid())
+	require.True(t, Rule{Field: FieldCampaign, Operator: OperatorIn, Value: []interface{}{"c1", "c2"}}.valid())
+}
+
+func TestEvaluate(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, "test_")
+	database := client.Database("test_" + appId)
+	defer database.Drop(context.Background())
+
+	const day = 24 * 3600
+	today := utils.TodayTimestamp()
+	ctx := context.Background()
+	for deviceId, channel := range map[string]string{"a": "x", "b": "x", "c": "y"} {
+		_, err := database.Collection(userCollectionName).InsertOne(ctx, bson.M{
+			"deviceId": deviceId, "channel": channel, "campaign": "c" + channel, "createdAt": today - 10*day,
+		})
+		require.NoError(t, err)
+	}
+	// a is active 2 days and b 1 day in the window
+	for _, active := range []bson.M{
+		{"deviceId": "a", "timestamp": today},
+		{"deviceId": "a", "timestamp": today - day},
+		{"deviceId": "b", "timestamp": today},
+		{"deviceId": "b", "timestamp": today - 5*day},
+		{"deviceId": "c", "timestamp": today},
+	} {
+		_, err := database.Collection(deviceActiveCollectionName).InsertOne(ctx, active)
+		require.NoError(t, err)
+	}
+	_, err := database.Collection(mongodb.EventCollectionName).InsertOne(ctx, bson.M{
+		"name": "level", "deviceId": "b", "timestamp": today + 10, "properties": bson.M{"amount": 2.0, "level": 5},
+	})
+	require.NoError(t, err)
+
+	s := Segment{
+		Id:   "s1",
+		Name: "active x",
+		Rules: []Rule{
+			{Field: FieldChannel, Operator: OperatorEq, Value: "x"},
+			{Field: FieldActiveDays, Operator: OperatorGte, Value: float64(2)},
+		},
+		WindowDays: 3,
+	}
+	require.NoError(t, store.createSegment(appId, s))
+	require.NoError(t, evaluateSegment(store, appId, s, today))
+	members, err := store.SegmentMembers(appId, s.Id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"a": true}, members)
+
+	s = Segment{
+		Id:         "s2",
+		Name:       "level",
+		Rules:      []Rule{{Field: "property.level", Operator: OperatorGte, Value: float64(5)}},
+		WindowDays: 3,
+	}
+	require.NoError(t, store.createSegment(appId, s))
+	require.NoError(t, evaluateSegment(store, appId, s, today))
+	members, err = store.SegmentMembers(appId, s.Id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"b": true}, members)
+
+	// counters over the members
+	sums, err := store.SegmentDateSum(appId, "s1", "DailyActiveCPVCounter", today-day, today)
+	require.NoError(t, err)
+	require.Equal(t, map[int64]float64{today - day: 1, today: 1}, sums)
+	sums, err = store.SegmentDateSum(appId, "s2", "level"+storage.CustomizedCounterNameSuffix, today, today)
+	require.NoError(t, err)
+	require.Equal(t, map[int64]float64{today: 2}, sums)
+	_, err = store.SegmentDateSum(appId, "s2", "unknown", today, today)
+	require.Equal(t, CounterNotSupportedError, err)
+	_, err = database.Collection(purchaseCollectionName).InsertOne(ctx, bson.M{
+		"deviceId": "b", "timestamp": today + 10, "currency": "USD", "amount": 1.5,
+	})
+	require.NoError(t, err)
+	span, err := store.SegmentSlotSpan(appId, "s2", "RevenueSlotCounter", today, today)
+	require.NoError(t, err)
+	require.Equal(t, storage.SlotCounters{today: {"USD": 1.5}}, span)
+	_, err = store.SegmentSlotSpan(appId, "s2", "DailyActiveCPVCounter", today, today)
+	require.Equal(t, CounterNotSupportedError, err)
+	active, err := store.SegmentUniqueActive(appId, "s2", today-7*day, today)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, active)
+
+	// counters over the devices attributed to a campaign
+	sums, err = store.CampaignDateSum(appId, "cx", "DailyActiveCPVCounter", today-day, today)
+	require.NoError(t, err)
+	require.Equal(t, map[int64]float64{today - day: 1, today: 2}, sums)
+	span, err = store.CampaignSlotSpan(appId, "cy", "RevenueSlotCounter", today, today)
+	require.NoError(t, err)
+	require.Len(t, span, 0)
+
+	// evaluating again switches to a new generation and removes the previous one
+	s1, _, err := store.getSegment(appId, "s1")
+	require.NoError(t, err)
+	require.NoError(t, evaluateSegment(store, appId, s1, today))
+	count, err := database.Collection(MemberCollectionName).CountDocuments(ctx, bson.M{"segmentId": "s1"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	require.NoError(t, store.deleteSegment(appId, "s1"))
+	members, err = store.SegmentMembers(appId, "s1")
+	require.NoError(t, err)
+	require.Nil(t, members)
+}
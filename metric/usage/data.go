@@ -13,10 +13,14 @@ const (
 	EachUsageAverageTimeSimpleCounter     = "EachUsageAverageTimeSimpleCounter"
 	DailyUsageTimeDistributionSlotCounter = "DailyUsageTimeDistributionSlotCounter"
 	DailyUsageAverageTimeSimpleCounter    = "DailyUsageAverageTimeSimpleCounter"
+
+	// usage seconds by channel, platform and version
+	UsageTimeCPVCounter = "UsageTimeCPVCounter"
 )
 
 const (
-	DailyScheduleEvent = "UsageDailyScheduleEvent"
+	DailyScheduleEvent    = "UsageDailyScheduleEvent"
+	LateDataScheduleEvent = "UsageLateDataScheduleEvent"
 )
 
 var (
@@ -33,7 +37,7 @@ var (
 )
 
 type usageTimeRequestData struct {
-	Seconds float64 `json:"seconds"'`
+	Seconds float64 `json:"seconds"`
 }
 
 type usageTimeData struct {
@@ -3,6 +3,7 @@ package usage
 import (
 	"errors"
 	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,6 +17,11 @@ func SetupProcessor(subscriber pubsub.Subscriber, store Store) {
 	if err != nil {
 		panic(err)
 	}
+
+	err = subscriber.Subscribe(LateDataScheduleEvent, lateDataScheduleEventHandler(store), LateDataScheduleEventData{})
+	if err != nil {
+		panic(err)
+	}
 }
 
 func usageTimeEventHandler(store Store) pubsub.EventHandler {
@@ -43,6 +49,14 @@ func usageTimeEventHandler(store Store) pubsub.EventHandler {
 		}
 
 
+		metadata := timeData.MetaData
+		err = store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform, metadata.Version,
+			UsageTimeCPVCounter, metadata.DateTimestamp, timeData.Seconds)
+		if err != nil {
+			entry.Warn("Add UsageTimeCPVCounter error: ", err.Error())
+			return err
+		}
+
 		slot := timeDistribution2Slot(timeData.Seconds)
 		err = store.AddSlotCounter(timeData.MetaData.AppId, EachUsageTimeDistributionSlotCounter, slot, timeData.MetaData.DateTimestamp, 1.0)
 		if err != nil {
@@ -50,6 +64,18 @@ func usageTimeEventHandler(store Store) pubsub.EventHandler {
 			return err
 		}
 
+		// per device daily usage time, used by the daily computations
+		err = store.addDeviceUsageTime(timeData)
+		if err != nil {
+			entry.Warn("addDeviceUsageTime error: ", err.Error())
+			return err
+		}
+
+		// derived daily data of a past day has to be computed again
+		if timeData.MetaData.DateTimestamp < utils.TodayTimestamp() {
+			store.MarkDailyLate(timeData.MetaData.AppId, DailyScheduleEvent, timeData.MetaData.DateTimestamp)
+		}
+
 		return nil
 	})
 }
@@ -19,25 +19,56 @@ func dailyScheduleEventHandler(store Store) pubsub.EventHandler {
 			return errors.New("UserDailyScheduleEventHandler: data is not of type *UserDailyScheduleEventData")
 		}
 
-		err := calculateEachUsageAverageTime(eventData, store)
-		if err != nil {
-			entry.Warn("calculateEachUsageAverageTime error: ", err.Error())
-		}
+		return calculateDaily(eventData, store, entry)
+	})
+}
 
-		err = calculateDailyUsageAverageTime(eventData, store)
-		if err != nil {
-			entry.Warn("calculateDailyUsageAverageTime error: ", err.Error())
-		}
+type LateDataScheduleEventData struct {
+	AppId string `json:"appId"`
+}
 
-		err = calculateDailyUsageTimeDistribution(eventData, store)
+// lateDataScheduleEventHandler recomputes the days that received data after their daily computations ran
+func lateDataScheduleEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		eventData, ok := data.(*LateDataScheduleEventData)
+		if !ok {
+			return errors.New("UsageLateDataScheduleEventHandler: data is not of type *LateDataScheduleEventData")
+		}
+		dates, err := store.PopDailyLate(eventData.AppId, DailyScheduleEvent)
 		if err != nil {
-			entry.Warn("calculateDailyUsageTimeDistribution error: ", err.Error())
+			return err
+		}
+		for _, date := range dates {
+			entry := logrus.WithFields(logrus.Fields{"appId": eventData.AppId, "timestamp": date})
+			entry.Info("Recompute usage daily data")
+			err = calculateDaily(&DailyScheduleEventData{Timestamp: date, AppId: eventData.AppId}, store, entry)
 		}
-
 		return err
 	})
 }
 
+func calculateDaily(data *DailyScheduleEventData, store Store, entry *logrus.Entry) error {
+	err := calculateEachUsageAverageTime(data, store)
+	if err != nil {
+		entry.Warn("calculateEachUsageAverageTime error: ", err.Error())
+	}
+
+	err = calculateDailyUsageAverageTime(data, store)
+	if err != nil {
+		entry.Warn("calculateDailyUsageAverageTime error: ", err.Error())
+	}
+
+	err = calculateDailyUsageTimeDistribution(data, store)
+	if err != nil {
+		entry.Warn("calculateDailyUsageTimeDistribution error: ", err.Error())
+	}
+
+	if err := store.MarkDailyProcessed(data.AppId, DailyScheduleEvent, data.Timestamp); err != nil {
+		entry.Warn("MarkDailyProcessed error: ", err.Error())
+	}
+	return err
+}
+
 func calculateEachUsageAverageTime(data *DailyScheduleEventData, store Store) error {
 	totalTime, err := store.GetSimpleCounterSum(data.AppId, UsageTimeTotalSimpleCounter, data.Timestamp, data.Timestamp)
 	if err != nil {
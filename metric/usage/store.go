@@ -18,6 +18,8 @@ const (
 
 type Store interface {
 	storage.Counter
+	storage.DailyTracker
+	addDeviceUsageTime(data *usageTimeData) error
 	getTotalUsageTime(appId string, date int64) (float64, error)
 	getDeviceCount(appId string, date int64) (int64, error)
 	calculateDailyUsageTimeDistribution(appId string, date int64) error
@@ -25,6 +27,7 @@ type Store interface {
 
 type mongodbStore struct {
 	storage.Counter
+	storage.DailyTracker
 	client         *mongo.Client
 	databasePrefix string
 }
@@ -32,6 +35,7 @@ type mongodbStore struct {
 func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
 	return &mongodbStore{
 		Counter:        mongodb.NewCounter(client, databasePrefix),
+		DailyTracker:   mongodb.NewDailyTracker(client, databasePrefix),
 		client:         client,
 		databasePrefix: databasePrefix,
 	}
@@ -123,13 +127,20 @@ func (ms *mongodbStore) calculateDailyUsageTimeDistribution(appId string, date i
 		Time float64 `bson:"time"`
 	}
 	entry := logrus.WithFields(logrus.Fields{"apppId": appId, "counter": DailyUsageTimeDistributionSlotCounter, "date": date})
+	// the distribution is set rather than added so that the day can be computed again
+	distribution := make(map[string]float64)
 	for cursor.Next(ctx) {
 		err = cursor.Decode(&tmp)
 		if err != nil {
 			return err
 		}
-		slot := timeDistribution2Slot(tmp.Time)
-		err = ms.SetSlotCounter(appId, DailyUsageTimeDistributionSlotCounter, slot, date, 1.0)
+		distribution[timeDistribution2Slot(tmp.Time)] += 1.0
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+	for slot, count := range distribution {
+		err = ms.SetSlotCounter(appId, DailyUsageTimeDistributionSlotCounter, slot, date, count)
 		if err != nil {
 			entry.Warnf("SetSlotCounter error: slot=%v error=%v", slot, err.Error())
 		}
@@ -0,0 +1,35 @@
+package user
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// calcActiveUserWindows computes the unique active users of the 7 days and the 30 days ending
+// on the date, and the stickiness of the date, the daily active users over the monthly active
+// users. Users are counted on their latest channel, platform and version.
+func (ms *mongodbStore) calcActiveUserWindows(appId string, timestamp int64) error {
+	const day = 24 * 3600
+	accumulators := bson.M{
+		"weekly": bson.M{"$max": bson.M{"$gte": bson.A{"$timestamp", timestamp - 6*day}}},
+	}
+	conditions := bson.M{
+		WeeklyActiveCPVCounter:  "$weekly",
+		MonthlyActiveCPVCounter: true,
+	}
+	counts, err := ms.countActiveCPV(appId, timestamp-29*day, timestamp, accumulators, nil, conditions)
+	if err != nil {
+		return err
+	}
+	if err = ms.setCPVCounts(appId, timestamp, counts); err != nil {
+		return err
+	}
+
+	monthlyActiveCount := 0.0
+	for _, count := range counts[MonthlyActiveCPVCounter] {
+		monthlyActiveCount += count
+	}
+	dailyActiveCount, err := ms.GetSimpleCPVSumTotal(appId, DailyActiveCPVCounter, timestamp, timestamp)
+	if err != nil {
+		return err
+	}
+	stickiness := calculatePercent(dailyActiveCount, monthlyActiveCount)
+	return ms.SetSimpleCounter(appId, DailyActiveStickinessSimpleCounter, timestamp, stickiness)
+}
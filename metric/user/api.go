@@ -4,15 +4,20 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lt90s/goanalytics/api/middlewares"
 	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"strconv"
 )
 
 func SetupRoute(iRoute *gin.RouterGroup, oRoute *gin.RouterGroup, publisher pubsub.Publisher, store Store) {
 	iGroup := iRoute.Group("/user")
 	iGroup.POST("open_app", openAppHandler(publisher))
+	iGroup.POST("/identify", identifyHandler(publisher))
+	iGroup.POST("/alias", aliasHandler(publisher))
 
-	//oGroup := oRoute.Group("/user")
+	oGroup := oRoute.Group("/user")
+	oGroup.GET("/registration_conversion", registrationConversionHandler(store))
 }
 
 func openAppHandler(publisher pubsub.Publisher) gin.HandlerFunc {
@@ -27,3 +32,70 @@ func openAppHandler(publisher pubsub.Publisher) gin.HandlerFunc {
 		publisher.Publish(EventUserOpenApp, metadata)
 	})
 }
+
+// identifyHandler links the device to the user id of the request
+func identifyHandler(publisher pubsub.Publisher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		metadata, ok := middlewares.GetMetaData(c)
+		if !ok {
+			log.Error("[identifyHandler] MetaData missing")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if metadata.UserId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		publisher.Publish(EventUserIdentify, metadata)
+	}
+}
+
+// aliasHandler merges a previous user id, e.g. a guest account, into the user id of the request
+func aliasHandler(publisher pubsub.Publisher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var requestData aliasRequestData
+		err := c.ShouldBindJSON(&requestData)
+		if err != nil || requestData.PreviousId == "" {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		metadata, ok := middlewares.GetMetaData(c)
+		if !ok {
+			log.Error("[aliasHandler] MetaData missing")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if metadata.UserId == "" || metadata.UserId == requestData.PreviousId {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		publisher.Publish(EventUserAlias, &aliasData{
+			MetaData:   metadata,
+			PreviousId: requestData.PreviousId,
+		})
+	}
+}
+
+// registrationConversionHandler reports by channel how many of the devices installed between
+// start and end registered
+func registrationConversionHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appId := c.GetString("appId")
+		start, err1 := strconv.ParseInt(c.Query("start"), 10, 64)
+		end, err2 := strconv.ParseInt(c.Query("end"), 10, 64)
+		if err1 != nil || err2 != nil || start > end {
+			c.Set("error", utils.ParamError)
+			return
+		}
+
+		conversions, err := getRegistrationConversions(store, appId, start, end)
+		if err != nil {
+			c.Set("error", err)
+			return
+		}
+		c.Set("data", conversions)
+	}
+}
@@ -0,0 +1,132 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/conf"
+	"github.com/lt90s/goanalytics/metric/attribution"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// New users are attributed to the click of a campaign tracking link, see metric/attribution.
+// The click id reported as the referrer on the first open is matched within the referrer
+// window, otherwise the latest click with the same fingerprint within the fingerprint window.
+// A click is matched to one device at most.
+
+// matchCampaignClick claims the click of the new user and returns its campaign, empty when
+// no click matches
+func (ms *mongodbStore) matchCampaignClick(data *middlewares.MetaData, referrerWindow,
+	fingerprintWindow int64) (campaignId string, matchedBy string, err error) {
+	collection := ms.database(data.AppId).Collection(attribution.ClickCollectionName)
+	update := bson.M{"$set": bson.M{"deviceId": data.DeviceId}}
+	var clicked struct {
+		CampaignId string `bson:"campaignId"`
+	}
+
+	// clicks are timed by the server
+	if data.Referrer != "" {
+		filter := bson.M{
+			"_id":       data.Referrer,
+			"deviceId":  bson.M{"$exists": false},
+			"timestamp": bson.M{"$gte": data.Timestamp - referrerWindow, "$lte": data.ServerTimestamp},
+		}
+		err = collection.FindOneAndUpdate(context.Background(), filter, update).Decode(&clicked)
+		if err == nil {
+			return clicked.CampaignId, attribution.MatchedByReferrer, nil
+		} else if err != mongo.ErrNoDocuments {
+			return "", "", err
+		}
+	}
+
+	if data.ClientIP == "" {
+		return "", "", nil
+	}
+	filter := bson.M{
+		"fingerprint": attribution.Fingerprint(data.ClientIP, data.Platform),
+		"deviceId":    bson.M{"$exists": false},
+		"timestamp":   bson.M{"$gte": data.Timestamp - fingerprintWindow, "$lte": data.ServerTimestamp},
+	}
+	option := options.FindOneAndUpdate().SetSort(bson.M{"timestamp": -1})
+	err = collection.FindOneAndUpdate(context.Background(), filter, update, option).Decode(&clicked)
+	if err == mongo.ErrNoDocuments {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	return clicked.CampaignId, attribution.MatchedByFingerprint, nil
+}
+
+// setUserCampaign stores the attributed campaign on the user record
+func (ms *mongodbStore) setUserCampaign(data *middlewares.MetaData, campaignId, matchedBy string) error {
+	filter := bson.M{"deviceId": data.DeviceId}
+	update := bson.M{
+		"$set": bson.M{
+			"campaign":          campaignId,
+			"campaignMatchedBy": matchedBy,
+		},
+	}
+	_, err := ms.database(data.AppId).Collection(userCollectionName).UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+// getUserCampaign returns the attributed campaign of the device, empty when not attributed
+func (ms *mongodbStore) getUserCampaign(appId, deviceId string) (campaignId string, createdAt int64, err error) {
+	option := &options.FindOneOptions{
+		Projection: bson.M{"campaign": 1, "createdAt": 1},
+	}
+	result := ms.database(appId).Collection(userCollectionName).FindOne(context.Background(), bson.M{"deviceId": deviceId}, option)
+	var ob struct {
+		Campaign  string `bson:"campaign"`
+		CreatedAt int64  `bson:"createdAt"`
+	}
+	err = result.Decode(&ob)
+	return ob.Campaign, ob.CreatedAt, err
+}
+
+// attributeNewUser matches the new user to a campaign click and counts it for the campaign
+func attributeNewUser(store Store, metadata *middlewares.MetaData) {
+	entry := log.WithFields(log.Fields{"data": metadata})
+	referrerWindow := conf.GetConfInt64(conf.AttributionReferrerWindowSecondsConfKey)
+	fingerprintWindow := conf.GetConfInt64(conf.AttributionFingerprintWindowSecondsConfKey)
+	campaignId, matchedBy, err := store.matchCampaignClick(metadata, referrerWindow, fingerprintWindow)
+	if err != nil {
+		entry.Warn("matchCampaignClick error: ", err.Error())
+		return
+	}
+	if campaignId == "" {
+		return
+	}
+	entry.WithFields(log.Fields{"campaign": campaignId, "matchedBy": matchedBy}).Debug("New user attributed")
+	if err = store.setUserCampaign(metadata, campaignId, matchedBy); err != nil {
+		entry.Warn("setUserCampaign error: ", err.Error())
+	}
+	store.AddSlotCounter(metadata.AppId, attribution.CampaignNewUserSlotCounter, campaignId, metadata.DateTimestamp, 1.0)
+}
+
+// updateCampaignActivity counts the daily active user and the retention of attributed users
+func updateCampaignActivity(store Store, metadata *middlewares.MetaData, days []int) {
+	campaignId, createdAt, err := store.getUserCampaign(metadata.AppId, metadata.DeviceId)
+	if err != nil {
+		log.WithFields(log.Fields{"data": metadata, "error": err.Error()}).Warn("getUserCampaign error")
+		return
+	}
+	if campaignId == "" {
+		return
+	}
+	store.AddSlotCounter(metadata.AppId, attribution.CampaignDailyActiveSlotCounter, campaignId, metadata.DateTimestamp, 1.0)
+
+	createdDateTimestamp := utils.TimestampToDate(createdAt).Unix()
+	delta := int((metadata.DateTimestamp - createdDateTimestamp) / (24 * 3600))
+	for _, day := range days {
+		if delta == day {
+			store.AddSlotCounter(metadata.AppId, attribution.CampaignNewUserRetentionSlotCounterPrefix+campaignId,
+				fmt.Sprintf("%d", day), createdDateTimestamp, 1.0)
+			break
+		}
+	}
+}
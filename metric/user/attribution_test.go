@@ -0,0 +1,60 @@
+package user
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/metric/attribution"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestMongodbStore_matchCampaignClick(t *testing.T) {
+	store := NewMongoStore(mongodb.DefaultClient, "test_").(*mongodbStore)
+	defer store.dropData(appId)
+
+	now := utils.NowTimestamp()
+	clicks := store.database(appId).Collection(attribution.ClickCollectionName)
+	_, err := clicks.InsertMany(context.Background(), []interface{}{
+		bson.M{"_id": "k1", "campaignId": "c1", "fingerprint": attribution.Fingerprint("1.2.3.4", "android"), "timestamp": now - 3600},
+		bson.M{"_id": "k2", "campaignId": "c2", "fingerprint": attribution.Fingerprint("1.2.3.4", "android"), "timestamp": now - 60},
+		bson.M{"_id": "k3", "campaignId": "c3", "fingerprint": attribution.Fingerprint("5.6.7.8", "ios"), "timestamp": now - 3*24*3600},
+	})
+	require.NoError(t, err)
+
+	data := func(deviceId, ip, platform, referrer string) *middlewares.MetaData {
+		return &middlewares.MetaData{AppId: appId, DeviceId: deviceId, ClientIP: ip, Platform: platform,
+			Referrer: referrer, Timestamp: now, ServerTimestamp: now}
+	}
+
+	// referrer
+	campaign, matchedBy, err := store.matchCampaignClick(data("a", "9.9.9.9", "android", "k1"), 7*24*3600, 24*3600)
+	require.NoError(t, err)
+	require.Equal(t, "c1", campaign)
+	require.Equal(t, attribution.MatchedByReferrer, matchedBy)
+
+	// a click is matched once, the latest click with the fingerprint is matched
+	campaign, matchedBy, err = store.matchCampaignClick(data("b", "1.2.3.4", "android", "k1"), 7*24*3600, 24*3600)
+	require.NoError(t, err)
+	require.Equal(t, "c2", campaign)
+	require.Equal(t, attribution.MatchedByFingerprint, matchedBy)
+
+	campaign, _, err = store.matchCampaignClick(data("c", "1.2.3.4", "android", ""), 7*24*3600, 24*3600)
+	require.NoError(t, err)
+	require.Equal(t, "", campaign)
+
+	// outside the fingerprint window
+	campaign, _, err = store.matchCampaignClick(data("d", "5.6.7.8", "ios", ""), 7*24*3600, 24*3600)
+	require.NoError(t, err)
+	require.Equal(t, "", campaign)
+
+	metadata := data("a", "9.9.9.9", "android", "")
+	require.True(t, store.updateUserRecord(metadata))
+	require.NoError(t, store.setUserCampaign(metadata, "c1", attribution.MatchedByReferrer))
+	campaign, createdAt, err := store.getUserCampaign(appId, "a")
+	require.NoError(t, err)
+	require.Equal(t, "c1", campaign)
+	require.Equal(t, now, createdAt)
+}
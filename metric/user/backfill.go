@@ -0,0 +1,157 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/lt90s/goanalytics/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// retentionCounts accumulates retention counts, counter name -> date -> slot -> count
+type retentionCounts map[string]map[int64]map[string]float64
+
+func (rc retentionCounts) add(counterName string, date int64, day int) {
+	dates, ok := rc[counterName]
+	if !ok {
+		dates = make(map[int64]map[string]float64)
+		rc[counterName] = dates
+	}
+	slots, ok := dates[date]
+	if !ok {
+		slots = make(map[string]float64)
+		dates[date] = slots
+	}
+	slots[fmt.Sprintf("%d", day)]++
+}
+
+type backfillUser struct {
+	createdDate int64
+	channel     string
+}
+
+// countRetention counts the new user and active user retention of one device active on the
+// dates for the given days
+func countRetention(counts retentionCounts, user backfillUser, dates map[int64]bool, days []int) {
+	for _, day := range days {
+		delta := int64(day * 24 * 3600)
+		if user.createdDate != 0 && dates[user.createdDate+delta] {
+			counts.add(NewUserRetentionSlotCounter, user.createdDate, day)
+			counts.add(ChannelNewUserRetentionSlotCounterPrefix+user.channel, user.createdDate, day)
+		}
+		for date := range dates {
+			if dates[date+delta] {
+				counts.add(ActiveUserRetentionSlotCounter, date, day)
+				counts.add(ChannelActiveUserRetentionSlotCounterPrefix+user.channel, date, day)
+			}
+		}
+	}
+}
+
+// devices whose user records are read at once by the backfill
+const backfillBatchSize = 1000
+
+// backfillUsers returns the user records of the devices
+func (ms *mongodbStore) backfillUsers(appId string, deviceIds []string) (map[string]backfillUser, error) {
+	ctx := context.Background()
+	option := options.Find().SetProjection(bson.M{"deviceId": 1, "createdAt": 1, "channel": 1})
+	filter := bson.M{"deviceId": bson.M{"$in": deviceIds}}
+	cursor, err := ms.database(appId).Collection(userCollectionName).Find(ctx, filter, option)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var user struct {
+		DeviceId  string `bson:"deviceId"`
+		CreatedAt int64  `bson:"createdAt"`
+		Channel   string `bson:"channel"`
+	}
+	users := make(map[string]backfillUser, len(deviceIds))
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		users[user.DeviceId] = backfillUser{
+			createdDate: utils.TimestampToDate(user.CreatedAt).Unix(),
+			channel:     user.Channel,
+		}
+	}
+	return users, cursor.Err()
+}
+
+// backfillRetention computes the new user and active user retention of the days from the
+// history in deviceActiveCollection. Counters of the days are set rather than incremented so
+// that a backfill can be run again. Channel counters use the latest channel of the device.
+//
+// Active dates are streamed by device and the user records of the devices are read in batches,
+// so that only a batch of devices is held in memory.
+func (ms *mongodbStore) backfillRetention(appId string, days []int) error {
+	if len(days) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	counts := make(retentionCounts)
+	batch := make(map[string]map[int64]bool)
+	flush := func() error {
+		deviceIds := make([]string, 0, len(batch))
+		for deviceId := range batch {
+			deviceIds = append(deviceIds, deviceId)
+		}
+		users, err := ms.backfillUsers(appId, deviceIds)
+		if err != nil {
+			return err
+		}
+		for deviceId, dates := range batch {
+			countRetention(counts, users[deviceId], dates, days)
+		}
+		batch = make(map[string]map[int64]bool)
+		return nil
+	}
+
+	option := options.Find().SetProjection(bson.M{"deviceId": 1, "timestamp": 1}).SetSort(bson.M{"deviceId": 1})
+	cursor, err := ms.database(appId).Collection(deviceActiveCollectionName).Find(ctx, bson.M{}, option)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	var active struct {
+		DeviceId  string `bson:"deviceId"`
+		Timestamp int64  `bson:"timestamp"`
+	}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&active); err != nil {
+			return err
+		}
+		dates, ok := batch[active.DeviceId]
+		if !ok {
+			// the batch is full once the dates of its devices are complete
+			if len(batch) >= backfillBatchSize {
+				if err = flush(); err != nil {
+					return err
+				}
+			}
+			dates = make(map[int64]bool)
+			batch[active.DeviceId] = dates
+		}
+		dates[active.Timestamp] = true
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		if err = flush(); err != nil {
+			return err
+		}
+	}
+
+	for counterName, dates := range counts {
+		for date, slots := range dates {
+			for slot, count := range slots {
+				if err = ms.SetSlotCounter(appId, counterName, slot, date, count); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package user
+
+import (
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfiguredRetentionDays(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, prefix)
+	defer store.dropData(appId)
+
+	handler := openAppEventHandler(store, mockAppConfigGetter{retentionDays: []int{60}})
+	installDate := utils.TodayDiff(60).Unix()
+	for _, date := range []int64{installDate, utils.TodayDiff(0).Unix()} {
+		require.NoError(t, handler.Handle(&middlewares.MetaData{
+			AppId:         appId,
+			DeviceId:      "a",
+			Channel:       "x",
+			Timestamp:     date + 3600,
+			DateTimestamp: date,
+		}))
+	}
+
+	for _, counterName := range []string{NewUserRetentionSlotCounter, ActiveUserRetentionSlotCounter} {
+		counters, err := store.GetSlotCounterSpan(appId, counterName, installDate, installDate)
+		require.NoError(t, err)
+		require.Equal(t, map[string]float64{"60": 1}, counters[installDate])
+	}
+}
+
+func TestBackfillRetention(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, prefix)
+	defer store.dropData(appId)
+
+	// retention is not counted while day 2 is not a retention day
+	handler := openAppEventHandler(store, mockAppConfigGetter{retentionDays: []int{1}})
+	installDate := utils.TodayDiff(4).Unix()
+	opens := map[string][]int{"a": {4, 2, 0}, "b": {4, 3}}
+	for deviceId, days := range opens {
+		for _, day := range days {
+			date := utils.TodayDiff(day).Unix()
+			require.NoError(t, handler.Handle(&middlewares.MetaData{
+				AppId:         appId,
+				DeviceId:      deviceId,
+				Channel:       deviceId,
+				Timestamp:     date + 3600,
+				DateTimestamp: date,
+			}))
+		}
+	}
+	counters, err := store.GetSlotCounterSpan(appId, NewUserRetentionSlotCounter, installDate, installDate)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"1": 1}, counters[installDate])
+
+	// backfill runs twice with the same result
+	for i := 0; i < 2; i++ {
+		require.NoError(t, store.backfillRetention(appId, []int{2}))
+	}
+
+	counters, err = store.GetSlotCounterSpan(appId, NewUserRetentionSlotCounter, installDate, installDate)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"1": 1, "2": 1}, counters[installDate])
+	counters, err = store.GetSlotCounterSpan(appId, ChannelNewUserRetentionSlotCounterPrefix+"a", installDate, installDate)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"2": 1}, counters[installDate])
+
+	// device a is active 2 days after its install and 2 days after that
+	start, end := utils.TodayDiff(4).Unix(), utils.TodayDiff(0).Unix()
+	counters, err = store.GetSlotCounterSpan(appId, ActiveUserRetentionSlotCounter, start, end)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), counters[installDate]["2"])
+	require.Equal(t, float64(1), counters[utils.TodayDiff(2).Unix()]["2"])
+}
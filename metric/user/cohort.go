@@ -0,0 +1,179 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/utils"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strconv"
+)
+
+// cohort describes a kind of user identity other than the device, e.g. the canonical user of
+// the identity graph. New users, daily active users and new user retention are counted for
+// every cohort the same way they are counted for devices.
+type cohort struct {
+	// prefix of the cohort's collections
+	name string
+	// optional, not counted when empty
+	newUserCounter         string
+	dailyActiveCounter     string
+	retentionCounter       string
+	channelRetentionPrefix string
+	// optional, not counted when empty
+	freshnessCounter string
+}
+
+func (ms *mongodbStore) cohortUserCollection(appId string, c cohort) *mongo.Collection {
+	return ms.database(appId).Collection(c.name + "Collection")
+}
+
+func (ms *mongodbStore) cohortActiveCollection(appId string, c cohort) *mongo.Collection {
+	return ms.database(appId).Collection(c.name + "ActiveCollection")
+}
+
+// cohortFirstSeen records the user and reports whether it is seen for the first time
+func (ms *mongodbStore) cohortFirstSeen(c cohort, id string, data *middlewares.MetaData) bool {
+	filter := bson.M{
+		"id": id,
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"channel":   data.Channel,
+			"createdAt": data.Timestamp,
+		},
+	}
+	upsert := true
+	option := &options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	result, err := ms.cohortUserCollection(data.AppId, c).UpdateOne(context.Background(), filter, update, option)
+	if err != nil {
+		log.WithFields(log.Fields{"cohort": c.name, "error": err.Error()}).Warn("cohortFirstSeen error")
+		return false
+	}
+	return result.UpsertedCount > 0
+}
+
+func (ms *mongodbStore) cohortFirstActiveToday(c cohort, id string, data *middlewares.MetaData) bool {
+	filter := bson.M{
+		"id":        id,
+		"timestamp": data.DateTimestamp,
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"f": 1,
+		},
+	}
+	upsert := true
+	option := &options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	result, err := ms.cohortActiveCollection(data.AppId, c).UpdateOne(context.Background(), filter, update, option)
+	if err != nil {
+		return false
+	}
+	return result.UpsertedCount > 0
+}
+
+// mergeCohortUser moves the user fromId into the user toId, keeping the earliest creation and its
+// channel, so that the merged user is neither counted as new again nor restarts its retention
+func (ms *mongodbStore) mergeCohortUser(appId string, c cohort, fromId, toId string) error {
+	ctx := context.Background()
+	collection := ms.cohortUserCollection(appId, c)
+	var from, to struct {
+		Channel   string `bson:"channel"`
+		CreatedAt int64  `bson:"createdAt"`
+	}
+	err := collection.FindOne(ctx, bson.M{"id": fromId}).Decode(&from)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = collection.FindOne(ctx, bson.M{"id": toId}).Decode(&to)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if err == mongo.ErrNoDocuments || from.CreatedAt < to.CreatedAt {
+		update := bson.M{"$set": bson.M{"channel": from.Channel, "createdAt": from.CreatedAt}}
+		_, err = collection.UpdateOne(ctx, bson.M{"id": toId}, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+	if _, err = collection.DeleteOne(ctx, bson.M{"id": fromId}); err != nil {
+		return err
+	}
+	// a date active for both users keeps two records, cohortFirstActiveToday matches either
+	filter := bson.M{"id": fromId}
+	_, err = ms.cohortActiveCollection(appId, c).UpdateMany(ctx, filter, bson.M{"$set": bson.M{"id": toId}})
+	return err
+}
+
+func (ms *mongodbStore) getCohortUserCreatedTimestamp(appId string, c cohort, id string) (int64, error) {
+	option := &options.FindOneOptions{
+		Projection: bson.M{"createdAt": 1},
+	}
+	result := ms.cohortUserCollection(appId, c).FindOne(context.Background(), bson.M{"id": id}, option)
+	var ob struct {
+		CreatedAt int64 `bson:"createdAt"`
+	}
+	if err := result.Decode(&ob); err != nil {
+		return 0, err
+	}
+	return ob.CreatedAt, nil
+}
+
+// updateCohortFreshness counts the active user by days since creation, like DailyActiveUserFreshnessSlotCounter
+func (ms *mongodbStore) updateCohortFreshness(c cohort, id string, data *middlewares.MetaData) {
+	createdAt, err := ms.getCohortUserCreatedTimestamp(data.AppId, c, id)
+	if err != nil {
+		log.WithFields(log.Fields{"cohort": c.name, "error": err.Error()}).Warn("[updateCohortFreshness] get user created time error")
+		return
+	}
+	delta := int((data.DateTimestamp - utils.TimestampToDate(createdAt).Unix()) / (24 * 3600))
+	if delta > 30 {
+		delta = 31
+	}
+	ms.AddSlotCounter(data.AppId, c.freshnessCounter, strconv.Itoa(delta), data.DateTimestamp, 1.0)
+}
+
+// updateCohortRetention credits the retention of the user's creation date when the user is
+// active on one of the retention days
+func (ms *mongodbStore) updateCohortRetention(c cohort, id string, data *middlewares.MetaData, days []int) {
+	createdAt, err := ms.getCohortUserCreatedTimestamp(data.AppId, c, id)
+	if err != nil {
+		log.WithFields(log.Fields{"cohort": c.name, "error": err.Error()}).Warn("[updateCohortRetention] get user created time error")
+		return
+	}
+	createdDateTimestamp := utils.TimestampToDate(createdAt).Unix()
+	delta := int((data.DateTimestamp - createdDateTimestamp) / (24 * 3600))
+	for _, day := range days {
+		if delta == day {
+			slot := fmt.Sprintf("%d", day)
+			ms.AddSlotCounter(data.AppId, c.retentionCounter, slot, createdDateTimestamp, 1.0)
+			ms.AddSlotCounter(data.AppId, c.channelRetentionPrefix+data.Channel, slot, createdDateTimestamp, 1.0)
+			break
+		}
+	}
+}
+
+// updateCohort counts the new user, daily active user and retention counters of the cohort
+func updateCohort(store Store, c cohort, id string, metadata *middlewares.MetaData, days []int) {
+	if store.cohortFirstSeen(c, id, metadata) && c.newUserCounter != "" {
+		store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform,
+			metadata.Version, c.newUserCounter, metadata.DateTimestamp, 1.0)
+	}
+	if store.cohortFirstActiveToday(c, id, metadata) {
+		store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform,
+			metadata.Version, c.dailyActiveCounter, metadata.DateTimestamp, 1.0)
+		store.updateCohortRetention(c, id, metadata, days)
+		if c.freshnessCounter != "" {
+			store.updateCohortFreshness(c, id, metadata)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package user
+
+import (
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRegisteredUserCohort(t *testing.T) {
+	store := NewMongoStore(mongodb.DefaultClient, "test_").(*mongodbStore)
+	defer store.dropData(appId)
+
+	handler := openAppEventHandler(store, mockAppConfigGetter{})
+	installDate := utils.TodayDiff(1).Unix()
+	data := &middlewares.MetaData{
+		AppId:         appId,
+		DeviceId:      "a",
+		Channel:       "c",
+		Platform:      "android",
+		Version:       "1.0.0",
+		Timestamp:     installDate + 3600,
+		DateTimestamp: installDate,
+	}
+	// anonymous install, registration the next day
+	require.NoError(t, handler.Handle(data))
+	data.UserId = "u1"
+	data.Timestamp = utils.NowTimestamp()
+	data.DateTimestamp = utils.TodayTimestamp()
+	require.NoError(t, handler.Handle(data))
+	require.NoError(t, handler.Handle(data))
+
+	today := utils.TodayTimestamp()
+	active, err := store.GetSimpleCPVSumTotal(appId, RegisteredDailyActiveCPVCounter, today, today)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, active)
+
+	registered, err := store.GetSimpleCPVSumTotal(appId, NewRegisteredUserCPVCounter, today, today)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, registered)
+
+	conversions, err := getRegistrationConversions(store, appId, installDate, installDate)
+	require.NoError(t, err)
+	require.Len(t, conversions, 1)
+	require.Equal(t, RegistrationConversion{Channel: "c", NewUsers: 1, RegisteredDevices: 1, Rate: 1}, conversions[0])
+
+	days, err := store.GetSlotCounterSpan(appId, RegistrationDaysSlotCounter, installDate, installDate)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, days[installDate]["1"])
+}
@@ -0,0 +1,42 @@
+package user
+
+import "sort"
+
+func getRegistrationConversions(store Store, appId string, start, end int64) ([]RegistrationConversion, error) {
+	newUsers, err := sumCPVByChannel(store, appId, NewUserCPVCounter, start, end)
+	if err != nil {
+		return nil, err
+	}
+	registered, err := sumCPVByChannel(store, appId, RegisteredDeviceCPVCounter, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	conversions := make([]RegistrationConversion, 0, len(newUsers))
+	for channel, count := range newUsers {
+		conversions = append(conversions, RegistrationConversion{
+			Channel:           channel,
+			NewUsers:          count,
+			RegisteredDevices: registered[channel],
+			Rate:              calculatePercent(registered[channel], count),
+		})
+	}
+	sort.Slice(conversions, func(i, j int) bool {
+		return conversions[i].Channel < conversions[j].Channel
+	})
+	return conversions, nil
+}
+
+func sumCPVByChannel(store Store, appId, counterName string, start, end int64) (map[string]float64, error) {
+	dateCPV, err := store.GetSimpleCPVDateCPV(appId, counterName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]float64)
+	for _, channels := range dateCPV["channel"] {
+		for channel, count := range channels {
+			sums[channel] += count
+		}
+	}
+	return sums, nil
+}
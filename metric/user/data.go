@@ -1,7 +1,11 @@
 package user
 
+import "github.com/lt90s/goanalytics/api/middlewares"
+
 const (
-	EventUserOpenApp = "EventUserOpenApp"
+	EventUserOpenApp  = "EventUserOpenApp"
+	EventUserIdentify = "EventUserIdentify"
+	EventUserAlias    = "EventUserAlias"
 )
 
 const (
@@ -13,6 +17,14 @@ const (
 	NewUserCPVCounter                   = "NewUserCPVCounter"
 	DailyActiveCPVCounter               = "DailyActiveCPVCounter"
 
+	// computed by the daily schedule, see calcUserLifecycle
+	ChurnedUserCPVCounter   = "ChurnedUserCPVCounter"
+	ReturningUserCPVCounter = "ReturningUserCPVCounter"
+	DormantUserCPVCounter   = "DormantUserCPVCounter"
+	// unique active users of the 7 and 30 days ending on the date, see calcActiveUserWindows
+	WeeklyActiveCPVCounter  = "WeeklyActiveCPVCounter"
+	MonthlyActiveCPVCounter = "MonthlyActiveCPVCounter"
+
 	NewUserRetentionSlotCounter              = "NewUserRetentionSlotCounter"
 	ChannelNewUserRetentionSlotCounterPrefix = "channelNewUserRetentionSlotCounter_"
 
@@ -23,13 +35,81 @@ const (
 	DailyActiveNewUserPercentSimpleCounter = "DailyActiveNewUserPercentSimpleCounter"
 	DailyActiveUserAffinitySlotCounter     = "DailyActiveUserAffinitySlotCounter"
 	DailyActiveUserFreshnessSlotCounter    = "DailyActiveUserFreshnessSlotCounter"
+
+	// daily active users over monthly active users
+	DailyActiveStickinessSimpleCounter = "DailyActiveStickinessSimpleCounter"
+
+	// user based variants, counted on the canonical user of the identity graph
+	CanonicalNewUserCPVCounter                        = "CanonicalNewUserCPVCounter"
+	CanonicalDailyActiveCPVCounter                    = "CanonicalDailyActiveCPVCounter"
+	CanonicalNewUserRetentionSlotCounter              = "CanonicalNewUserRetentionSlotCounter"
+	ChannelCanonicalNewUserRetentionSlotCounterPrefix = "channelCanonicalNewUserRetentionSlotCounter_"
+
+	// registered user variants, counted on the user id and the registration date
+	RegisteredDailyActiveCPVCounter                 = "RegisteredDailyActiveCPVCounter"
+	RegisteredUserRetentionSlotCounter              = "RegisteredUserRetentionSlotCounter"
+	ChannelRegisteredUserRetentionSlotCounterPrefix = "channelRegisteredUserRetentionSlotCounter_"
+	RegisteredUserFreshnessSlotCounter              = "RegisteredUserFreshnessSlotCounter"
+	// devices that registered, credited on the install date of the device, slotted by days
+	// from install to registration
+	RegisteredDeviceCPVCounter  = "RegisteredDeviceCPVCounter"
+	RegistrationDaysSlotCounter = "RegistrationDaysSlotCounter"
+
+	// slotted by country ISO code, region ISO code and "region/city", see geoip.Location
+	CountryActiveUserSlotCounter = "CountryActiveUserSlotCounter"
+	RegionActiveUserSlotCounter  = "RegionActiveUserSlotCounter"
+	CityActiveUserSlotCounter    = "CityActiveUserSlotCounter"
+	CountryNewUserSlotCounter    = "CountryNewUserSlotCounter"
+
+	// slotted by device attribute, os version slots are "platform/osVersion"
+	OSVersionActiveUserSlotCounter    = "OSVersionActiveUserSlotCounter"
+	DeviceModelActiveUserSlotCounter  = "DeviceModelActiveUserSlotCounter"
+	ManufacturerActiveUserSlotCounter = "ManufacturerActiveUserSlotCounter"
+	LocaleActiveUserSlotCounter       = "LocaleActiveUserSlotCounter"
+	NetworkTypeActiveUserSlotCounter  = "NetworkTypeActiveUserSlotCounter"
+	CarrierActiveUserSlotCounter      = "CarrierActiveUserSlotCounter"
+	ResolutionActiveUserSlotCounter   = "ResolutionActiveUserSlotCounter"
 )
 
 const (
-	DailyScheduleEvent = "UserDailyScheduleEvent"
+	DailyScheduleEvent    = "UserDailyScheduleEvent"
+	LateDataScheduleEvent = "UserLateDataScheduleEvent"
+)
+
+var (
+	canonicalUserCohort = cohort{
+		name:                   "canonicalUser",
+		newUserCounter:         CanonicalNewUserCPVCounter,
+		dailyActiveCounter:     CanonicalDailyActiveCPVCounter,
+		retentionCounter:       CanonicalNewUserRetentionSlotCounter,
+		channelRetentionPrefix: ChannelCanonicalNewUserRetentionSlotCounterPrefix,
+	}
+	// new registered users are counted by NewRegisteredUserCPVCounter
+	registeredUserCohort = cohort{
+		name:                   "registeredUser",
+		dailyActiveCounter:     RegisteredDailyActiveCPVCounter,
+		retentionCounter:       RegisteredUserRetentionSlotCounter,
+		channelRetentionPrefix: ChannelRegisteredUserRetentionSlotCounterPrefix,
+		freshnessCounter:       RegisteredUserFreshnessSlotCounter,
+	}
 )
 
 var (
-	retentionDays                 = [...]int{1, 2, 3, 4, 5, 6, 7, 15, 30}
 	OpenAppCountDistributionSlots = []string{"1-2", "3-4", "5-6", "7-8", "9-10", "11-20", "21-30", "31-49", "50+"}
 )
+
+type aliasRequestData struct {
+	PreviousId string `json:"previousId"`
+}
+
+type aliasData struct {
+	MetaData   *middlewares.MetaData `json:"metadata"`
+	PreviousId string                `json:"previousId"`
+}
+
+type RegistrationConversion struct {
+	Channel           string  `json:"channel"`
+	NewUsers          float64 `json:"newUsers"`
+	RegisteredDevices float64 `json:"registeredDevices"`
+	Rate              float64 `json:"rate"`
+}
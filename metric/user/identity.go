@@ -0,0 +1,112 @@
+package user
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The identity graph maps device ids and user ids to a canonical user. Every node is a
+// document keyed by "device:<deviceId>" or "user:<userId>" holding the canonical id.
+//
+// An anonymous device is its own canonical user. The first user identified on an anonymous
+// device adopts the device's canonical user, so that the activity before registration
+// belongs to the user. A device already identified keeps its canonical user when another
+// account logs in on it, events reporting a user id are resolved by the user id.
+
+const (
+	identityCollectionName = "identityCollection"
+
+	deviceIdentityPrefix = "device:"
+	userIdentityPrefix   = "user:"
+)
+
+type identityNode struct {
+	Key         string `bson:"_id"`
+	CanonicalId string `bson:"canonicalId"`
+	Identified  bool   `bson:"identified"`
+}
+
+func (ms *mongodbStore) identityCollection(appId string) *mongo.Collection {
+	return ms.database(appId).Collection(identityCollectionName)
+}
+
+// getOrCreateIdentity returns the node, creating it with the canonical id when absent
+func (ms *mongodbStore) getOrCreateIdentity(appId, key, canonicalId string) (node identityNode, err error) {
+	filter := bson.M{"_id": key}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"canonicalId": canonicalId,
+			"identified":  false,
+		},
+	}
+	option := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	result := ms.identityCollection(appId).FindOneAndUpdate(context.Background(), filter, update, option)
+	err = result.Decode(&node)
+	return
+}
+
+func (ms *mongodbStore) resolveDevice(appId, deviceId string) (string, error) {
+	key := deviceIdentityPrefix + deviceId
+	node, err := ms.getOrCreateIdentity(appId, key, key)
+	return node.CanonicalId, err
+}
+
+// identify links the device to the user and returns the canonical user
+func (ms *mongodbStore) identify(appId, deviceId, userId string) (string, error) {
+	deviceKey := deviceIdentityPrefix + deviceId
+	device, err := ms.getOrCreateIdentity(appId, deviceKey, deviceKey)
+	if err != nil {
+		return "", err
+	}
+
+	canonicalId := userIdentityPrefix + userId
+	if !device.Identified {
+		canonicalId = device.CanonicalId
+	}
+	user, err := ms.getOrCreateIdentity(appId, userIdentityPrefix+userId, canonicalId)
+	if err != nil {
+		return "", err
+	}
+
+	if !device.Identified {
+		filter := bson.M{"_id": deviceKey, "identified": false}
+		update := bson.M{"$set": bson.M{"canonicalId": user.CanonicalId, "identified": true}}
+		_, err = ms.identityCollection(appId).UpdateOne(context.Background(), filter, update)
+	}
+	return user.CanonicalId, err
+}
+
+// alias merges the canonical user of previousId into the canonical user of userId, all the
+// devices and user ids of previousId then resolve to the canonical user of userId, which
+// takes over the cohort record of the merged user
+func (ms *mongodbStore) alias(appId, previousId, userId string) error {
+	userKey := userIdentityPrefix + userId
+	user, err := ms.getOrCreateIdentity(appId, userKey, userKey)
+	if err != nil {
+		return err
+	}
+	previousKey := userIdentityPrefix + previousId
+	previous, err := ms.getOrCreateIdentity(appId, previousKey, user.CanonicalId)
+	if err != nil || previous.CanonicalId == user.CanonicalId {
+		return err
+	}
+
+	filter := bson.M{"canonicalId": previous.CanonicalId}
+	update := bson.M{"$set": bson.M{"canonicalId": user.CanonicalId}}
+	_, err = ms.identityCollection(appId).UpdateMany(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	return ms.mergeCohortUser(appId, canonicalUserCohort, previous.CanonicalId, user.CanonicalId)
+}
+
+// canonicalUserOf returns the canonical user of the event
+func canonicalUserOf(store Store, data *middlewares.MetaData) (string, error) {
+	if data.UserId != "" {
+		return store.identify(data.AppId, data.DeviceId, data.UserId)
+	}
+	return store.resolveDevice(data.AppId, data.DeviceId)
+}
@@ -0,0 +1,114 @@
+package user
+
+import (
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMongodbStore_Identity(t *testing.T) {
+	store := NewMongoStore(mongodb.DefaultClient, "test_").(*mongodbStore)
+	defer store.dropData(appId)
+
+	// anonymous device
+	a, err := store.resolveDevice(appId, "a")
+	require.NoError(t, err)
+
+	// first user on the device adopts the device's canonical user
+	u1, err := store.identify(appId, "a", "u1")
+	require.NoError(t, err)
+	require.Equal(t, a, u1)
+
+	// the same user on another device
+	u1b, err := store.identify(appId, "b", "u1")
+	require.NoError(t, err)
+	require.Equal(t, u1, u1b)
+	b, err := store.resolveDevice(appId, "b")
+	require.NoError(t, err)
+	require.Equal(t, u1, b)
+
+	// another user on a shared device
+	u2, err := store.identify(appId, "a", "u2")
+	require.NoError(t, err)
+	require.NotEqual(t, u1, u2)
+	a, err = store.resolveDevice(appId, "a")
+	require.NoError(t, err)
+	require.Equal(t, u1, a)
+
+	// merge u2 into u3
+	u3, err := store.identify(appId, "c", "u3")
+	require.NoError(t, err)
+	require.NoError(t, store.alias(appId, "u2", "u3"))
+	u2, err = store.identify(appId, "a", "u2")
+	require.NoError(t, err)
+	require.Equal(t, u3, u2)
+}
+
+func TestCanonicalUserCohort(t *testing.T) {
+	store := NewMongoStore(mongodb.DefaultClient, "test_").(*mongodbStore)
+	defer store.dropData(appId)
+
+	handler := openAppEventHandler(store, mockAppConfigGetter{})
+	data := &middlewares.MetaData{
+		AppId:         appId,
+		Channel:       "c",
+		Platform:      "android",
+		Version:       "1.0.0",
+		UserId:        "u1",
+		Timestamp:     utils.NowTimestamp(),
+		DateTimestamp: utils.TodayTimestamp(),
+	}
+	for _, deviceId := range []string{"a", "b"} {
+		data.DeviceId = deviceId
+		require.NoError(t, handler.Handle(data))
+	}
+
+	today := utils.TodayTimestamp()
+	devices, err := store.GetSimpleCPVSumTotal(appId, NewUserCPVCounter, today, today)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, devices)
+	users, err := store.GetSimpleCPVSumTotal(appId, CanonicalNewUserCPVCounter, today, today)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, users)
+	active, err := store.GetSimpleCPVSumTotal(appId, CanonicalDailyActiveCPVCounter, today, today)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, active)
+}
+
+func TestAliasMergesCanonicalUserCohort(t *testing.T) {
+	store := NewMongoStore(mongodb.DefaultClient, "test_").(*mongodbStore)
+	defer store.dropData(appId)
+
+	handler := openAppEventHandler(store, mockAppConfigGetter{})
+	yesterday := utils.TodayDiff(1).Unix()
+	today := utils.TodayTimestamp()
+	open := func(deviceId, userId string, timestamp int64) {
+		require.NoError(t, handler.Handle(&middlewares.MetaData{
+			AppId:         appId,
+			DeviceId:      deviceId,
+			Channel:       "c",
+			Platform:      "android",
+			Version:       "1.0.0",
+			UserId:        userId,
+			Timestamp:     timestamp,
+			DateTimestamp: utils.TimestampToDate(timestamp).Unix(),
+		}))
+	}
+
+	// a guest yesterday, who registers today
+	open("a", "guest", yesterday)
+	require.NoError(t, store.alias(appId, "guest", "u1"))
+	canonicalId, err := store.identify(appId, "a", "u1")
+	require.NoError(t, err)
+	createdAt, err := store.getCohortUserCreatedTimestamp(appId, canonicalUserCohort, canonicalId)
+	require.NoError(t, err)
+	require.Equal(t, yesterday, createdAt)
+
+	// the merged user is not new again
+	open("a", "u1", utils.NowTimestamp())
+	users, err := store.GetSimpleCPVSumTotal(appId, CanonicalNewUserCPVCounter, yesterday, today)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, users)
+}
@@ -0,0 +1,201 @@
+package user
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type cpv struct {
+	channel  string
+	platform string
+	version  string
+}
+
+// setCPVCounts sets the cpv counters of the date, counter name -> cpv -> count
+func (ms *mongodbStore) setCPVCounts(appId string, timestamp int64, counts map[string]map[cpv]float64) error {
+	for counterName, cpvCounts := range counts {
+		for key, count := range cpvCounts {
+			err := ms.SetSimpleCPVCounter(appId, key.channel, key.platform, key.version, counterName, timestamp, count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// countActiveCPV groups the devices of deviceActiveCollection active between start and end by
+// their latest channel, platform and version. deviceStages are run on the devices, whose _id is
+// the device id, after accumulators have been grouped by device, and conditions maps the names
+// of the returned counts to the condition a device is counted on. Devices are only counted when
+// their user record was created before the end of the date.
+func (ms *mongodbStore) countActiveCPV(appId string, start, end int64, accumulators bson.M, deviceStages []bson.M,
+	conditions bson.M) (map[string]map[cpv]float64, error) {
+	const day = 24 * 3600
+	deviceGroup := bson.M{"_id": "$deviceId"}
+	for name, accumulator := range accumulators {
+		deviceGroup[name] = accumulator
+	}
+	cpvGroup := bson.M{
+		"_id": bson.M{"channel": "$user.channel", "platform": "$user.platform", "version": "$user.version"},
+	}
+	for name, condition := range conditions {
+		cpvGroup[name] = bson.M{"$sum": bson.M{"$cond": bson.A{condition, 1.0, 0.0}}}
+	}
+
+	pipeline := []bson.M{
+		{"$match": dateRange(start, end)},
+		{"$group": deviceGroup},
+	}
+	pipeline = append(pipeline, deviceStages...)
+	pipeline = append(pipeline,
+		bson.M{
+			"$lookup": bson.M{
+				"from":         userCollectionName,
+				"localField":   "_id",
+				"foreignField": "deviceId",
+				"as":           "user",
+			},
+		},
+		bson.M{"$unwind": "$user"},
+		bson.M{"$match": bson.M{"user.createdAt": bson.M{"$lt": end + day}}},
+		bson.M{"$group": cpvGroup},
+	)
+
+	ctx := context.Background()
+	option := options.Aggregate().SetAllowDiskUse(true)
+	cursor, err := ms.database(appId).Collection(deviceActiveCollectionName).Aggregate(ctx, pipeline, option)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	return decodeCPVCounts(ctx, cursor)
+}
+
+// countUserCPV counts the users matching the filter by their latest channel, platform and version
+func (ms *mongodbStore) countUserCPV(appId string, filter bson.M, name string) (map[string]map[cpv]float64, error) {
+	pipeline := []bson.M{
+		{"$match": filter},
+		{
+			"$group": bson.M{
+				"_id": bson.M{"channel": "$channel", "platform": "$platform", "version": "$version"},
+				name:  bson.M{"$sum": 1.0},
+			},
+		},
+	}
+	ctx := context.Background()
+	cursor, err := ms.database(appId).Collection(userCollectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	return decodeCPVCounts(ctx, cursor)
+}
+
+// decodeCPVCounts reads the counts grouped by channel, platform and version,
+// name -> cpv -> count
+func decodeCPVCounts(ctx context.Context, cursor *mongo.Cursor) (map[string]map[cpv]float64, error) {
+	counts := make(map[string]map[cpv]float64)
+	for cursor.Next(ctx) {
+		var tmp struct {
+			Id struct {
+				Channel  string `bson:"channel"`
+				Platform string `bson:"platform"`
+				Version  string `bson:"version"`
+			} `bson:"_id"`
+			Counts map[string]float64 `bson:",inline"`
+		}
+		if err := cursor.Decode(&tmp); err != nil {
+			return nil, err
+		}
+		key := cpv{channel: tmp.Id.Channel, platform: tmp.Id.Platform, version: tmp.Id.Version}
+		for name, count := range tmp.Counts {
+			if counts[name] == nil {
+				counts[name] = make(map[cpv]float64)
+			}
+			if count > 0 {
+				counts[name][key] = count
+			}
+		}
+	}
+	return counts, cursor.Err()
+}
+
+func dateRange(start, end int64) bson.M {
+	return bson.M{"timestamp": bson.M{"$gte": start, "$lte": end}}
+}
+
+// calcUserLifecycle computes the churned, returning and dormant users of the date:
+//   - a churned user was last active inactiveDays before the date, and turns inactive for
+//     inactiveDays on the date
+//   - a returning user is active on the date after being inactive for at least inactiveDays
+//   - a dormant user was created before the date and has been inactive for at least
+//     inactiveDays on the date
+//
+// Counters are set rather than added so that the day can be computed again. Users are counted
+// on their latest channel, platform and version.
+func (ms *mongodbStore) calcUserLifecycle(appId string, timestamp int64, inactiveDays int) error {
+	const day = 24 * 3600
+	inactiveStart := timestamp - int64(inactiveDays)*day
+
+	accumulators := bson.M{
+		"lastActive": bson.M{"$max": bson.M{"$eq": bson.A{"$timestamp", inactiveStart}}},
+		"recent":     bson.M{"$max": bson.M{"$gt": bson.A{"$timestamp", inactiveStart}}},
+		"gap":        bson.M{"$max": bson.M{"$lt": bson.A{"$timestamp", timestamp}}},
+		"today":      bson.M{"$max": bson.M{"$eq": bson.A{"$timestamp", timestamp}}},
+	}
+	// devices active on the date after the gap are returning unless they are new users, look up
+	// a single activity before the gap
+	candidate := bson.M{"$and": bson.A{"$today", bson.M{"$not": bson.A{"$gap"}}}}
+	deviceStages := []bson.M{
+		{
+			"$lookup": bson.M{
+				"from": deviceActiveCollectionName,
+				"let":  bson.M{"deviceId": "$_id", "candidate": candidate},
+				"pipeline": bson.A{
+					bson.M{
+						"$match": bson.M{
+							"$expr": bson.M{
+								"$and": bson.A{
+									"$$candidate",
+									bson.M{"$eq": bson.A{"$deviceId", "$$deviceId"}},
+									bson.M{"$lt": bson.A{"$timestamp", inactiveStart}},
+								},
+							},
+						},
+					},
+					bson.M{"$limit": 1},
+				},
+				"as": "before",
+			},
+		},
+	}
+	conditions := bson.M{
+		ChurnedUserCPVCounter:   bson.M{"$and": bson.A{"$lastActive", bson.M{"$not": bson.A{"$recent"}}}},
+		ReturningUserCPVCounter: bson.M{"$and": bson.A{candidate, bson.M{"$gt": bson.A{bson.M{"$size": "$before"}, 0}}}},
+		"recent":                "$recent",
+	}
+	counts, err := ms.countActiveCPV(appId, inactiveStart, timestamp, accumulators, deviceStages, conditions)
+	if err != nil {
+		return err
+	}
+
+	// dormant users are the users created before the end of the date who are not recently active
+	created, err := ms.countUserCPV(appId, bson.M{"createdAt": bson.M{"$lt": timestamp + day}}, DormantUserCPVCounter)
+	if err != nil {
+		return err
+	}
+	dormant := make(map[cpv]float64)
+	for key, count := range created[DormantUserCPVCounter] {
+		dormant[key] = count
+	}
+	for key, count := range counts["recent"] {
+		dormant[key] -= count
+	}
+	counts[DormantUserCPVCounter] = dormant
+	delete(counts, "recent")
+
+	return ms.setCPVCounts(appId, timestamp, counts)
+}
@@ -0,0 +1,111 @@
+package user
+
+import (
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCalcUserLifecycle(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, prefix)
+	defer store.dropData(appId)
+
+	date := utils.TodayDiff(1).Unix()
+	// device -> active days before the date, the first one is the install
+	actives := map[string][]int{
+		// churned, last active 3 days before
+		"a": {10, 3},
+		// returning after 3 inactive days
+		"b": {10, 0},
+		// new user
+		"c": {0},
+		// dormant
+		"d": {10},
+		// active
+		"e": {10, 1},
+	}
+	for deviceId, days := range actives {
+		for i, day := range days {
+			data := &middlewares.MetaData{
+				AppId:         appId,
+				DeviceId:      deviceId,
+				Channel:       "channel",
+				Platform:      "android",
+				Version:       "1.0.0",
+				Timestamp:     date - int64(day*24*3600) + 3600,
+				DateTimestamp: date - int64(day*24*3600),
+			}
+			if i == 0 {
+				store.updateUserRecord(data)
+			}
+			store.deviceFirstOpenToday(data)
+		}
+	}
+
+	require.NoError(t, store.calcUserLifecycle(appId, date, 3))
+	// computing again gives the same counters
+	require.NoError(t, store.calcUserLifecycle(appId, date, 3))
+
+	expected := map[string]float64{
+		ChurnedUserCPVCounter:   1,
+		ReturningUserCPVCounter: 1,
+		DormantUserCPVCounter:   2,
+	}
+	for counterName, count := range expected {
+		total, err := store.GetSimpleCPVSumTotal(appId, counterName, date, date)
+		require.NoError(t, err)
+		require.Equal(t, count, total, counterName)
+	}
+}
+
+func TestCalcActiveUserWindows(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, prefix)
+	defer store.dropData(appId)
+
+	date := utils.TodayDiff(1).Unix()
+	// device -> active days before the date, the first one is the install
+	actives := map[string][]int{
+		"a": {40, 20, 3, 1, 0},
+		"b": {10},
+		"c": {5, 0},
+		"d": {40},
+	}
+	for deviceId, days := range actives {
+		for i, day := range days {
+			data := &middlewares.MetaData{
+				AppId:         appId,
+				DeviceId:      deviceId,
+				Channel:       deviceId,
+				Timestamp:     date - int64(day*24*3600) + 3600,
+				DateTimestamp: date - int64(day*24*3600),
+			}
+			if i == 0 {
+				store.updateUserRecord(data)
+			}
+			if store.deviceFirstOpenToday(data) {
+				store.AddSimpleCPVCounter(appId, data.Channel, data.Platform, data.Version, DailyActiveCPVCounter,
+					data.DateTimestamp, 1.0)
+			}
+		}
+	}
+
+	require.NoError(t, store.calcActiveUserWindows(appId, date))
+
+	weekly, err := store.GetSimpleCPVSumTotal(appId, WeeklyActiveCPVCounter, date, date)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), weekly)
+	monthly, err := store.GetSimpleCPVSumTotal(appId, MonthlyActiveCPVCounter, date, date)
+	require.NoError(t, err)
+	require.Equal(t, float64(3), monthly)
+	channel, err := store.GetSimpleCPVChannelSumDate(appId, MonthlyActiveCPVCounter, "a", date, date)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), channel[date])
+
+	stickiness, err := store.GetSimpleCounterSum(appId, DailyActiveStickinessSimpleCounter, date, date)
+	require.NoError(t, err)
+	require.InDelta(t, 2.0/3, stickiness, 1e-9)
+}
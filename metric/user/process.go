@@ -5,17 +5,27 @@ import (
 	"github.com/lt90s/goanalytics/api/middlewares"
 	"github.com/lt90s/goanalytics/common"
 	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
 	log "github.com/sirupsen/logrus"
 	"strconv"
 	"time"
 )
 
-func SetupProcessor(subscriber pubsub.Subscriber, store Store) {
-	subscriber.Subscribe(EventUserOpenApp, openAppEventHandler(store), middlewares.MetaData{})
+func SetupProcessor(subscriber pubsub.Subscriber, store Store, appConfigGetter middlewares.AppConfigGetter) {
+	subscriber.Subscribe(EventUserOpenApp, openAppEventHandler(store, appConfigGetter), middlewares.MetaData{})
+
+	subscriber.Subscribe(EventUserIdentify, identifyEventHandler(store), middlewares.MetaData{})
+
+	subscriber.Subscribe(EventUserAlias, aliasEventHandler(store), aliasData{})
 
 	subscriber.Subscribe(DailyScheduleEvent, dailyScheduleEventHandler(store), DailyScheduleEventData{})
 
+	subscriber.Subscribe(LateDataScheduleEvent, lateDataScheduleEventHandler(store), LateDataScheduleEventData{})
+
 	subscriber.Subscribe(common.GlobalEventDropData, dropDataEventHandler(store), common.DropDataRequest{})
+
+	subscriber.Subscribe(common.GlobalEventBackfillRetention, backfillRetentionEventHandler(store),
+		common.BackfillRetentionRequest{})
 }
 
 func dropDataEventHandler(store Store) pubsub.EventHandler {
@@ -31,7 +41,113 @@ func dropDataEventHandler(store Store) pubsub.EventHandler {
 	})
 }
 
-func openAppEventHandler(store Store) pubsub.EventHandler {
+// backfillRetentionEventHandler runs the backfill in the background, it reads the whole
+// activity history of the app and must not hold the publisher, e.g. the retention days request
+func backfillRetentionEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		entry := log.WithFields(log.Fields{"data": data, "handler": "backfillRetentionEventHandler"})
+		r, ok := data.(*common.BackfillRetentionRequest)
+		if !ok {
+			entry.Warn("data type is not *common.BackfillRetentionRequest")
+			return errors.New("data type is not *common.BackfillRetentionRequest")
+		}
+		go func() {
+			if err := store.backfillRetention(r.AppId, r.Days); err != nil {
+				entry.Warn("backfillRetention error: ", err.Error())
+				return
+			}
+			entry.Info("backfillRetention done")
+		}()
+		return nil
+	})
+}
+
+// updateRegistrationConversion credits the registration of the device to its install date
+func updateRegistrationConversion(store Store, metadata *middlewares.MetaData) {
+	createdAt, err := store.getUserCreatedTimestamp(metadata.AppId, metadata.DeviceId)
+	if err != nil {
+		log.WithFields(log.Fields{"data": metadata, "error": err.Error()}).Warn("getUserCreatedTimestamp error")
+		return
+	}
+	installDate := utils.TimestampToDate(createdAt).Unix()
+	delta := int((metadata.DateTimestamp - installDate) / (24 * 3600))
+	if delta > 30 {
+		delta = 31
+	}
+	store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform, metadata.Version,
+		RegisteredDeviceCPVCounter, installDate, 1.0)
+	store.AddSlotCounter(metadata.AppId, RegistrationDaysSlotCounter, strconv.Itoa(delta), installDate, 1.0)
+}
+
+func updateActiveUserLocation(store Store, metadata *middlewares.MetaData) {
+	if metadata.Country == "" {
+		return
+	}
+	store.AddSlotCounter(metadata.AppId, CountryActiveUserSlotCounter, metadata.Country, metadata.DateTimestamp, 1.0)
+	if metadata.Region == "" {
+		return
+	}
+	store.AddSlotCounter(metadata.AppId, RegionActiveUserSlotCounter, metadata.Region, metadata.DateTimestamp, 1.0)
+	if metadata.City != "" {
+		store.AddSlotCounter(metadata.AppId, CityActiveUserSlotCounter, metadata.Region+"/"+metadata.City,
+			metadata.DateTimestamp, 1.0)
+	}
+}
+
+func updateActiveUserDevice(store Store, metadata *middlewares.MetaData) {
+	osVersion := ""
+	if metadata.OSVersion != "" {
+		osVersion = metadata.Platform + "/" + metadata.OSVersion
+	}
+	slots := map[string]string{
+		OSVersionActiveUserSlotCounter:    osVersion,
+		DeviceModelActiveUserSlotCounter:  metadata.DeviceModel,
+		ManufacturerActiveUserSlotCounter: metadata.Manufacturer,
+		LocaleActiveUserSlotCounter:       metadata.Locale,
+		NetworkTypeActiveUserSlotCounter:  metadata.NetworkType,
+		CarrierActiveUserSlotCounter:      metadata.Carrier,
+		ResolutionActiveUserSlotCounter:   metadata.Resolution,
+	}
+	for counterName, slot := range slots {
+		if slot != "" {
+			store.AddSlotCounter(metadata.AppId, counterName, slot, metadata.DateTimestamp, 1.0)
+		}
+	}
+}
+
+func identifyEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		entry := log.WithFields(log.Fields{"data": data, "handler": "identifyEventHandler"})
+		metadata, ok := data.(*middlewares.MetaData)
+		if !ok {
+			entry.Warn("data type is not *middlewares.MetaData")
+			return errors.New("data type is not *middlewares.MetaData")
+		}
+		_, err := store.identify(metadata.AppId, metadata.DeviceId, metadata.UserId)
+		if err != nil {
+			entry.Warn("identify error: ", err.Error())
+		}
+		return err
+	})
+}
+
+func aliasEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		entry := log.WithFields(log.Fields{"data": data, "handler": "aliasEventHandler"})
+		alias, ok := data.(*aliasData)
+		if !ok {
+			entry.Warn("data type is not *aliasData")
+			return errors.New("data type is not *aliasData")
+		}
+		err := store.alias(alias.MetaData.AppId, alias.PreviousId, alias.MetaData.UserId)
+		if err != nil {
+			entry.Warn("alias error: ", err.Error())
+		}
+		return err
+	})
+}
+
+func openAppEventHandler(store Store, appConfigGetter middlewares.AppConfigGetter) pubsub.EventHandler {
 	return pubsub.EventHandlerFunc(func(data interface{}) error {
 		entry := log.WithFields(log.Fields{"data": data, "handler": "openAppEventHandler"})
 
@@ -43,8 +159,15 @@ func openAppEventHandler(store Store) pubsub.EventHandler {
 
 		entry.Debug("Handle open app event")
 
+		retentionDays := middlewares.RetentionDaysOf(appConfigGetter, metadata.AppId)
+
 		store.saveOpenAppData(metadata)
 
+		// derived daily data of a past day has to be computed again
+		if metadata.DateTimestamp < utils.TodayTimestamp() {
+			store.MarkDailyLate(metadata.AppId, DailyScheduleEvent, metadata.DateTimestamp)
+		}
+
 		// open app distribution
 		hourSlot := strconv.Itoa(time.Unix(metadata.Timestamp, 0).Hour())
 		store.AddSlotCounter(metadata.AppId, OpenAppTimeDistributionSlotCounter, hourSlot, metadata.DateTimestamp, 1.0)
@@ -54,12 +177,15 @@ func openAppEventHandler(store Store) pubsub.EventHandler {
 			metadata.Version, OpenAppCPVCounter, metadata.DateTimestamp, 1.0)
 
 		// newly registered user
-		if store.isUserIdNew(metadata.AppId, metadata.UserId) {
+		if metadata.UserId != "" && store.isUserIdNew(metadata.AppId, metadata.UserId) {
 			entry.Debug("Newly registered user")
 			store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform,
 				metadata.Version, NewRegisteredUserCPVCounter, metadata.DateTimestamp, 1.0)
 		}
 
+		// version upgrade, before the device record takes the new version
+		versionChanged := updateVersionUpgrade(store, metadata)
+
 		// new user
 		if store.updateUserRecord(metadata) {
 			entry.Debug("New user")
@@ -67,10 +193,33 @@ func openAppEventHandler(store Store) pubsub.EventHandler {
 				metadata.DateTimestamp, 1.0)
 			store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform,
 				metadata.Version, NewUserCPVCounter, metadata.DateTimestamp, 1.0)
+			if metadata.Country != "" {
+				store.AddSlotCounter(metadata.AppId, CountryNewUserSlotCounter, metadata.Country,
+					metadata.DateTimestamp, 1.0)
+			}
+			// campaign attribution
+			attributeNewUser(store, metadata)
+		}
+
+		// registered user daily active user, retention & install to registration conversion
+		if metadata.UserId != "" {
+			updateCohort(store, registeredUserCohort, metadata.UserId, metadata, retentionDays)
+			if store.deviceFirstRegistration(metadata) {
+				updateRegistrationConversion(store, metadata)
+			}
+		}
+
+		// user based new user, daily active user & retention
+		canonicalId, err := canonicalUserOf(store, metadata)
+		if err != nil {
+			entry.Warn("canonicalUserOf error: ", err.Error())
+		} else {
+			updateCohort(store, canonicalUserCohort, canonicalId, metadata, retentionDays)
 		}
 
 		// FirstOpen update daily active user counter & user retention & active user retention
-		if store.deviceFirstOpenToday(metadata) {
+		firstOpenToday := store.deviceFirstOpenToday(metadata)
+		if firstOpenToday {
 			entry.Debug("User first open app today")
 			// daily active user
 			store.AddSimpleCPVCounter(metadata.AppId, metadata.Channel, metadata.Platform,
@@ -79,11 +228,22 @@ func openAppEventHandler(store Store) pubsub.EventHandler {
 			store.AddSlotCounter(metadata.AppId, ActiveUserTimeDistributionSlotCounter,
 				hourSlot, metadata.DateTimestamp, 1.0)
 			// new user retention
-			store.updateNewUserRetention(metadata)
+			store.updateNewUserRetention(metadata, retentionDays)
 			// active user retention
-			store.updateActiveUserRetention(metadata)
+			store.updateActiveUserRetention(metadata, retentionDays)
 			// active user freshness
 			store.updateActiveUserFreshness(metadata)
+			// active user location
+			updateActiveUserLocation(store, metadata)
+			// active user device attributes
+			updateActiveUserDevice(store, metadata)
+			// attributed campaign activity
+			updateCampaignActivity(store, metadata, retentionDays)
+		}
+
+		// attribute only segment membership, after the device record is updated
+		if firstOpenToday || versionChanged {
+			updateSegments(store, metadata)
 		}
 		return nil
 	})
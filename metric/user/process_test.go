@@ -5,6 +5,7 @@ import (
 	"github.com/lt90s/goanalytics/api/middlewares"
 	"github.com/lt90s/goanalytics/storage/mongodb"
 	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -12,6 +13,14 @@ const (
 	prefix = "goanalytics_process_test_"
 )
 
+type mockAppConfigGetter struct {
+	retentionDays []int
+}
+
+func (m mockAppConfigGetter) GetAppConfig(appId string) (middlewares.AppConfig, error) {
+	return middlewares.AppConfig{RetentionDays: m.retentionDays}, nil
+}
+
 // BenchmarkOpenAppEventHandler-12    	     500	   2169938 ns/op
 // BenchmarkOpenAppEventHandler-12    	     100	  10152505 ns/op
 func BenchmarkOpenAppEventHandler(b *testing.B) {
@@ -19,7 +28,7 @@ func BenchmarkOpenAppEventHandler(b *testing.B) {
 	store := NewMongoStore(client, prefix)
 	defer client.Database(prefix + appId).Drop(context.Background())
 
-	handler := openAppEventHandler(store)
+	handler := openAppEventHandler(store, mockAppConfigGetter{})
 	data := &middlewares.MetaData{
 		AppId:         appId,
 		DeviceId:      "deviceId",
@@ -37,3 +46,46 @@ func BenchmarkOpenAppEventHandler(b *testing.B) {
 		handler.Handle(data)
 	}
 }
+
+func TestLateDataScheduleEventHandler(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, prefix)
+	defer store.dropData(appId)
+
+	yesterday := utils.TodayDiff(1).Unix()
+	handler := openAppEventHandler(store, mockAppConfigGetter{})
+	data := &middlewares.MetaData{
+		AppId:         appId,
+		DeviceId:      "a",
+		Timestamp:     yesterday + 3600,
+		DateTimestamp: yesterday,
+	}
+	require.NoError(t, handler.Handle(data))
+
+	err := dailyScheduleEventHandler(store).Handle(&DailyScheduleEventData{AppId: appId, Timestamp: yesterday})
+	require.NoError(t, err)
+	distribution, err := store.GetSlotCounterSpan(appId, OpenAppCountDistributionSlotCounter, yesterday, yesterday)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, distribution[yesterday]["1-2"])
+
+	// late data for yesterday
+	data.DeviceId = "b"
+	require.NoError(t, handler.Handle(data))
+
+	lateHandler := lateDataScheduleEventHandler(store)
+	require.NoError(t, lateHandler.Handle(&LateDataScheduleEventData{AppId: appId}))
+	distribution, err = store.GetSlotCounterSpan(appId, OpenAppCountDistributionSlotCounter, yesterday, yesterday)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, distribution[yesterday]["1-2"])
+
+	dates, err := store.PopDailyLate(appId, DailyScheduleEvent)
+	require.NoError(t, err)
+	require.Len(t, dates, 0)
+}
+
+func TestDependentDates(t *testing.T) {
+	day := int64(24 * 3600)
+	require.Equal(t, []int64{day, 2 * day, 3 * day, 4 * day}, dependentDates([]int64{3 * day, day}, 2, 4*day))
+	require.Equal(t, []int64{5 * day}, dependentDates([]int64{5 * day}, 30, 4*day))
+	require.Len(t, dependentDates(nil, 30, 4*day), 0)
+}
@@ -2,8 +2,11 @@ package user
 
 import (
 	"errors"
+	"github.com/lt90s/goanalytics/conf"
 	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/utils"
 	"github.com/sirupsen/logrus"
+	"sort"
 )
 
 type DailyScheduleEventData struct {
@@ -17,13 +20,113 @@ func dailyScheduleEventHandler(store Store) pubsub.EventHandler {
 		if !ok {
 			return errors.New("UserDailyScheduleEventHandler: data is not of type *UserDailyScheduleEventData")
 		}
-		calcDailyActiveNewUserPercent(eventData, store)
-		calcDailyActiveUserAffinity(eventData, store)
-		calcOpenAppCountDistribution(eventData, store)
+		return calcDaily(eventData, store)
+	})
+}
+
+type LateDataScheduleEventData struct {
+	AppId string `json:"appId"`
+}
+
+// lateDataScheduleEventHandler recomputes the days that received data after their daily computations
+// ran or whose computations failed, along with the processed days reading them through their windows
+func lateDataScheduleEventHandler(store Store) pubsub.EventHandler {
+	return pubsub.EventHandlerFunc(func(data interface{}) error {
+		eventData, ok := data.(*LateDataScheduleEventData)
+		if !ok {
+			return errors.New("UserLateDataScheduleEventHandler: data is not of type *LateDataScheduleEventData")
+		}
+		lateDates, err := store.PopDailyLate(eventData.AppId, DailyScheduleEvent)
+		if err != nil {
+			return err
+		}
+		late := make(map[int64]bool, len(lateDates))
+		for _, date := range lateDates {
+			late[date] = true
+		}
+		for _, date := range dependentDates(lateDates, dailyWindowDays(), utils.TodayDiff(1).Unix()) {
+			if !late[date] {
+				// days not processed yet are computed by the daily schedule
+				processed, err := store.IsDailyProcessed(eventData.AppId, DailyScheduleEvent, date)
+				if err != nil {
+					return err
+				}
+				if !processed {
+					continue
+				}
+			}
+			logrus.WithFields(logrus.Fields{"appId": eventData.AppId, "timestamp": date}).Info("Recompute user daily data")
+			calcDaily(&DailyScheduleEventData{Timestamp: date, AppId: eventData.AppId}, store)
+		}
 		return nil
 	})
 }
 
+// dailyWindowDays is the number of following days whose daily data read a day, through the
+// monthly active users, the affinity and the lifecycle windows
+func dailyWindowDays() int {
+	days := 30
+	if inactiveDays := int(conf.GetConfInt64(conf.ChurnInactiveDaysConfKey)); inactiveDays > days {
+		days = inactiveDays
+	}
+	return days
+}
+
+// dependentDates returns the dates and the days following them within days, up to last, in order
+func dependentDates(dates []int64, days int, last int64) []int64 {
+	seen := make(map[int64]bool)
+	dependent := make([]int64, 0)
+	for _, date := range dates {
+		for i := 0; i <= days; i++ {
+			day := date + int64(i*24*3600)
+			if day > last && i > 0 {
+				break
+			}
+			if !seen[day] {
+				seen[day] = true
+				dependent = append(dependent, day)
+			}
+		}
+	}
+	sort.Slice(dependent, func(i, j int) bool { return dependent[i] < dependent[j] })
+	return dependent
+}
+
+// calcDaily computes the daily data of the date. The date is marked processed only when every
+// computation succeeded, otherwise it is computed again with the late dates.
+func calcDaily(data *DailyScheduleEventData, store Store) error {
+	calcs := []struct {
+		name string
+		calc func(data *DailyScheduleEventData, store Store) error
+	}{
+		{"calcDailyActiveNewUserPercent", calcDailyActiveNewUserPercent},
+		{"calcDailyActiveUserAffinity", calcDailyActiveUserAffinity},
+		{"calcOpenAppCountDistribution", calcOpenAppCountDistribution},
+		{"calcUserLifecycle", calcUserLifecycle},
+		{"calcActiveUserWindows", calcActiveUserWindows},
+	}
+	var failed error
+	for _, c := range calcs {
+		if err := c.calc(data, store); err != nil {
+			logrus.WithFields(logrus.Fields{"data": data, "error": err.Error()}).Warn("[" + c.name + "] error")
+			failed = err
+		}
+	}
+
+	entry := logrus.WithFields(logrus.Fields{"data": data})
+	if failed != nil {
+		if err := store.MarkDailyFailed(data.AppId, DailyScheduleEvent, data.Timestamp); err != nil {
+			entry.WithFields(logrus.Fields{"error": err.Error()}).Warn("MarkDailyFailed error")
+		}
+		return failed
+	}
+	err := store.MarkDailyProcessed(data.AppId, DailyScheduleEvent, data.Timestamp)
+	if err != nil {
+		entry.WithFields(logrus.Fields{"error": err.Error()}).Warn("MarkDailyProcessed error")
+	}
+	return err
+}
+
 func calculatePercent(a, b float64) float64 {
 	if b == 0 {
 		return 0
@@ -32,43 +135,56 @@ func calculatePercent(a, b float64) float64 {
 	return a / b
 }
 
-func calcDailyActiveNewUserPercent(data *DailyScheduleEventData, store Store) {
+func calcDailyActiveNewUserPercent(data *DailyScheduleEventData, store Store) error {
 	appId := data.AppId
-	entry := logrus.WithFields(logrus.Fields{"timestamp": data.Timestamp, "appId": appId})
 	dailyActiveCount, err := store.GetSimpleCPVSumTotal(appId, DailyActiveCPVCounter, data.Timestamp, data.Timestamp)
 	if err != nil {
-		entry.WithFields(logrus.Fields{"error": err.Error()}).Warn("[calcDailyActiveNewUserPercent] error")
-		return
+		return err
 	}
 
 	newUserCount, err := store.GetSimpleCPVSumTotal(appId, NewUserCPVCounter, data.Timestamp, data.Timestamp)
 	if err != nil {
-		entry.WithFields(logrus.Fields{"error": err.Error()}).Warn("[calcDailyActiveNewUserPercent] error")
-		return
+		return err
 	}
 	percent := calculatePercent(newUserCount, dailyActiveCount)
-	store.SetSimpleCounter(appId, DailyActiveNewUserPercentSimpleCounter, data.Timestamp, percent)
+	return store.SetSimpleCounter(appId, DailyActiveNewUserPercentSimpleCounter, data.Timestamp, percent)
 }
 
-func calcDailyActiveUserAffinity(data *DailyScheduleEventData, store Store) {
+func calcDailyActiveUserAffinity(data *DailyScheduleEventData, store Store) error {
 	timestamp := data.Timestamp
 
 	saus := store.getUniqueActiveUserCount(data.AppId, timestamp-7*24*3600, timestamp)
 	faus := store.getUniqueActiveUserCount(data.AppId, timestamp-15*24*3600, timestamp)
 	taus := store.getUniqueActiveUserCount(data.AppId, timestamp-30*24*3600, timestamp)
 	if saus == 0 || faus == 0 || taus == 0 {
-		return
+		return nil
+	}
+	dailyActiveCount, err := store.GetSimpleCPVSumTotal(data.AppId, DailyActiveCPVCounter, timestamp, timestamp)
+	if err != nil {
+		return err
 	}
-	dailyActiveCount, _ := store.GetSimpleCPVSumTotal(data.AppId, DailyActiveCPVCounter, timestamp, timestamp)
 	p1 := calculatePercent(dailyActiveCount, float64(saus))
 	p2 := calculatePercent(dailyActiveCount, float64(faus))
 	p3 := calculatePercent(dailyActiveCount, float64(taus))
 
-	store.SetSlotCounter(data.AppId, DailyActiveUserAffinitySlotCounter, "7", timestamp, p1)
-	store.SetSlotCounter(data.AppId, DailyActiveUserAffinitySlotCounter, "15", timestamp, p2)
-	store.SetSlotCounter(data.AppId, DailyActiveUserAffinitySlotCounter, "30", timestamp, p3)
+	if err = store.SetSlotCounter(data.AppId, DailyActiveUserAffinitySlotCounter, "7", timestamp, p1); err != nil {
+		return err
+	}
+	if err = store.SetSlotCounter(data.AppId, DailyActiveUserAffinitySlotCounter, "15", timestamp, p2); err != nil {
+		return err
+	}
+	return store.SetSlotCounter(data.AppId, DailyActiveUserAffinitySlotCounter, "30", timestamp, p3)
+}
+
+func calcOpenAppCountDistribution(data *DailyScheduleEventData, store Store) error {
+	return store.calcOpenAppCountDistribution(data.AppId, data.Timestamp)
+}
+
+func calcUserLifecycle(data *DailyScheduleEventData, store Store) error {
+	inactiveDays := int(conf.GetConfInt64(conf.ChurnInactiveDaysConfKey))
+	return store.calcUserLifecycle(data.AppId, data.Timestamp, inactiveDays)
 }
 
-func calcOpenAppCountDistribution(data *DailyScheduleEventData, store Store) {
-	store.calcOpenAppCountDistribution(data.AppId, data.Timestamp)
+func calcActiveUserWindows(data *DailyScheduleEventData, store Store) error {
+	return store.calcActiveUserWindows(data.AppId, data.Timestamp)
 }
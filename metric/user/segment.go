@@ -0,0 +1,122 @@
+package user
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/metric/segment"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+// segments are cached so that open app events do not read the segment definitions every time,
+// new segments are evaluated by metric/segment when created
+const segmentCacheTTL = time.Minute
+
+type segmentCacheItem struct {
+	segments []segment.Segment
+	expireAt time.Time
+}
+
+type segmentCache struct {
+	mutex sync.RWMutex
+	items map[string]segmentCacheItem
+}
+
+func newSegmentCache() *segmentCache {
+	return &segmentCache{items: make(map[string]segmentCacheItem)}
+}
+
+func (ms *mongodbStore) getAttributeSegments(appId string) ([]segment.Segment, error) {
+	now := time.Now()
+	ms.segments.mutex.RLock()
+	item, ok := ms.segments.items[appId]
+	ms.segments.mutex.RUnlock()
+	if ok && now.Before(item.expireAt) {
+		return item.segments, nil
+	}
+
+	segments, err := ms.loadAttributeSegments(appId)
+	if err != nil {
+		return nil, err
+	}
+	ms.segments.mutex.Lock()
+	ms.segments.items[appId] = segmentCacheItem{segments: segments, expireAt: now.Add(segmentCacheTTL)}
+	ms.segments.mutex.Unlock()
+	return segments, nil
+}
+
+func (ms *mongodbStore) loadAttributeSegments(appId string) ([]segment.Segment, error) {
+	ctx := context.Background()
+	cursor, err := ms.database(appId).Collection(segment.SegmentCollectionName).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	segments := make([]segment.Segment, 0)
+	for cursor.Next(ctx) {
+		var s segment.Segment
+		if err = cursor.Decode(&s); err != nil {
+			return nil, err
+		}
+		if s.IsAttributeOnly() {
+			segments = append(segments, s)
+		}
+	}
+	return segments, cursor.Err()
+}
+
+// updateSegmentMember adds the device to the segment when its record matches the segment rules
+// and removes it otherwise
+func (ms *mongodbStore) updateSegmentMember(appId, deviceId string, s segment.Segment) error {
+	ctx := context.Background()
+	filter := segment.AttributeFilter(s.Rules)
+	filter["deviceId"] = deviceId
+	count, err := ms.database(appId).Collection(userCollectionName).CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	member := bson.M{"segmentId": s.Id, "deviceId": deviceId}
+	collection := ms.database(appId).Collection(segment.MemberCollectionName)
+	if count == 0 {
+		_, err = collection.DeleteMany(ctx, member)
+		return err
+	}
+
+	// the cached segment may be of a replaced generation
+	var current struct {
+		Generation string `bson:"generation"`
+	}
+	option := options.FindOne().SetProjection(bson.M{"generation": 1})
+	err = ms.database(appId).Collection(segment.SegmentCollectionName).FindOne(ctx, bson.M{"_id": s.Id}, option).
+		Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	member["generation"] = current.Generation
+	upsert := true
+	_, err = collection.UpdateOne(ctx, member, bson.M{"$set": member}, &options.UpdateOptions{Upsert: &upsert})
+	return err
+}
+
+// updateSegments keeps the device membership of attribute only segments up to date, it is called
+// on the first open of the day and when the version of the device changes. Other segments are
+// evaluated by the daily schedule.
+func updateSegments(store Store, metadata *middlewares.MetaData) {
+	entry := log.WithFields(log.Fields{"data": metadata})
+	segments, err := store.getAttributeSegments(metadata.AppId)
+	if err != nil {
+		entry.Warn("getAttributeSegments error: ", err.Error())
+		return
+	}
+	for _, s := range segments {
+		if err = store.updateSegmentMember(metadata.AppId, metadata.DeviceId, s); err != nil {
+			entry.Warn("updateSegmentMember error: ", err.Error())
+		}
+	}
+}
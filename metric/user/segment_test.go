@@ -0,0 +1,58 @@
+package user
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/metric/segment"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestUpdateSegments(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, prefix)
+	defer store.dropData(appId)
+
+	ctx := context.Background()
+	database := client.Database(prefix + appId)
+	_, err := database.Collection(segment.SegmentCollectionName).InsertMany(ctx, []interface{}{
+		segment.Segment{Id: "ios", Name: "ios", WindowDays: 1,
+			Rules: []segment.Rule{{Field: segment.FieldPlatform, Operator: segment.OperatorEq, Value: "ios"}}},
+		// evaluated by the daily schedule only
+		segment.Segment{Id: "active", Name: "active", WindowDays: 1,
+			Rules: []segment.Rule{{Field: segment.FieldActiveDays, Operator: segment.OperatorGte, Value: 1.0}}},
+	})
+	require.NoError(t, err)
+
+	handler := openAppEventHandler(store, mockAppConfigGetter{})
+	today := utils.TodayTimestamp()
+	members := database.Collection(segment.MemberCollectionName)
+	// membership is updated on the first open of the day
+	const day = 24 * 3600
+	for _, open := range []struct {
+		platform string
+		date     int64
+		member   int64
+	}{
+		{"ios", today - day, 1},
+		{"android", today - day, 1},
+		{"android", today, 0},
+	} {
+		require.NoError(t, handler.Handle(&middlewares.MetaData{
+			AppId:         appId,
+			DeviceId:      "a",
+			Platform:      open.platform,
+			Timestamp:     open.date + 3600,
+			DateTimestamp: open.date,
+		}))
+		count, err := members.CountDocuments(ctx, bson.M{"segmentId": "ios", "deviceId": "a"})
+		require.NoError(t, err)
+		require.Equal(t, open.member, count)
+	}
+	count, err := members.CountDocuments(ctx, bson.M{"segmentId": "active"})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), count)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/metric/segment"
 	"github.com/lt90s/goanalytics/storage"
 	"github.com/lt90s/goanalytics/storage/mongodb"
 	"github.com/lt90s/goanalytics/utils"
@@ -17,15 +18,41 @@ import (
 
 type Store interface {
 	storage.Counter
+	storage.DailyTracker
 	saveOpenAppData(data *middlewares.MetaData) error
 	isUserIdNew(appId, userId string) bool
 	updateUserRecord(data *middlewares.MetaData) bool
 	deviceFirstOpenToday(data *middlewares.MetaData) bool
-	updateNewUserRetention(data *middlewares.MetaData)
-	updateActiveUserRetention(data *middlewares.MetaData)
+	updateNewUserRetention(data *middlewares.MetaData, days []int)
+	updateActiveUserRetention(data *middlewares.MetaData, days []int)
+	backfillRetention(appId string, days []int) error
 	updateActiveUserFreshness(data *middlewares.MetaData)
 	getUniqueActiveUserCount(appId string, start, end int64) int
 	calcOpenAppCountDistribution(appId string, timestamp int64) error
+	calcUserLifecycle(appId string, timestamp int64, inactiveDays int) error
+	calcActiveUserWindows(appId string, timestamp int64) error
+
+	resolveDevice(appId, deviceId string) (string, error)
+	identify(appId, deviceId, userId string) (string, error)
+	alias(appId, previousId, userId string) error
+
+	cohortFirstSeen(c cohort, id string, data *middlewares.MetaData) bool
+	cohortFirstActiveToday(c cohort, id string, data *middlewares.MetaData) bool
+	updateCohortRetention(c cohort, id string, data *middlewares.MetaData, days []int)
+	updateCohortFreshness(c cohort, id string, data *middlewares.MetaData)
+	deviceFirstRegistration(data *middlewares.MetaData) bool
+	getUserCreatedTimestamp(appId, deviceId string) (int64, error)
+
+	matchCampaignClick(data *middlewares.MetaData, referrerWindow, fingerprintWindow int64) (campaignId string,
+		matchedBy string, err error)
+	setUserCampaign(data *middlewares.MetaData, campaignId, matchedBy string) error
+	getUserCampaign(appId, deviceId string) (campaignId string, createdAt int64, err error)
+
+	updateUserVersion(data *middlewares.MetaData) (previous string, changed bool, err error)
+	saveVersionUpgrade(data *middlewares.MetaData, from string) error
+
+	getAttributeSegments(appId string) ([]segment.Segment, error)
+	updateSegmentMember(appId, deviceId string, s segment.Segment) error
 
 	dropData(appId string)
 }
@@ -38,15 +65,19 @@ const (
 
 type mongodbStore struct {
 	storage.Counter
+	storage.DailyTracker
 	client         *mongo.Client
 	databasePrefix string
+	segments       *segmentCache
 }
 
 func NewMongoStore(client *mongo.Client, databasePrefix string) Store {
 	return &mongodbStore{
 		Counter:        mongodb.NewCounter(client, databasePrefix),
+		DailyTracker:   mongodb.NewDailyTracker(client, databasePrefix),
 		client:         client,
 		databasePrefix: databasePrefix,
+		segments:       newSegmentCache(),
 	}
 }
 
@@ -64,12 +95,17 @@ func (ms *mongodbStore) saveOpenAppData(data *middlewares.MetaData) error {
 		return errors.New("data cannot be nil")
 	}
 	_, err := ms.database(data.AppId).Collection(openAppDataCollectionName).InsertOne(context.Background(), bson.M{
-		"timestamp": data.Timestamp,
-		"deviceId":  data.DeviceId,
-		"channel":   data.Channel,
-		"platform":  data.Platform,
-		"version":   data.Version,
-		"userId":    data.UserId,
+		"timestamp":       data.Timestamp,
+		"serverTimestamp": data.ServerTimestamp,
+		"deviceId":        data.DeviceId,
+		"channel":         data.Channel,
+		"platform":        data.Platform,
+		"version":         data.Version,
+		"userId":          data.UserId,
+		"clientIp":        data.ClientIP,
+		"country":         data.Country,
+		"region":          data.Region,
+		"city":            data.City,
 	})
 	return err
 }
@@ -90,16 +126,39 @@ func (ms *mongodbStore) updateUserRecord(data *middlewares.MetaData) bool {
 	filter := bson.M{
 		"deviceId": data.DeviceId,
 	}
+	set := bson.M{
+		"channel":   data.Channel,
+		"platform":  data.Platform,
+		"userId":    data.UserId,
+		"updatedAt": data.Timestamp,
+	}
+	// keep the known location when the ip cannot be resolved
+	if data.Country != "" {
+		set["country"], set["region"], set["city"] = data.Country, data.Region, data.City
+	}
+	// keep the latest reported device attributes
+	for key, value := range map[string]string{
+		"osVersion":    data.OSVersion,
+		"deviceModel":  data.DeviceModel,
+		"manufacturer": data.Manufacturer,
+		"locale":       data.Locale,
+		"networkType":  data.NetworkType,
+		"carrier":      data.Carrier,
+		"resolution":   data.Resolution,
+	} {
+		if value != "" {
+			set[key] = value
+		}
+	}
 	update := bson.M{
-		"$set": bson.M{
-			"channel":   data.Channel,
-			"platform":  data.Platform,
-			"version":   data.Version,
-			"userId":    data.UserId,
-			"updatedAt": data.Timestamp,
-		},
+		"$set": set,
+		// the version of existing records is maintained by updateUserVersion, installVersion
+		// keeps the version the device was installed with
 		"$setOnInsert": bson.M{
-			"createdAt": data.Timestamp,
+			"createdAt":        data.Timestamp,
+			"installVersion":   data.Version,
+			"version":          data.Version,
+			"versionUpdatedAt": data.Timestamp,
 		},
 	}
 	upsert := true
@@ -135,6 +194,26 @@ func (ms *mongodbStore) deviceFirstOpenToday(data *middlewares.MetaData) bool {
 	return result.UpsertedCount > 0
 }
 
+// deviceFirstRegistration marks the device record as registered and reports whether a user id
+// is reported by the device for the first time
+func (ms *mongodbStore) deviceFirstRegistration(data *middlewares.MetaData) bool {
+	filter := bson.M{
+		"deviceId":     data.DeviceId,
+		"registeredAt": bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"registeredAt": data.Timestamp,
+		},
+	}
+	result, err := ms.database(data.AppId).Collection(userCollectionName).UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		log.WithFields(log.Fields{"error": err.Error()}).Warn("deviceFirstRegistration error")
+		return false
+	}
+	return result.ModifiedCount > 0
+}
+
 func (ms *mongodbStore) getUserCreatedTimestamp(appId, deviceId string) (int64, error) {
 	ctx := context.Background()
 	filter := bson.M{"deviceId": deviceId}
@@ -153,7 +232,9 @@ func (ms *mongodbStore) getUserCreatedTimestamp(appId, deviceId string) (int64,
 	return ob.CreatedAt, nil
 }
 
-func (ms *mongodbStore) updateNewUserRetention(data *middlewares.MetaData) {
+// updateNewUserRetention credits the retention of the device's install date when the device is
+// active on one of the retention days
+func (ms *mongodbStore) updateNewUserRetention(data *middlewares.MetaData, days []int) {
 	createdAt, err := ms.getUserCreatedTimestamp(data.AppId, data.DeviceId)
 	if err != nil {
 		log.Error("[updateNewUserRetention] get user created time error", "error", err.Error())
@@ -161,10 +242,7 @@ func (ms *mongodbStore) updateNewUserRetention(data *middlewares.MetaData) {
 	}
 	createdDateTimestamp := utils.TimestampToDate(createdAt).Unix()
 	delta := int((data.Timestamp - createdDateTimestamp) / (24 * 3600))
-	if delta > 30 {
-		return
-	}
-	for _, day := range retentionDays {
+	for _, day := range days {
 		if delta == day {
 			slot := fmt.Sprintf("%d", day)
 			ms.AddSlotCounter(data.AppId, NewUserRetentionSlotCounter, slot, createdDateTimestamp, 1.0)
@@ -174,25 +252,40 @@ func (ms *mongodbStore) updateNewUserRetention(data *middlewares.MetaData) {
 	}
 }
 
-func (ms *mongodbStore) updateActiveUserRetention(data *middlewares.MetaData) {
+// updateActiveUserRetention credits the retention of the dates the device was active on, the
+// retention days before today
+func (ms *mongodbStore) updateActiveUserRetention(data *middlewares.MetaData, days []int) {
+	dayOf := make(map[int64]int, len(days))
+	timestamps := make([]int64, 0, len(days))
+	for _, day := range days {
+		deltaTimestamp := data.DateTimestamp - int64(day*24*3600)
+		dayOf[deltaTimestamp] = day
+		timestamps = append(timestamps, deltaTimestamp)
+	}
+
 	ctx := context.Background()
 	filter := bson.M{
-		"deviceId": data.DeviceId,
+		"deviceId":  data.DeviceId,
+		"timestamp": bson.M{"$in": timestamps},
 	}
-
-	for _, day := range retentionDays {
-		deltaTimestamp := data.DateTimestamp - int64(day*24*3600)
-		filter["timestamp"] = deltaTimestamp
-		count, err := ms.database(data.AppId).Collection(deviceActiveCollectionName).CountDocuments(ctx, filter)
-		if err != nil {
-			log.Error("[updateActiveUserRetention] CountDocuments error", "data", data, "day", day)
-			continue
-		}
-		if count > 0 {
-			slot := fmt.Sprintf("%d", day)
-			ms.AddSlotCounter(data.AppId, ActiveUserRetentionSlotCounter, slot, deltaTimestamp, 1.0)
-			ms.AddSlotCounter(data.AppId, ChannelActiveUserRetentionSlotCounterPrefix+data.Channel, slot, deltaTimestamp, 1.0)
+	option := options.Find().SetProjection(bson.M{"timestamp": 1})
+	cursor, err := ms.database(data.AppId).Collection(deviceActiveCollectionName).Find(ctx, filter, option)
+	if err != nil {
+		log.Error("[updateActiveUserRetention] Find error", "data", data, "error", err.Error())
+		return
+	}
+	defer cursor.Close(ctx)
+	var tmp struct {
+		Timestamp int64 `bson:"timestamp"`
+	}
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&tmp); err != nil {
+			log.Error("[updateActiveUserRetention] Decode error", "data", data, "error", err.Error())
+			return
 		}
+		slot := fmt.Sprintf("%d", dayOf[tmp.Timestamp])
+		ms.AddSlotCounter(data.AppId, ActiveUserRetentionSlotCounter, slot, tmp.Timestamp, 1.0)
+		ms.AddSlotCounter(data.AppId, ChannelActiveUserRetentionSlotCounterPrefix+data.Channel, slot, tmp.Timestamp, 1.0)
 	}
 }
 
@@ -276,6 +369,8 @@ func (ms *mongodbStore) calcOpenAppCountDistribution(appId string, timestamp int
 	var tmp struct {
 		Count int `bson:"count"`
 	}
+	// the distribution is set rather than added so that the day can be computed again
+	distribution := make(map[string]float64)
 	for cursor.Next(ctx) {
 		err = cursor.Decode(&tmp)
 		if err != nil {
@@ -302,7 +397,16 @@ func (ms *mongodbStore) calcOpenAppCountDistribution(appId string, timestamp int
 		} else {
 			slot = "50+"
 		}
-		ms.SetSlotCounter(appId, OpenAppCountDistributionSlotCounter, slot, timestamp, 1.0)
+		distribution[slot] += 1.0
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+	for slot, count := range distribution {
+		err = ms.SetSlotCounter(appId, OpenAppCountDistributionSlotCounter, slot, timestamp, count)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
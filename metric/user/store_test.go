@@ -73,7 +73,7 @@ func TestUpdateNewUserRetention(t *testing.T) {
 			data.DeviceId = "efgh"
 			data.Channel = "y"
 		}
-		store.updateNewUserRetention(data)
+		store.updateNewUserRetention(data, []int{1, 2, 3, 4, 5, 6, 7})
 	}
 
 	start := utils.TodayDiff(7).Unix()
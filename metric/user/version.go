@@ -0,0 +1,70 @@
+package user
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/metric/release"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// updateUserVersion sets the version of the device record and returns the previous version when
+// the device moved from another version. Devices without a record are new users.
+//
+// versionUpdatedAt keeps the timestamp of the latest event setting the version, events reported
+// late or uploaded from an offline cache do not move the device back to an older version.
+func (ms *mongodbStore) updateUserVersion(data *middlewares.MetaData) (previous string, changed bool, err error) {
+	filter := bson.M{
+		"deviceId": data.DeviceId,
+		"$or": bson.A{
+			bson.M{"versionUpdatedAt": bson.M{"$exists": false}},
+			bson.M{"versionUpdatedAt": bson.M{"$lte": data.Timestamp}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"version": data.Version, "versionUpdatedAt": data.Timestamp}}
+	var before struct {
+		Version string `bson:"version"`
+	}
+	err = ms.database(data.AppId).Collection(userCollectionName).FindOneAndUpdate(context.Background(), filter,
+		update).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return before.Version, before.Version != "" && before.Version != data.Version, nil
+}
+
+func (ms *mongodbStore) saveVersionUpgrade(data *middlewares.MetaData, from string) error {
+	_, err := ms.database(data.AppId).Collection(release.UpgradeCollectionName).InsertOne(context.Background(), bson.M{
+		"deviceId":  data.DeviceId,
+		"from":      from,
+		"to":        data.Version,
+		"timestamp": data.Timestamp,
+	})
+	return err
+}
+
+// updateVersionUpgrade records the upgrade event of a device reporting a version different from
+// its previous one and reports whether the version changed, see metric/release
+func updateVersionUpgrade(store Store, metadata *middlewares.MetaData) bool {
+	if metadata.Version == "" {
+		return false
+	}
+	entry := log.WithFields(log.Fields{"data": metadata})
+	from, changed, err := store.updateUserVersion(metadata)
+	if err != nil {
+		entry.Warn("updateUserVersion error: ", err.Error())
+		return false
+	}
+	if !changed {
+		return false
+	}
+	if err = store.saveVersionUpgrade(metadata, from); err != nil {
+		entry.Warn("saveVersionUpgrade error: ", err.Error())
+	}
+	store.AddSlotCounter(metadata.AppId, release.VersionUpgradeSlotCounter, release.UpgradeSlot(from, metadata.Version),
+		metadata.DateTimestamp, 1.0)
+	return true
+}
@@ -0,0 +1,60 @@
+package user
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/api/middlewares"
+	"github.com/lt90s/goanalytics/metric/release"
+	"github.com/lt90s/goanalytics/storage/mongodb"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestUpdateVersionUpgrade(t *testing.T) {
+	client := mongodb.DefaultClient
+	store := NewMongoStore(client, prefix)
+	defer store.dropData(appId)
+
+	handler := openAppEventHandler(store, mockAppConfigGetter{})
+	today := utils.TodayTimestamp()
+	events := []struct {
+		version   string
+		timestamp int64
+	}{
+		{"1.0.0", today + 3600},
+		{"1.0.0", today + 3601},
+		{"1.1.0", today + 3602},
+		{"1.1.0", today + 3603},
+		// uploaded late from the offline cache of the old version
+		{"1.0.0", today + 3601},
+	}
+	for _, event := range events {
+		require.NoError(t, handler.Handle(&middlewares.MetaData{
+			AppId:         appId,
+			DeviceId:      "a",
+			Version:       event.version,
+			Timestamp:     event.timestamp,
+			DateTimestamp: today,
+		}))
+	}
+
+	counters, err := store.GetSlotCounterSpan(appId, release.VersionUpgradeSlotCounter, today, today)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{release.UpgradeSlot("1.0.0", "1.1.0"): 1}, counters[today])
+
+	collection := client.Database(prefix + appId).Collection(release.UpgradeCollectionName)
+	count, err := collection.CountDocuments(context.Background(), bson.M{"deviceId": "a", "from": "1.0.0", "to": "1.1.0"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	var user struct {
+		Version        string `bson:"version"`
+		InstallVersion string `bson:"installVersion"`
+	}
+	err = client.Database(prefix+appId).Collection(userCollectionName).FindOne(context.Background(),
+		bson.M{"deviceId": "a"}).Decode(&user)
+	require.NoError(t, err)
+	require.Equal(t, "1.1.0", user.Version)
+	require.Equal(t, "1.0.0", user.InstallVersion)
+}
@@ -2,6 +2,9 @@ package schedule
 
 import (
 	"github.com/lt90s/goanalytics/event/pubsub"
+	"github.com/lt90s/goanalytics/metric/anomaly"
+	"github.com/lt90s/goanalytics/metric/revenue"
+	"github.com/lt90s/goanalytics/metric/segment"
 	"github.com/lt90s/goanalytics/metric/usage"
 	"github.com/lt90s/goanalytics/metric/user"
 	"github.com/lt90s/goanalytics/utils"
@@ -15,27 +18,61 @@ type AppIdsGetter interface {
 func RunScheduler(getter AppIdsGetter, publisher pubsub.Publisher) {
 	schedule := clockwork.NewScheduler()
 
-	appIds := getter.GetAppIds()
+	// app ids and the date are resolved when the job runs, so that apps created after
+	// startup are scheduled and every run computes its own yesterday
+	schedule.Schedule().Every().Day().At("1:00").Do(func() {
+		publishDaily(getter.GetAppIds(), utils.TodayDiff(1).Unix(), publisher)
+	})
 
-	yesterdayTimestamp := utils.TodayDiff(1).Unix()
+	// days that received data after their daily computations ran are computed again. the
+	// counters of yesterday are checked once its daily computations are marked processed,
+	// the check is skipped until then
+	schedule.Schedule().Every().Hour().Do(func() {
+		appIds := getter.GetAppIds()
+		publishLateData(appIds, publisher)
+		publishAnomalyCheck(appIds, utils.TodayDiff(1).Unix(), publisher)
+	})
 
+	go schedule.Run()
+}
+
+func publishDaily(appIds []string, yesterdayTimestamp int64, publisher pubsub.Publisher) {
 	for _, appId := range appIds {
-		userDailyData := user.DailyScheduleEventData{
+		publisher.Publish(user.DailyScheduleEvent, &user.DailyScheduleEventData{
+			Timestamp: yesterdayTimestamp,
+			AppId:     appId,
+		})
+
+		publisher.Publish(usage.DailyScheduleEvent, &usage.DailyScheduleEventData{
+			AppId:     appId,
+			Timestamp: yesterdayTimestamp,
+		})
+
+		publisher.Publish(revenue.DailyScheduleEvent, &revenue.DailyScheduleEventData{
+			AppId:     appId,
 			Timestamp: yesterdayTimestamp,
+		})
+
+		publisher.Publish(segment.DailyScheduleEvent, &segment.DailyScheduleEventData{
 			AppId:     appId,
-		}
-		schedule.Schedule().Every().Day().At("1:00").Do(func() {
-			publisher.Publish(user.DailyScheduleEvent, &userDailyData)
+			Timestamp: yesterdayTimestamp,
 		})
+	}
+}
 
-		usageDailyData := usage.DailyScheduleEventData{
+func publishAnomalyCheck(appIds []string, yesterdayTimestamp int64, publisher pubsub.Publisher) {
+	for _, appId := range appIds {
+		publisher.Publish(anomaly.ScheduleEvent, &anomaly.ScheduleEventData{
 			AppId:     appId,
 			Timestamp: yesterdayTimestamp,
-		}
-		schedule.Schedule().Every().Day().At("1:00").Do(func() {
-			publisher.Publish(usage.DailyScheduleEvent, &usageDailyData)
 		})
 	}
+}
 
-	go schedule.Run()
+func publishLateData(appIds []string, publisher pubsub.Publisher) {
+	for _, appId := range appIds {
+		publisher.Publish(user.LateDataScheduleEvent, &user.LateDataScheduleEventData{AppId: appId})
+		publisher.Publish(usage.LateDataScheduleEvent, &usage.LateDataScheduleEventData{AppId: appId})
+		publisher.Publish(revenue.LateDataScheduleEvent, &revenue.LateDataScheduleEventData{AppId: appId})
+	}
 }
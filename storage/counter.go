@@ -50,6 +50,7 @@ type Counter interface {
 	GetSimpleCPVSumDate(appId, counterName string, start, end int64) (map[int64]float64, error)
 	GetSimpleCPVDateCPV(appId, counterName string, start, end int64) (map[string]map[int64]map[string]float64, error)
 	GetSimpleCPVChannelSumDate(appId, counterName, channel string, start, end int64) (map[int64]float64, error)
+	GetSimpleCPVPlatformSumDate(appId, counterName, platform string, start, end int64) (map[int64]float64, error)
 
 	AddCustomizedCounter(appId string, data CustomizedCounter) error
 	GetCustomizedCounters(appId string) (counters []CustomizedCounter, err error)
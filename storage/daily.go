@@ -0,0 +1,15 @@
+package storage
+
+// DailyTracker records which days the daily computations of a metric have processed, and
+// which of those days received data afterwards so that they can be computed again
+type DailyTracker interface {
+	MarkDailyProcessed(appId, name string, date int64) error
+	IsDailyProcessed(appId, name string, date int64) (bool, error)
+	// MarkDailyFailed marks date as late and not processed, so that it is computed again with
+	// the late dates
+	MarkDailyFailed(appId, name string, date int64) error
+	// MarkDailyLate marks date as late if it has already been processed
+	MarkDailyLate(appId, name string, date int64) error
+	// PopDailyLate returns the late dates and clears their late mark
+	PopDailyLate(appId, name string) ([]int64, error)
+}
@@ -0,0 +1,17 @@
+package storage
+
+// Event is a raw custom event, kept for the analyses that can not be answered by counters
+type Event struct {
+	Name       string                 `json:"name" bson:"name"`
+	DeviceId   string                 `json:"deviceId" bson:"deviceId"`
+	UserId     string                 `json:"userId" bson:"userId"`
+	Channel    string                 `json:"channel" bson:"channel"`
+	Platform   string                 `json:"platform" bson:"platform"`
+	Version    string                 `json:"version" bson:"version"`
+	Timestamp  int64                  `json:"timestamp" bson:"timestamp"`
+	Properties map[string]interface{} `json:"properties" bson:"properties"`
+}
+
+type EventLog interface {
+	SaveEvent(appId string, event Event) error
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"container/list"
+	"github.com/lt90s/goanalytics/storage"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type seenItem struct {
+	key      string
+	expireAt time.Time
+}
+
+type seenSet struct {
+	capacity int
+	mutex    sync.Mutex
+	// least recently seen at the back
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+// NewSeenSet returns a SeenSet kept in process memory. It is only suitable when a single
+// api instance is deployed, use a shared store otherwise.
+//
+// At most capacity keys are kept, the least recently seen key is forgotten before it
+// expires when full. A capacity of 0 means unbounded.
+func NewSeenSet(capacity int) storage.SeenSet {
+	s := &seenSet{
+		capacity: capacity,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+	go s.sweep()
+	return s
+}
+
+func (s *seenSet) Seen(appId string, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	key = appId + "/" + key
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.items[key]; ok {
+		item := element.Value.(*seenItem)
+		if now.Before(item.expireAt) {
+			s.lru.MoveToFront(element)
+			return true, nil
+		}
+		item.expireAt = now.Add(ttl)
+		s.lru.MoveToFront(element)
+		return false, nil
+	}
+
+	s.items[key] = s.lru.PushFront(&seenItem{key: key, expireAt: now.Add(ttl)})
+	if s.capacity > 0 && s.lru.Len() > s.capacity {
+		s.remove(s.lru.Back())
+	}
+	return false, nil
+}
+
+func (s *seenSet) Forget(appId string, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.items[appId+"/"+key]; ok {
+		s.remove(element)
+	}
+	return nil
+}
+
+func (s *seenSet) remove(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.items, element.Value.(*seenItem).key)
+}
+
+func (s *seenSet) sweep() {
+	for {
+		time.Sleep(sweepInterval)
+		now := time.Now()
+		s.mutex.Lock()
+		for _, element := range s.items {
+			if !now.Before(element.Value.(*seenItem).expireAt) {
+				s.remove(element)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
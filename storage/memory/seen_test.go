@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSeenSet_Seen(t *testing.T) {
+	s := NewSeenSet(0)
+
+	seen, err := s.Seen("app", "foo", time.Minute)
+	require.NoError(t, err)
+	require.False(t, seen)
+
+	seen, err = s.Seen("app", "foo", time.Minute)
+	require.NoError(t, err)
+	require.True(t, seen)
+
+	seen, err = s.Seen("otherApp", "foo", time.Minute)
+	require.NoError(t, err)
+	require.False(t, seen)
+
+	seen, err = s.Seen("app", "bar", time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, seen)
+	time.Sleep(2 * time.Millisecond)
+	seen, err = s.Seen("app", "bar", time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, seen)
+
+	require.NoError(t, s.Forget("app", "foo"))
+	seen, err = s.Seen("app", "foo", time.Minute)
+	require.NoError(t, err)
+	require.False(t, seen)
+}
+
+func TestSeenSet_Capacity(t *testing.T) {
+	s := NewSeenSet(2)
+
+	for _, key := range []string{"a", "b", "a", "c"} {
+		_, err := s.Seen("app", key, time.Minute)
+		require.NoError(t, err)
+	}
+
+	// b is the least recently seen key and forgotten
+	seen, err := s.Seen("app", "b", time.Minute)
+	require.NoError(t, err)
+	require.False(t, seen)
+
+	// a was pushed out by b
+	seen, err = s.Seen("app", "c", time.Minute)
+	require.NoError(t, err)
+	require.True(t, seen)
+	seen, err = s.Seen("app", "a", time.Minute)
+	require.NoError(t, err)
+	require.False(t, seen)
+}
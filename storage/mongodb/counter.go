@@ -27,6 +27,20 @@ const (
 	customizedCounterCollectionName      = "customizedCounterCollection"
 )
 
+// slot names are stored as field names, which must not contain dots or start with $
+var (
+	slotNameEscaper   = strings.NewReplacer("%", "%25", ".", "%2E", "$", "%24")
+	slotNameUnescaper = strings.NewReplacer("%25", "%", "%2E", ".", "%24", "$")
+)
+
+func escapeSlotName(slotName string) string {
+	return slotNameEscaper.Replace(slotName)
+}
+
+func unescapeSlotName(slotName string) string {
+	return slotNameUnescaper.Replace(slotName)
+}
+
 func NewCounter(client *mongo.Client, databasePrefix string) storage.Counter {
 	return &counter{
 		databasePrefix: databasePrefix,
@@ -82,7 +96,7 @@ func (c *counter) GetSimpleCounterSum(appId string, counterName string, startTim
 func (c *counter) AddSlotCounter(appId string, counterName, slotName string, dateTimestamp int64, amount float64) error {
 	ctx := context.Background()
 	filter := bson.M{"date": dateTimestamp}
-	update := bson.M{"$inc": bson.M{"counter." + slotName: amount}}
+	update := bson.M{"$inc": bson.M{"counter." + escapeSlotName(slotName): amount}}
 	upsert := true
 	option := &options.UpdateOptions{
 		Upsert: &upsert,
@@ -94,7 +108,7 @@ func (c *counter) AddSlotCounter(appId string, counterName, slotName string, dat
 func (c *counter) SetSlotCounter(appId string, counterName, slotName string, dateTimestamp int64, amount float64) error {
 	ctx := context.Background()
 	filter := bson.M{"date": dateTimestamp}
-	update := bson.M{"$set": bson.M{"counter." + slotName: amount}}
+	update := bson.M{"$set": bson.M{"counter." + escapeSlotName(slotName): amount}}
 	upsert := true
 	option := &options.UpdateOptions{
 		Upsert: &upsert,
@@ -117,7 +131,7 @@ func (c *counter) GetSlotCounterPartialSlotSum(appId string, counterName string,
 
 	var sum float64
 	for _, slot := range slots {
-		sum += tmp.Counter[slot]
+		sum += tmp.Counter[escapeSlotName(slot)]
 	}
 	return sum
 }
@@ -155,7 +169,7 @@ func (c *counter) GetSlotCounterSpan(appId string, counterName string, start, en
 		slotCounter := make(storage.SlotCounter)
 
 		for _, e := range counterArray {
-			slot := e.Key
+			slot := unescapeSlotName(e.Key)
 			count := e.Value.(float64)
 			slotCounter[slot] = count
 		}
@@ -173,6 +187,7 @@ func (c *counter) GetSlotCounterSum(appId string, counterName string, start, end
 		"_id": nil,
 	}
 	for _, slot := range slots {
+		slot = escapeSlotName(slot)
 		group[slot] = bson.M{
 			"$sum": fmt.Sprintf("$counter.%s", slot),
 		}
@@ -208,7 +223,7 @@ func (c *counter) GetSlotCounterSum(appId string, counterName string, start, end
 			if key == "_id" {
 				continue
 			}
-			sums[key] = value.(float64)
+			sums[unescapeSlotName(key)] = value.(float64)
 		}
 	}
 	return
@@ -341,6 +356,10 @@ func (c *counter) GetSimpleCPVChannelSumDate(appId, counterName, channel string,
 	return c.getSimpleCPVPartialSumDate(appId, counterName, "C", channel, start, end)
 }
 
+func (c *counter) GetSimpleCPVPlatformSumDate(appId, counterName, platform string, start, end int64) (map[int64]float64, error) {
+	return c.getSimpleCPVPartialSumDate(appId, counterName, "P", platform, start, end)
+}
+
 func (c *counter) GetSimpleCPVSumDate(appId, counterName string, start, end int64) (map[int64]float64, error) {
 	return c.getSimpleCPVPartialSumDate(appId, counterName, "", "", start, end)
 }
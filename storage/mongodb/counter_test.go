@@ -162,6 +162,25 @@ func TestCounter_GetSlotCounterSum(t *testing.T) {
 	require.Equal(t, 2.4*7, bazSum)
 }
 
+func TestCounter_SlotNameEscape(t *testing.T) {
+	mongoCounter := NewCounter(newMongoClient(), "goanalytics").(*counter)
+	defer mongoCounter.database(appId).Drop(context.Background())
+
+	slot := "US/MO/St. Louis"
+	err := mongoCounter.AddSlotCounter(appId, "foo", slot, utils.TodayTimestamp(), 1)
+	require.NoError(t, err)
+
+	span, err := mongoCounter.GetSlotCounterSpan(appId, "foo", utils.TodayTimestamp(), utils.TodayTimestamp())
+	require.NoError(t, err)
+	require.Equal(t, 1.0, span[utils.TodayTimestamp()][slot])
+
+	sums, err := mongoCounter.GetSlotCounterSum(appId, "foo", utils.TodayTimestamp(), utils.TodayTimestamp(), []string{slot})
+	require.NoError(t, err)
+	require.Equal(t, 1.0, sums[slot])
+
+	require.Equal(t, "$100%.", unescapeSlotName(escapeSlotName("$100%.")))
+}
+
 func TestCounter_SetSlotCounter_GetSlotCounterSpan(t *testing.T) {
 	mongoCounter := NewCounter(newMongoClient(), "goanalytics").(*counter)
 	defer mongoCounter.database(appId).Drop(context.Background())
@@ -232,6 +251,22 @@ func TestCounter_GetSimpleCPVSumDate(t *testing.T) {
 	require.Equal(t, 10.0, sum)
 }
 
+func TestCounter_GetSimpleCPVPlatformSumDate(t *testing.T) {
+	mongoCounter := NewCounter(newMongoClient(), "test_").(*counter)
+	defer mongoCounter.database(appId).Drop(context.Background())
+
+	for _, platform := range []string{"ios", "android", "web", "miniprogram"} {
+		err := mongoCounter.AddSimpleCPVCounter(appId, "c0", platform, "v0", "cpv", utils.TodayTimestamp(), 1.0)
+		require.NoError(t, err)
+	}
+	err := mongoCounter.AddSimpleCPVCounter(appId, "c1", "web", "v0", "cpv", utils.TodayTimestamp(), 2.0)
+	require.NoError(t, err)
+
+	sums, err := mongoCounter.GetSimpleCPVPlatformSumDate(appId, "cpv", "web", utils.TodayTimestamp(), utils.TodayTimestamp())
+	require.NoError(t, err)
+	require.Equal(t, 3.0, sums[utils.TodayTimestamp()])
+}
+
 func TestCounter_SetSimpleCPVCounter(t *testing.T) {
 	mongoCounter := NewCounter(newMongoClient(), "goanalytics").(*counter)
 	defer mongoCounter.database(appId).Drop(context.Background())
@@ -0,0 +1,132 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	dailyTrackerCollectionName = "dailyTrackerCollection"
+)
+
+type dailyTracker struct {
+	client         *mongo.Client
+	databasePrefix string
+}
+
+func NewDailyTracker(client *mongo.Client, databasePrefix string) storage.DailyTracker {
+	return &dailyTracker{
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (dt *dailyTracker) collection(appId string) *mongo.Collection {
+	return dt.client.Database(dt.databasePrefix + appId).Collection(dailyTrackerCollectionName)
+}
+
+func (dt *dailyTracker) MarkDailyProcessed(appId, name string, date int64) error {
+	ctx := context.Background()
+	filter := bson.M{
+		"name": name,
+		"date": date,
+	}
+	// keep the late mark of data arriving while the date is being computed again
+	update := bson.M{
+		"$set": bson.M{
+			"processed": true,
+		},
+		"$setOnInsert": bson.M{
+			"late": false,
+		},
+	}
+	upsert := true
+	option := &options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	_, err := dt.collection(appId).UpdateOne(ctx, filter, update, option)
+	return err
+}
+
+func (dt *dailyTracker) IsDailyProcessed(appId, name string, date int64) (bool, error) {
+	ctx := context.Background()
+	filter := bson.M{
+		"name":      name,
+		"date":      date,
+		"processed": true,
+	}
+	count, err := dt.collection(appId).CountDocuments(ctx, filter)
+	return count > 0, err
+}
+
+func (dt *dailyTracker) MarkDailyFailed(appId, name string, date int64) error {
+	ctx := context.Background()
+	filter := bson.M{
+		"name": name,
+		"date": date,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"processed": false,
+			"late":      true,
+		},
+	}
+	upsert := true
+	option := &options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	_, err := dt.collection(appId).UpdateOne(ctx, filter, update, option)
+	return err
+}
+
+func (dt *dailyTracker) MarkDailyLate(appId, name string, date int64) error {
+	ctx := context.Background()
+	filter := bson.M{
+		"name":      name,
+		"date":      date,
+		"processed": true,
+		"late":      false,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"late": true,
+		},
+	}
+	_, err := dt.collection(appId).UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (dt *dailyTracker) PopDailyLate(appId, name string) ([]int64, error) {
+	ctx := context.Background()
+	filter := bson.M{
+		"name": name,
+		"late": true,
+	}
+	cursor, err := dt.collection(appId).Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var tmp struct {
+		Date int64 `bson:"date"`
+	}
+	dates := make([]int64, 0)
+	for cursor.Next(ctx) {
+		if err = cursor.Decode(&tmp); err != nil {
+			return nil, err
+		}
+		dates = append(dates, tmp.Date)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	// clear the marks before computing, data arriving meanwhile marks the date again
+	if len(dates) > 0 {
+		filter["date"] = bson.M{"$in": dates}
+		_, err = dt.collection(appId).UpdateMany(ctx, filter, bson.M{"$set": bson.M{"late": false}})
+	}
+	return dates, err
+}
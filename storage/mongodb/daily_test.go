@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/utils"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDailyTracker(t *testing.T) {
+	client := newMongoClient()
+	tracker := NewDailyTracker(client, "goanalytics")
+	defer client.Database("goanalytics" + appId).Drop(context.Background())
+
+	yesterday := utils.TodayDiff(1).Unix()
+	today := utils.TodayTimestamp()
+
+	// not processed yet
+	require.NoError(t, tracker.MarkDailyLate(appId, "foo", today))
+	processed, err := tracker.IsDailyProcessed(appId, "foo", yesterday)
+	require.NoError(t, err)
+	require.False(t, processed)
+	require.NoError(t, tracker.MarkDailyProcessed(appId, "foo", yesterday))
+	processed, err = tracker.IsDailyProcessed(appId, "foo", yesterday)
+	require.NoError(t, err)
+	require.True(t, processed)
+	processed, err = tracker.IsDailyProcessed(appId, "foo", today)
+	require.NoError(t, err)
+	require.False(t, processed)
+	require.NoError(t, tracker.MarkDailyLate(appId, "foo", yesterday))
+	require.NoError(t, tracker.MarkDailyLate(appId, "bar", yesterday))
+
+	dates, err := tracker.PopDailyLate(appId, "foo")
+	require.NoError(t, err)
+	require.Equal(t, []int64{yesterday}, dates)
+
+	dates, err = tracker.PopDailyLate(appId, "foo")
+	require.NoError(t, err)
+	require.Len(t, dates, 0)
+
+	// late data arriving during the computation is not lost
+	require.NoError(t, tracker.MarkDailyLate(appId, "foo", yesterday))
+	require.NoError(t, tracker.MarkDailyProcessed(appId, "foo", yesterday))
+	dates, err = tracker.PopDailyLate(appId, "foo")
+	require.NoError(t, err)
+	require.Equal(t, []int64{yesterday}, dates)
+
+	// a failed date is not processed and is computed again
+	require.NoError(t, tracker.MarkDailyFailed(appId, "bar", yesterday))
+	processed, err = tracker.IsDailyProcessed(appId, "bar", yesterday)
+	require.NoError(t, err)
+	require.False(t, processed)
+	dates, err = tracker.PopDailyLate(appId, "bar")
+	require.NoError(t, err)
+	require.Equal(t, []int64{yesterday}, dates)
+}
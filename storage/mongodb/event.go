@@ -0,0 +1,79 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+const (
+	// EventCollectionName is the collection of the raw custom events of an app database
+	EventCollectionName = "eventCollection"
+)
+
+type eventLog struct {
+	client         *mongo.Client
+	databasePrefix string
+	ttl            time.Duration
+	// apps whose indexes have been created
+	indexed sync.Map
+}
+
+// eventRecord is the stored event, removed by the ttl index at expireAt
+type eventRecord struct {
+	storage.Event `bson:",inline"`
+	ExpireAt      *time.Time `bson:"expireAt,omitempty"`
+}
+
+// NewEventLog returns an EventLog whose events are removed by a ttl index after ttl, a zero ttl
+// keeps them forever. The events of an app are indexed by name and timestamp for the funnel and
+// segment queries, the indexes are created with the first event of the app.
+func NewEventLog(client *mongo.Client, databasePrefix string, ttl time.Duration) storage.EventLog {
+	return &eventLog{
+		client:         client,
+		databasePrefix: databasePrefix,
+		ttl:            ttl,
+	}
+}
+
+func (el *eventLog) collection(appId string) *mongo.Collection {
+	return el.client.Database(el.databasePrefix + appId).Collection(EventCollectionName)
+}
+
+func (el *eventLog) ensureIndexes(appId string) error {
+	if _, ok := el.indexed.Load(appId); ok {
+		return nil
+	}
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "timestamp", Value: 1}}},
+	}
+	if el.ttl > 0 {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys:    bson.M{"expireAt": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+	}
+	_, err := el.collection(appId).Indexes().CreateMany(context.Background(), indexes)
+	if err != nil {
+		return err
+	}
+	el.indexed.Store(appId, true)
+	return nil
+}
+
+func (el *eventLog) SaveEvent(appId string, event storage.Event) error {
+	if err := el.ensureIndexes(appId); err != nil {
+		return err
+	}
+	record := eventRecord{Event: event}
+	if el.ttl > 0 {
+		expireAt := time.Now().Add(el.ttl)
+		record.ExpireAt = &expireAt
+	}
+	_, err := el.collection(appId).InsertOne(context.Background(), record)
+	return err
+}
@@ -0,0 +1,127 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+const (
+	quarantineCollectionName = "quarantineCollection"
+	// an app is trimmed at most once per interval, it may exceed maxEntries in between
+	quarantineTrimInterval = time.Minute
+)
+
+type quarantine struct {
+	client         *mongo.Client
+	databasePrefix string
+	ttl            time.Duration
+	maxEntries     int64
+	// apps whose ttl index has been created
+	indexed sync.Map
+	// app id -> time of the last trim
+	trimmed sync.Map
+}
+
+// NewQuarantine returns a Quarantine whose entries are removed by a ttl index after ttl, the
+// entries of an app are trimmed periodically to the latest maxEntries
+func NewQuarantine(client *mongo.Client, databasePrefix string, ttl time.Duration, maxEntries int64) storage.Quarantine {
+	return &quarantine{
+		client:         client,
+		databasePrefix: databasePrefix,
+		ttl:            ttl,
+		maxEntries:     maxEntries,
+	}
+}
+
+func (q *quarantine) collection(appId string) *mongo.Collection {
+	return q.client.Database(q.databasePrefix + appId).Collection(quarantineCollectionName)
+}
+
+func (q *quarantine) ensureIndex(appId string) error {
+	if _, ok := q.indexed.Load(appId); ok {
+		return nil
+	}
+	_, err := q.collection(appId).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"expireAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+	q.indexed.Store(appId, true)
+	return nil
+}
+
+func (q *quarantine) Add(appId string, entry storage.QuarantineEntry) error {
+	if err := q.ensureIndex(appId); err != nil {
+		return err
+	}
+	_, err := q.collection(appId).InsertOne(context.Background(), bson.M{
+		"reason":    entry.Reason,
+		"details":   entry.Details,
+		"method":    entry.Method,
+		"path":      entry.Path,
+		"query":     entry.Query,
+		"body":      entry.Body,
+		"timestamp": entry.Timestamp,
+		"expireAt":  time.Now().Add(q.ttl),
+	})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if last, ok := q.trimmed.Load(appId); ok && now.Sub(last.(time.Time)) < quarantineTrimInterval {
+		return nil
+	}
+	q.trimmed.Store(appId, now)
+	return q.trim(appId)
+}
+
+// trim removes the entries older than the latest maxEntries, ObjectIds increase with insertion
+func (q *quarantine) trim(appId string) error {
+	if q.maxEntries <= 0 {
+		return nil
+	}
+	ctx := context.Background()
+	option := options.FindOne().SetSort(bson.M{"_id": -1}).SetSkip(q.maxEntries - 1).
+		SetProjection(bson.M{"_id": 1})
+	var oldest struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	err := q.collection(appId).FindOne(ctx, bson.M{}, option).Decode(&oldest)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = q.collection(appId).DeleteMany(ctx, bson.M{"_id": bson.M{"$lt": oldest.Id}})
+	return err
+}
+
+func (q *quarantine) List(appId, reason string, limit int64) ([]storage.QuarantineEntry, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if reason != "" {
+		filter["reason"] = reason
+	}
+	option := options.Find().SetSort(bson.M{"timestamp": -1}).SetLimit(limit)
+	cursor, err := q.collection(appId).Find(ctx, filter, option)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]storage.QuarantineEntry, 0)
+	for cursor.Next(ctx) {
+		var entry storage.QuarantineEntry
+		if err = cursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, cursor.Err()
+}
@@ -0,0 +1,70 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	eventSchemaCollectionName = "eventSchemaCollection"
+)
+
+type schemaRegistry struct {
+	client         *mongo.Client
+	databasePrefix string
+}
+
+func NewSchemaRegistry(client *mongo.Client, databasePrefix string) storage.SchemaRegistry {
+	return &schemaRegistry{
+		client:         client,
+		databasePrefix: databasePrefix,
+	}
+}
+
+func (sr *schemaRegistry) collection(appId string) *mongo.Collection {
+	return sr.client.Database(sr.databasePrefix + appId).Collection(eventSchemaCollectionName)
+}
+
+// SetEventSchema registers the schema, replacing the schema of the same event
+func (sr *schemaRegistry) SetEventSchema(appId string, schema storage.EventSchema) error {
+	upsert := true
+	option := &options.ReplaceOptions{
+		Upsert: &upsert,
+	}
+	_, err := sr.collection(appId).ReplaceOne(context.Background(), bson.M{"name": schema.Name}, schema, option)
+	return err
+}
+
+func (sr *schemaRegistry) GetEventSchema(appId, name string) (schema storage.EventSchema, found bool, err error) {
+	result := sr.collection(appId).FindOne(context.Background(), bson.M{"name": name})
+	err = result.Decode(&schema)
+	if err == mongo.ErrNoDocuments {
+		return schema, false, nil
+	}
+	return schema, err == nil, err
+}
+
+func (sr *schemaRegistry) GetEventSchemas(appId string) ([]storage.EventSchema, error) {
+	ctx := context.Background()
+	cursor, err := sr.collection(appId).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	schemas := make([]storage.EventSchema, 0)
+	for cursor.Next(ctx) {
+		var schema storage.EventSchema
+		if err = cursor.Decode(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, cursor.Err()
+}
+
+func (sr *schemaRegistry) DeleteEventSchema(appId, name string) error {
+	_, err := sr.collection(appId).DeleteOne(context.Background(), bson.M{"name": name})
+	return err
+}
@@ -0,0 +1,96 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	client := newMongoClient()
+	registry := NewSchemaRegistry(client, "goanalytics")
+	defer client.Database("goanalytics" + appId).Drop(context.Background())
+
+	min := 1.0
+	schema := storage.EventSchema{
+		Name: "purchase",
+		Properties: []storage.PropertySchema{
+			{Name: "slot", Type: storage.PropertyTypeString, Required: true, Values: []string{"coin", "gem"}},
+			{Name: "amount", Type: storage.PropertyTypeNumber, Min: &min},
+		},
+		Strict: true,
+		Action: storage.SchemaActionReject,
+	}
+	require.True(t, schema.Valid())
+	require.NoError(t, registry.SetEventSchema(appId, schema))
+
+	got, found, err := registry.GetEventSchema(appId, "purchase")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, schema, got)
+
+	_, found, err = registry.GetEventSchema(appId, "foo")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	schemas, err := registry.GetEventSchemas(appId)
+	require.NoError(t, err)
+	require.Len(t, schemas, 1)
+
+	require.NoError(t, registry.DeleteEventSchema(appId, "purchase"))
+	_, found, err = registry.GetEventSchema(appId, "purchase")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestEventSchema_Validate(t *testing.T) {
+	min := 1.0
+	schema := storage.EventSchema{
+		Name: "purchase",
+		Properties: []storage.PropertySchema{
+			{Name: "slot", Type: storage.PropertyTypeString, Required: true, Values: []string{"coin", "gem"}},
+			{Name: "amount", Type: storage.PropertyTypeNumber, Min: &min},
+		},
+		Strict: true,
+		Action: storage.SchemaActionQuarantine,
+	}
+
+	require.Len(t, schema.Validate(map[string]interface{}{"slot": "coin", "amount": 2.0}), 0)
+	require.Equal(t, []string{"slot: missing"}, schema.Validate(map[string]interface{}{}))
+	require.Equal(t, []string{"slot: value \"foo\" not allowed", "amount: 0 less than 1"},
+		schema.Validate(map[string]interface{}{"slot": "foo", "amount": 0.0}))
+	require.Equal(t, []string{"bar: unknown property"},
+		schema.Validate(map[string]interface{}{"slot": "gem", "bar": true}))
+
+	schema.Action = "drop"
+	require.False(t, schema.Valid())
+}
+
+func TestQuarantine(t *testing.T) {
+	client := newMongoClient()
+	q := NewQuarantine(client, "goanalytics", time.Hour, 2)
+	defer client.Database("goanalytics" + appId).Drop(context.Background())
+
+	require.NoError(t, q.Add(appId, storage.QuarantineEntry{Reason: "schema_mismatch", Path: "/i/counter/customized", Timestamp: 1}))
+	require.NoError(t, q.Add(appId, storage.QuarantineEntry{Reason: "schema_mismatch", Path: "/i/counter/customized", Timestamp: 2}))
+	require.NoError(t, q.Add(appId, storage.QuarantineEntry{Reason: "other", Timestamp: 3}))
+
+	// the app was trimmed by the first add only
+	entries, err := q.List(appId, "", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// only the latest 2 entries are kept
+	require.NoError(t, q.(*quarantine).trim(appId))
+	entries, err = q.List(appId, "schema_mismatch", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, int64(2), entries[0].Timestamp)
+
+	entries, err = q.List(appId, "", 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, int64(3), entries[0].Timestamp)
+}
@@ -0,0 +1,104 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/lt90s/goanalytics/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sync"
+	"time"
+)
+
+type seenSet struct {
+	client         *mongo.Client
+	databasePrefix string
+	collectionName string
+	// apps whose ttl index has been created
+	indexed sync.Map
+}
+
+// NewSeenSet returns a SeenSet shared by all api instances. Keys are stored in a collection
+// of the app database and removed by a ttl index once expired.
+func NewSeenSet(client *mongo.Client, databasePrefix, collectionName string) storage.SeenSet {
+	return &seenSet{
+		client:         client,
+		databasePrefix: databasePrefix,
+		collectionName: collectionName,
+	}
+}
+
+func (s *seenSet) collection(appId string) *mongo.Collection {
+	return s.client.Database(s.databasePrefix + appId).Collection(s.collectionName)
+}
+
+func (s *seenSet) ensureIndex(appId string) error {
+	if _, ok := s.indexed.Load(appId); ok {
+		return nil
+	}
+	_, err := s.collection(appId).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"expireAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+	s.indexed.Store(appId, true)
+	return nil
+}
+
+func (s *seenSet) Seen(appId string, key string, ttl time.Duration) (bool, error) {
+	if err := s.ensureIndex(appId); err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	filter := bson.M{"_id": key}
+	update := bson.M{"$setOnInsert": bson.M{"expireAt": now.Add(ttl)}}
+	upsert := true
+	option := &options.UpdateOptions{
+		Upsert: &upsert,
+	}
+	result, err := s.collection(appId).UpdateOne(ctx, filter, update, option)
+	if err != nil {
+		// concurrent upserts of the same key
+		if isDuplicateKeyError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if result.UpsertedCount > 0 {
+		return false, nil
+	}
+
+	// the key may have expired without being removed by the ttl monitor yet
+	filter["expireAt"] = bson.M{"$lte": now}
+	update = bson.M{"$set": bson.M{"expireAt": now.Add(ttl)}}
+	result, err = s.collection(appId).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 0, nil
+}
+
+// duplicate key error code of the server
+const duplicateKeyErrorCode = 11000
+
+func isDuplicateKeyError(err error) bool {
+	writeException, ok := err.(mongo.WriteException)
+	if !ok {
+		return false
+	}
+	for _, writeError := range writeException.WriteErrors {
+		if writeError.Code == duplicateKeyErrorCode {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *seenSet) Forget(appId string, key string) error {
+	_, err := s.collection(appId).DeleteOne(context.Background(), bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,38 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+	"time"
+)
+
+func TestSeenSet_Seen(t *testing.T) {
+	client := newMongoClient()
+	s := NewSeenSet(client, "goanalytics", "seenCollection")
+	defer client.Database("goanalytics" + appId).Drop(context.Background())
+
+	seen, err := s.Seen(appId, "foo", time.Minute)
+	require.NoError(t, err)
+	require.False(t, seen)
+
+	seen, err = s.Seen(appId, "foo", time.Minute)
+	require.NoError(t, err)
+	require.True(t, seen)
+
+	seen, err = s.Seen(appId, "bar", time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, seen)
+	time.Sleep(5 * time.Millisecond)
+	seen, err = s.Seen(appId, "bar", time.Minute)
+	require.NoError(t, err)
+	require.False(t, seen)
+}
+
+func TestIsDuplicateKeyError(t *testing.T) {
+	require.True(t, isDuplicateKeyError(mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}))
+	require.False(t, isDuplicateKeyError(mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 2}}}))
+	require.False(t, isDuplicateKeyError(errors.New("E11000 duplicate key error")))
+}
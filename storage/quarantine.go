@@ -0,0 +1,19 @@
+package storage
+
+// QuarantineEntry is a copy of an ingestion request that was not accepted
+type QuarantineEntry struct {
+	Reason string `json:"reason" bson:"reason"`
+	// e.g. the event name or the mismatched properties
+	Details   []string `json:"details" bson:"details"`
+	Method    string   `json:"method" bson:"method"`
+	Path      string   `json:"path" bson:"path"`
+	Query     string   `json:"query" bson:"query"`
+	Body      string   `json:"body" bson:"body"`
+	Timestamp int64    `json:"timestamp" bson:"timestamp"`
+}
+
+type Quarantine interface {
+	Add(appId string, entry QuarantineEntry) error
+	// List returns the latest entries, of the reason when reason is not empty
+	List(appId, reason string, limit int64) ([]QuarantineEntry, error)
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	PropertyTypeString = "string"
+	PropertyTypeNumber = "number"
+	PropertyTypeBool   = "bool"
+)
+
+const (
+	// mismatched events are rejected
+	SchemaActionReject = "reject"
+	// mismatched events are not counted and kept in the quarantine for inspection
+	SchemaActionQuarantine = "quarantine"
+)
+
+type PropertySchema struct {
+	Name     string `json:"name" bson:"name"`
+	Type     string `json:"type" bson:"type"`
+	Required bool   `json:"required" bson:"required"`
+	// allowed values of a string property, any value when empty
+	Values []string `json:"values" bson:"values"`
+	// range of a number property, unbounded when nil
+	Min *float64 `json:"min" bson:"min"`
+	Max *float64 `json:"max" bson:"max"`
+}
+
+// EventSchema describes the properties of a custom event. The slot and the amount of a
+// customized counter event are validated as the properties "slot" and "amount".
+type EventSchema struct {
+	Name       string           `json:"name" bson:"name"`
+	Properties []PropertySchema `json:"properties" bson:"properties"`
+	// reject properties not in the schema
+	Strict bool   `json:"strict" bson:"strict"`
+	Action string `json:"action" bson:"action"`
+}
+
+func (s EventSchema) Valid() bool {
+	if s.Name == "" {
+		return false
+	}
+	if s.Action != SchemaActionReject && s.Action != SchemaActionQuarantine {
+		return false
+	}
+
+	names := make(map[string]bool)
+	for _, p := range s.Properties {
+		if p.Name == "" || names[p.Name] {
+			return false
+		}
+		names[p.Name] = true
+		switch p.Type {
+		case PropertyTypeString:
+			if p.Min != nil || p.Max != nil {
+				return false
+			}
+		case PropertyTypeNumber:
+			if len(p.Values) > 0 || (p.Min != nil && p.Max != nil && *p.Min > *p.Max) {
+				return false
+			}
+		case PropertyTypeBool:
+			if len(p.Values) > 0 || p.Min != nil || p.Max != nil {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Validate returns the mismatches of the properties, properties decoded from json are expected
+func (s EventSchema) Validate(properties map[string]interface{}) []string {
+	mismatches := make([]string, 0)
+	known := make(map[string]bool)
+	for _, p := range s.Properties {
+		known[p.Name] = true
+		value, ok := properties[p.Name]
+		if !ok || value == nil {
+			if p.Required {
+				mismatches = append(mismatches, fmt.Sprintf("%s: missing", p.Name))
+			}
+			continue
+		}
+		if mismatch := p.validate(value); mismatch != "" {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s", p.Name, mismatch))
+		}
+	}
+
+	if s.Strict {
+		unknown := make([]string, 0)
+		for name := range properties {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		for _, name := range unknown {
+			mismatches = append(mismatches, fmt.Sprintf("%s: unknown property", name))
+		}
+	}
+	return mismatches
+}
+
+func (p PropertySchema) validate(value interface{}) string {
+	switch p.Type {
+	case PropertyTypeString:
+		s, ok := value.(string)
+		if !ok {
+			return "not a string"
+		}
+		if len(p.Values) == 0 {
+			return ""
+		}
+		for _, v := range p.Values {
+			if s == v {
+				return ""
+			}
+		}
+		return fmt.Sprintf("value %q not allowed", s)
+	case PropertyTypeNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return "not a number"
+		}
+		if p.Min != nil && n < *p.Min {
+			return fmt.Sprintf("%v less than %v", n, *p.Min)
+		}
+		if p.Max != nil && n > *p.Max {
+			return fmt.Sprintf("%v greater than %v", n, *p.Max)
+		}
+	case PropertyTypeBool:
+		if _, ok := value.(bool); !ok {
+			return "not a bool"
+		}
+	}
+	return ""
+}
+
+type SchemaRegistry interface {
+	SetEventSchema(appId string, schema EventSchema) error
+	GetEventSchema(appId, name string) (schema EventSchema, found bool, err error)
+	GetEventSchemas(appId string) ([]EventSchema, error)
+	DeleteEventSchema(appId, name string) error
+}
@@ -0,0 +1,11 @@
+package storage
+
+import "time"
+
+// SeenSet remembers keys for a while, it is used to detect duplicated requests
+type SeenSet interface {
+	// Seen reports whether key has been seen within ttl, and records it if not
+	Seen(appId string, key string, ttl time.Duration) (bool, error)
+	// Forget removes the key, so that it is not seen any more
+	Forget(appId string, key string) error
+}